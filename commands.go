@@ -0,0 +1,820 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/conformance"
+	"example.com/golox/lox/dap"
+	"example.com/golox/lox/debugger"
+	"example.com/golox/lox/diagnostics"
+	"example.com/golox/lox/format"
+	"example.com/golox/lox/lint"
+	"example.com/golox/lox/loxtest"
+	"example.com/golox/lox/lsp"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/refactor"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// commands are the subcommands accepted as the first argument, as in
+// "glox lsp". Each returns the process exit code.
+var commands = map[string]func(args []string) int{
+	"ast":         runAST,
+	"conformance": runConformance,
+	"dap":         runDAP,
+	"debug":       runDebug,
+	"explain":     runExplain,
+	"fmt":         runFmt,
+	"lint":        runLint,
+	"lsp":         runLSP,
+	"rename":      runRename,
+	"test":        runTest,
+	"tokens":      runTokens,
+}
+
+func runLSP(args []string) int {
+	if len(args) != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: glox lsp")
+		return exitUsage
+	}
+	if err := lsp.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "lsp:", err)
+		return 1
+	}
+	return 0
+}
+
+// runAST prints the syntax tree of a script: as S-expressions, as a
+// Graphviz digraph, or as the JSON document described by ast.EncodeJSON.
+func runAST(args []string) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	format := flags.String("format", "sexpr", "print the tree as `sexpr`, dot or json")
+	asJSON := flags.Bool("json", false, "short for --format=json")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox ast [--format sexpr|dot|json] [--diagnostics format] file.lox")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *asJSON {
+		*format = "json"
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+	switch *format {
+	case "sexpr", "dot", "json":
+	default:
+		fmt.Fprintf(os.Stderr, "glox ast: unknown format %q (want sexpr, dot or json)\n", *format)
+		return exitUsage
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.fail(path, err, fmt.Sprintln("Error:", err))
+		return exitDataError
+	}
+	var statements []ast.Stmt
+	if diagnostics := shared.Collect(func() {
+		statements = parser.NewParser(scanner.NewScanner(string(src)).ScanTokens()).Parse()
+	}); len(diagnostics) > 0 {
+		var text strings.Builder
+		for _, diag := range diagnostics {
+			fmt.Fprintln(&text, diag.Caret(string(src)))
+		}
+		log.fail(path, shared.Errors(diagnostics), text.String())
+		return exitDataError
+	}
+
+	switch *format {
+	case "dot":
+		fmt.Print(ast.DOT(statements))
+	case "json":
+		data, err := ast.EncodeJSON(statements)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+		fmt.Println(string(data))
+	default:
+		fmt.Print((&ast.AstPrinter{}).PrintProgram(statements))
+	}
+	return 0
+}
+
+// runTokens prints every token of a script with its position, for
+// debugging the scanner. Scan errors do not stop it: each is shown next
+// to the ILLEGAL token covering the bytes it is about.
+func runTokens(args []string) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	asJSON := flags.Bool("json", false, "print the tokens as a JSON array")
+	trivia := flags.Bool("trivia", false, "include whitespace, newlines and comments")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox tokens [--json] [--trivia] [--diagnostics format] file.lox")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.fail(path, err, fmt.Sprintln("Error:", err))
+		return exitDataError
+	}
+	var tokens []scanner.Token
+	errs := shared.Collect(func() {
+		tokens = scanner.NewScanner(string(src)).WithTrivia().ScanTokens()
+	})
+	if diagnosticsFormat.Structured() {
+		log.addError(path, shared.Errors(errs))
+	}
+
+	// The scanner adds one ILLEGAL token for each error, in order.
+	records := []tokenRecord{}
+	for _, token := range tokens {
+		record := tokenRecord{
+			Type:    token.Type.String(),
+			Lexeme:  token.Lexeme,
+			Literal: token.Literal,
+			Line:    token.Line,
+			Column:  token.Column,
+			Offset:  token.Offset,
+			Length:  token.Length,
+			start:   ast.TokenSpan(token).Start,
+		}
+		if token.Type == scanner.ILLEGAL && len(errs) > 0 {
+			record.Error = &tokenError{Code: errs[0].Code.ID, Message: errs[0].Message}
+			errs = errs[1:]
+		} else if token.Type.IsTrivia() && !*trivia {
+			continue
+		}
+		records = append(records, record)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(records); err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+			return 1
+		}
+	} else {
+		for _, r := range records {
+			// A token's Line is where it ends; show where it starts.
+			line := fmt.Sprintf("%-8s %-14s %s", fmt.Sprintf("%d:%d", r.start.Line, r.Column), r.Type, strconv.Quote(r.Lexeme))
+			switch literal := r.Literal.(type) {
+			case string:
+				line += " " + strconv.Quote(literal)
+			case float64:
+				line += " " + strconv.FormatFloat(literal, 'g', -1, 64)
+			}
+			if r.Error != nil {
+				line += fmt.Sprintf("  Error[%s]: %s", r.Error.Code, r.Error.Message)
+			}
+			fmt.Println(line)
+		}
+	}
+
+	for _, r := range records {
+		if r.Error != nil {
+			return exitDataError
+		}
+	}
+	return 0
+}
+
+// tokenRecord is a token as glox tokens --json prints it.
+type tokenRecord struct {
+	Type    string      `json:"type"`
+	Lexeme  string      `json:"lexeme"`
+	Literal any         `json:"literal,omitempty"`
+	Line    int         `json:"line"`
+	Column  int         `json:"column"`
+	Offset  int         `json:"offset"`
+	Length  int         `json:"length"`
+	Error   *tokenError `json:"error,omitempty"`
+
+	start ast.Pos
+}
+
+type tokenError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// runExplain prints the long form of each error code named, or lists
+// every code when none is.
+func runExplain(args []string) int {
+	if len(args) == 0 {
+		for _, code := range codes.All() {
+			fmt.Printf("%s  %-8s %s\n", code.ID, code.Phase, code.Message)
+		}
+		return 0
+	}
+
+	status := 0
+	for i, id := range args {
+		code, ok := codes.Lookup(strings.ToUpper(id))
+		if !ok {
+			fmt.Fprintf(os.Stderr, "glox explain: unknown error code %q\n", id)
+			status = exitUsage
+			continue
+		}
+		if i > 0 {
+			fmt.Println()
+		}
+		explanation := code.Explain()
+		fmt.Printf("%s: %s (%s error)\n\n%s\n", code.ID, code.Message, code.Phase, wrap(explanation.Text, 72))
+		if explanation.Wrong != "" {
+			fmt.Printf("\nWrong:\n\n%s\nRight:\n\n%s", indent(explanation.Wrong), indent(explanation.Right))
+		}
+	}
+	return status
+}
+
+// wrap breaks text into lines of at most width columns, between words.
+func wrap(text string, width int) string {
+	var b strings.Builder
+	column := 0
+	for _, word := range strings.Fields(text) {
+		switch {
+		case column == 0:
+		case column+1+len(word) > width:
+			b.WriteByte('\n')
+			column = 0
+		default:
+			b.WriteByte(' ')
+			column++
+		}
+		b.WriteString(word)
+		column += len(word)
+	}
+	return b.String()
+}
+
+// runFmt formats the named files, or standard input, printing the result
+// unless --check or --write is given.
+func runFmt(args []string) int {
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	check := flags.Bool("check", false, "list files whose formatting differs and exit 1 if any do")
+	write := flags.Bool("write", false, "rewrite files in place")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox fmt [--check | --write] [--diagnostics format] [file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *check && *write || (*check || *write) && flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	if flags.NArg() == 0 {
+		src, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			log.fail("<stdin>", err, fmt.Sprintln("Error:", err))
+			return exitDataError
+		}
+		out, err := format.Source(string(src))
+		if err != nil {
+			log.fail("<stdin>", err, fmt.Sprintln(err))
+			return exitDataError
+		}
+		fmt.Print(out)
+		return 0
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			log.fail(path, err, fmt.Sprintln("Error:", err))
+			status = exitDataError
+			continue
+		}
+		out, err := format.Source(string(src))
+		if err != nil {
+			log.fail(path, err, fmt.Sprintf("%s:\n%v\n", path, err))
+			status = exitDataError
+			continue
+		}
+
+		switch {
+		case *check:
+			if !bytes.Equal(src, []byte(out)) {
+				fmt.Println(path)
+				if status == 0 {
+					status = 1
+				}
+			}
+		case *write:
+			if !bytes.Equal(src, []byte(out)) {
+				if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+					fmt.Fprintln(os.Stderr, "Error:", err)
+					status = exitDataError
+				}
+			}
+		default:
+			fmt.Print(out)
+		}
+	}
+	return status
+}
+
+// runRename renames the symbol at file:line:column, printing the change
+// as a unified diff unless --write is given.
+func runRename(args []string) int {
+	flags := flag.NewFlagSet("rename", flag.ContinueOnError)
+	write := flags.Bool("write", false, "rewrite the file in place instead of printing a diff")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox rename [--write] [--diagnostics format] file.lox:line:column newName")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitUsage
+	}
+	path, line, column, ok := parsePosition(flags.Arg(0))
+	if !ok {
+		fmt.Fprintf(os.Stderr, "glox rename: %q is not file:line:column\n", flags.Arg(0))
+		return exitUsage
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		log.fail(path, err, fmt.Sprintln("Error:", err))
+		return exitDataError
+	}
+	edits, err := refactor.Rename(string(src), line, column, flags.Arg(1))
+	if err != nil {
+		var list shared.Errors
+		if errors.As(err, &list) {
+			log.fail(path, err, fmt.Sprintf("%s:\n%v\n", path, err))
+			return exitDataError
+		}
+		// A refusal is about the rename, not an error in the program.
+		log.fail(path, err, fmt.Sprintf("glox rename: %v\n", err))
+		return 1
+	}
+
+	out := refactor.Apply(string(src), edits)
+	if *write {
+		if err := os.WriteFile(path, []byte(out), 0o644); err != nil {
+			log.fail(path, err, fmt.Sprintln("Error:", err))
+			return exitDataError
+		}
+		return 0
+	}
+	fmt.Print(refactor.Diff(path, string(src), out))
+	return 0
+}
+
+// parsePosition splits "file.lox:12:5" into the file, line and column.
+func parsePosition(spec string) (path string, line, column int, ok bool) {
+	parts := strings.Split(spec, ":")
+	if len(parts) < 3 {
+		return "", 0, 0, false
+	}
+	n := len(parts)
+	line, errLine := strconv.Atoi(parts[n-2])
+	column, errColumn := strconv.Atoi(parts[n-1])
+	if errLine != nil || errColumn != nil || line < 1 || column < 1 {
+		return "", 0, 0, false
+	}
+	return strings.Join(parts[:n-2], ":"), line, column, true
+}
+
+// runLint lints the named files. It exits 1 if there were warnings and
+// exitDataError if there were errors.
+func runLint(args []string) int {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	disable := flags.String("disable", "", "comma-separated rule IDs to turn off")
+	list := flags.Bool("rules", false, "list the available rules and exit")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox lint [--disable rule,...] [--diagnostics format] file ...")
+		fmt.Fprintln(os.Stderr, "       glox lint --rules")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if *list {
+		for _, rule := range lint.Rules {
+			fmt.Printf("%-22s %s\n", rule.ID, rule.Description)
+		}
+		return 0
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	linter := lint.New()
+	if *disable != "" {
+		for _, id := range strings.Split(*disable, ",") {
+			if err := linter.Disable(strings.TrimSpace(id)); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				return exitUsage
+			}
+		}
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	status := 0
+	for _, path := range flags.Args() {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			log.fail(path, err, fmt.Sprintln("Error:", err))
+			status = exitDataError
+			continue
+		}
+		findings, err := linter.Check(string(src))
+		if err != nil {
+			log.fail(path, err, fmt.Sprintf("%s:\n%v\n", path, err))
+			status = exitDataError
+			continue
+		}
+		for _, finding := range findings {
+			if diagnosticsFormat.Structured() {
+				log.add(findingRecord(path, finding))
+			} else {
+				fmt.Printf("%s:%d: %s [%s] %s\n", path, finding.Line, finding.Severity, finding.Rule, finding.Message)
+			}
+			if finding.Severity == lint.Error {
+				status = exitDataError
+			} else if status == 0 {
+				status = 1
+			}
+		}
+	}
+	return status
+}
+
+// findingRecord makes a diagnostics record of a lint finding. The code of
+// a rule's finding is the rule ID.
+func findingRecord(path string, finding lint.Finding) diagnostics.Record {
+	record := diagnostics.Record{
+		File:     path,
+		Line:     finding.Line,
+		Column:   finding.Column,
+		Severity: diagnostics.Warning,
+		Phase:    diagnostics.Lint,
+		Code:     finding.Rule,
+		Message:  finding.Message,
+	}
+	if finding.Severity == lint.Error {
+		record.Severity = diagnostics.Error
+	}
+	if finding.Code.ID != "" {
+		record.Phase, record.Code = finding.Code.Phase, finding.Code.ID
+	}
+	return record
+}
+
+// runDebug runs a script under the terminal debugger, reading commands
+// from stdin. Breakpoints given with --break are set before it starts.
+func runDebug(args []string) int {
+	flags := flag.NewFlagSet("debug", flag.ContinueOnError)
+	breaks := flags.String("break", "", "comma-separated breakpoints to set before starting")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox debug [--break spec,...] script")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 1 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	path := flags.Arg(0)
+	src, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitDataError
+	}
+	session, err := debugger.NewSession(interp, path, string(src))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return exitDataError
+	}
+	if *breaks != "" {
+		for _, spec := range strings.Split(*breaks, ",") {
+			if _, err := session.SetBreakpoint(spec); err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				return exitUsage
+			}
+		}
+	}
+
+	debugger.NewConsole(session, os.Stdin, os.Stdout).Run()
+	if shared.HadRuntimeError {
+		return exitRuntimeError
+	}
+	return 0
+}
+
+// runDAP serves the Debug Adapter Protocol on stdio, or on one TCP
+// connection when --listen is given.
+func runDAP(args []string) int {
+	flags := flag.NewFlagSet("dap", flag.ContinueOnError)
+	listen := flags.String("listen", "", "accept one client on this address, e.g. 127.0.0.1:4711, instead of using stdio")
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox dap [--listen addr]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	if flags.NArg() != 0 {
+		flags.Usage()
+		return exitUsage
+	}
+
+	if *listen == "" {
+		if err := dap.NewServer(os.Stdin, os.Stdout).Serve(); err != nil {
+			fmt.Fprintln(os.Stderr, "dap:", err)
+			return 1
+		}
+		return 0
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dap:", err)
+		return 1
+	}
+	defer listener.Close()
+	fmt.Fprintln(os.Stderr, "dap: listening on", listener.Addr())
+
+	conn, err := listener.Accept()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "dap:", err)
+		return 1
+	}
+	defer conn.Close()
+	if err := dap.NewServer(conn, conn).Serve(); err != nil {
+		fmt.Fprintln(os.Stderr, "dap:", err)
+		return 1
+	}
+	return 0
+}
+
+// runTest runs the Lox tests in *_test.lox files under the given paths,
+// or the current directory. It exits 1 if any test fails.
+func runTest(args []string) int {
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "list every test and show output of passing tests too")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox test [-v] [--diagnostics format] [dir | file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := loxtest.Discover(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitUsage
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "No *"+loxtest.Suffix+" files found.")
+		return 1
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	passed, failed := 0, 0
+	for _, file := range files {
+		results, err := loxtest.RunFile(file)
+		if err != nil {
+			log.addError(file, err)
+			fmt.Printf("FAIL %s\n%s", file, indent(err.Error()))
+			failed++
+			continue
+		}
+
+		fileFailed := false
+		for _, r := range results {
+			if r.Passed {
+				passed++
+				if *verbose {
+					fmt.Printf("--- PASS: %s (%.3fs)\n%s", r.Name, r.Duration.Seconds(), indent(r.Output))
+				}
+				continue
+			}
+			failed++
+			fileFailed = true
+			log.add(diagnostics.Record{
+				File:     file,
+				Line:     r.Line,
+				Severity: diagnostics.Error,
+				Phase:    r.Code.Phase,
+				Code:     r.Code.ID,
+				Message:  r.Name + ": " + r.Message,
+			})
+			fmt.Printf("--- FAIL: %s (%s:%d)\n%s%s", r.Name, file, r.Line, indent(r.Message), indent(r.Output))
+		}
+		if fileFailed {
+			fmt.Printf("FAIL %s\n", file)
+		} else {
+			fmt.Printf("ok   %s\n", file)
+		}
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL: %d passed, %d failed\n", passed, failed)
+		return 1
+	}
+	fmt.Printf("PASS: %d passed\n", passed)
+	return 0
+}
+
+// indent prefixes each line of s with four spaces.
+func indent(s string) string {
+	if s == "" {
+		return ""
+	}
+	lines := strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+	return "    " + strings.Join(lines, "\n    ") + "\n"
+}
+
+// runConformance checks .lox scripts against their "// expect:" comments,
+// showing a diff for each mismatch. It exits 1 if any script fails.
+func runConformance(args []string) int {
+	flags := flag.NewFlagSet("conformance", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "list passing scripts too")
+	flags.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+	flags.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox conformance [-v] [--diagnostics format] [dir | file ...]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := conformance.Discover(paths...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitUsage
+	}
+
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		defer log.write()
+	}
+
+	failed := 0
+	for _, file := range files {
+		result, err := conformance.Run(file)
+		if err != nil {
+			log.fail(file, err, fmt.Sprintln("Error:", err))
+			failed++
+			continue
+		}
+		if result.Passed() {
+			if *verbose {
+				fmt.Printf("ok   %s\n", file)
+			}
+			continue
+		}
+		failed++
+		log.add(diagnostics.Record{
+			File:     file,
+			Severity: diagnostics.Error,
+			Message:  "output does not match the expectations (- expected, + actual):\n" + strings.TrimSuffix(result.Diff(), "\n"),
+		})
+		fmt.Printf("FAIL %s (- expected, + actual)\n%s", file, indent(result.Diff()))
+	}
+
+	if failed > 0 {
+		fmt.Printf("FAIL: %d of %d scripts failed\n", failed, len(files))
+		return 1
+	}
+	fmt.Printf("PASS: %d scripts\n", len(files))
+	return 0
+}
+
+// diagnosticsFormat is set by --diagnostics, which may come before the
+// command name or, for the commands that take it, after it.
+var diagnosticsFormat diagnostics.Format
+
+const diagnosticsUsage = "write errors to stderr as `format`: text, json or sarif"
+
+// structuredCommands are the commands that honor --diagnostics=json or
+// sarif, as running a script does. The others report no errors in a
+// program: debug and dap show them to the user as the program runs, lsp
+// publishes them over its own protocol and explain only describes codes.
+var structuredCommands = map[string]bool{
+	"ast": true, "conformance": true, "fmt": true, "lint": true,
+	"rename": true, "test": true, "tokens": true,
+}
+
+// diagnosticsLog gathers the records for a structured --diagnostics
+// document, which is written to stderr in place of the usual messages.
+type diagnosticsLog struct {
+	records []diagnostics.Record
+}
+
+func (l *diagnosticsLog) add(records ...diagnostics.Record) {
+	l.records = append(l.records, records...)
+}
+
+// fail reports err about file. With a structured --diagnostics format it
+// is recorded, otherwise text is printed to stderr.
+func (l *diagnosticsLog) fail(file string, err error, text string) {
+	if diagnosticsFormat.Structured() {
+		l.addError(file, err)
+	} else {
+		fmt.Fprint(os.Stderr, text)
+	}
+}
+
+// addError records err about file: each diagnostic of a shared.Errors, or
+// a record with no phase or code for anything else, such as a file that
+// cannot be read.
+func (l *diagnosticsLog) addError(file string, err error) {
+	var list shared.Errors
+	if !errors.As(err, &list) {
+		l.add(diagnostics.Record{File: file, Severity: diagnostics.Error, Message: err.Error()})
+		return
+	}
+	for _, diag := range list {
+		l.add(diagnostics.FromDiagnostic(file, diag))
+	}
+}
+
+func (l *diagnosticsLog) write() {
+	if err := diagnostics.Write(os.Stderr, diagnosticsFormat, l.records); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// capture runs f with stdout and stderr redirected, and returns what it
+// wrote to each. The pipes are drained while f runs, so it may write more
+// than they buffer.
+func capture(t *testing.T, f func()) (stdout, stderr string) {
+	t.Helper()
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	rOut, wOut, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rErr, wErr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout, os.Stderr = wOut, wErr
+
+	drain := func(r *os.File) <-chan string {
+		ch := make(chan string)
+		go func() {
+			var buf bytes.Buffer
+			_, _ = io.Copy(&buf, r)
+			_ = r.Close()
+			ch <- buf.String()
+		}()
+		return ch
+	}
+	outCh, errCh := drain(rOut), drain(rErr)
+
+	f()
+
+	_ = wOut.Close()
+	_ = wErr.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	return <-outCh, <-errCh
+}
+
+func TestTokensAttachEachScanErrorToItsIllegalToken(t *testing.T) {
+	var code int
+	stdout, _ := capture(t, func() {
+		code = runTokens([]string{"--json", "testdata/scan_errors.lox"})
+	})
+	if code != exitDataError {
+		t.Errorf("exit code = %d, want %d", code, exitDataError)
+	}
+
+	var records []struct {
+		Type   string
+		Lexeme string
+		Column int
+		Error  *tokenError
+	}
+	if err := json.Unmarshal([]byte(stdout), &records); err != nil {
+		t.Fatalf("output is not a JSON array of tokens: %v\n%s", err, stdout)
+	}
+
+	type illegal struct {
+		lexeme, code, message string
+		column                int
+	}
+	want := []illegal{
+		{"@", "L0001", "Unexpected character.", 9},
+		{"#", "L0001", "Unexpected character.", 9},
+		{"\"never closed;\n", "L0002", "Unterminated string.", 7},
+	}
+	var got []illegal
+	for _, r := range records {
+		if r.Type != "ILLEGAL" {
+			if r.Error != nil {
+				t.Errorf("%s %q has error %+v, want none", r.Type, r.Lexeme, *r.Error)
+			}
+			continue
+		}
+		if r.Error == nil {
+			t.Errorf("ILLEGAL %q has no error", r.Lexeme)
+			continue
+		}
+		got = append(got, illegal{r.Lexeme, r.Error.Code, r.Error.Message, r.Column})
+	}
+	if len(got) != len(want) {
+		t.Fatalf("ILLEGAL tokens = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("ILLEGAL token %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+	if last := records[len(records)-1]; last.Type != "EOF" {
+		t.Errorf("last token = %s, want EOF", last.Type)
+	}
+}
+
+func TestTokensReportScanErrorsAsStructuredDiagnostics(t *testing.T) {
+	defer func() { diagnosticsFormat = "" }()
+
+	var code int
+	_, stderr := capture(t, func() {
+		code = runTokens([]string{"--diagnostics=json", "testdata/scan_errors.lox"})
+	})
+	if code != exitDataError {
+		t.Errorf("exit code = %d, want %d", code, exitDataError)
+	}
+
+	var document struct {
+		Diagnostics []struct{ Code string }
+	}
+	if err := json.Unmarshal([]byte(stderr), &document); err != nil {
+		t.Fatalf("stderr is not a diagnostics document: %v\n%s", err, stderr)
+	}
+	var codes []string
+	for _, d := range document.Diagnostics {
+		codes = append(codes, d.Code)
+	}
+	if len(codes) != 3 || codes[0] != "L0001" || codes[1] != "L0001" || codes[2] != "L0002" {
+		t.Errorf("codes = %v, want [L0001 L0001 L0002]", codes)
+	}
+}
+
+func TestTokensPrintsMoreThanAPipeHolds(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "long.lox")
+	if err := os.WriteFile(path, []byte(strings.Repeat("print 1;\n", 10000)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	stdout, _ := capture(t, func() {
+		code = runTokens([]string{"--json", path})
+	})
+	if code != 0 {
+		t.Errorf("exit code = %d, want 0", code)
+	}
+	var records []struct{ Type string }
+	if err := json.Unmarshal([]byte(stdout), &records); err != nil {
+		t.Fatalf("output is not a JSON array of tokens: %v", err)
+	}
+	if len(records) != 30001 {
+		t.Errorf("got %d tokens, want 30001", len(records))
+	}
+}
+
+func TestConformanceReportsFailuresAsStructuredDiagnostics(t *testing.T) {
+	defer func() { diagnosticsFormat = "" }()
+	path := filepath.Join(t.TempDir(), "wrong.lox")
+	if err := os.WriteFile(path, []byte("print 1; // expect: 2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var code int
+	_, stderr := capture(t, func() {
+		code = runConformance([]string{"--diagnostics=json", path})
+	})
+	if code != 1 {
+		t.Errorf("exit code = %d, want 1", code)
+	}
+
+	var document struct {
+		Diagnostics []struct{ File, Message string }
+	}
+	if err := json.Unmarshal([]byte(stderr), &document); err != nil {
+		t.Fatalf("stderr is not a diagnostics document: %v\n%s", err, stderr)
+	}
+	if len(document.Diagnostics) != 1 {
+		t.Fatalf("got %d records, want 1: %s", len(document.Diagnostics), stderr)
+	}
+	if d := document.Diagnostics[0]; d.File != path || !strings.Contains(d.Message, "- out: 2\n+ out: 1") {
+		t.Errorf("unexpected record %+v", d)
+	}
+}
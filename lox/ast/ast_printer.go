@@ -19,6 +19,25 @@ func (p *AstPrinter) Print(expr Expr) string {
 	return fmt.Sprint(result)
 }
 
+// PrintStmt renders a single statement in the same parenthesized style
+// used for expressions.
+func (p *AstPrinter) PrintStmt(stmt Stmt) string {
+	if stmt == nil {
+		return ""
+	}
+	return stmt.Accept(p).(string)
+}
+
+// PrintProgram renders every statement on its own line.
+func (p *AstPrinter) PrintProgram(stmts []Stmt) string {
+	var b strings.Builder
+	for _, stmt := range stmts {
+		b.WriteString(p.PrintStmt(stmt))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
 func (p *AstPrinter) VisitBinaryExpr(expr *Binary) any {
 	return p.parenthesize(expr.Operator.Lexeme, expr.Left, expr.Right)
 }
@@ -57,6 +76,10 @@ func (p *AstPrinter) VisitCallExpr(expr *Call) any {
 	return p.parenthesize("call", parts...)
 }
 
+func (p *AstPrinter) VisitErrorExpr(expr *Error) any {
+	return "(error)"
+}
+
 func (p *AstPrinter) VisitGetExpr(expr *Get) any {
 	return p.parenthesize("get "+expr.Name.Lexeme, expr.Object)
 }
@@ -73,8 +96,90 @@ func (p *AstPrinter) VisitSuperExpr(expr *Super) any {
 	return p.parenthesize("super "+expr.Method.Lexeme)
 }
 
+// ---- Statements ----
+
+func (p *AstPrinter) VisitBadStmt(stmt *Bad) any {
+	return "(error)"
+}
+
+func (p *AstPrinter) VisitBlockStmt(stmt *Block) any {
+	return p.group("block", stmt.Statements)
+}
+
+func (p *AstPrinter) VisitClassStmt(stmt *Class) any {
+	name := "class " + stmt.Name.Lexeme
+	if stmt.Superclass != nil {
+		name += " < " + p.Print(stmt.Superclass)
+	}
+
+	methods := make([]Stmt, 0, len(stmt.Methods))
+	for _, method := range stmt.Methods {
+		methods = append(methods, method)
+	}
+	return p.group(name, methods)
+}
+
+func (p *AstPrinter) VisitExpressionStmt(stmt *Expression) any {
+	return p.parenthesize(";", stmt.Expression)
+}
+
+func (p *AstPrinter) VisitFunctionStmt(stmt *Function) any {
+	params := make([]string, 0, len(stmt.Params))
+	for _, param := range stmt.Params {
+		params = append(params, param.Lexeme)
+	}
+	name := "fun " + stmt.Name.Lexeme + " (" + strings.Join(params, " ") + ")"
+	return p.group(name, stmt.Body)
+}
+
+func (p *AstPrinter) VisitPrintStmt(stmt *Print) any {
+	return p.parenthesize("print", stmt.Expression)
+}
+
+func (p *AstPrinter) VisitIfStmt(stmt *If) any {
+	name := "if " + p.Print(stmt.Condition)
+	if stmt.ElseBranch == nil {
+		return p.group(name, []Stmt{stmt.ThenBranch})
+	}
+	return p.group(name, []Stmt{stmt.ThenBranch, stmt.ElseBranch})
+}
+
+func (p *AstPrinter) VisitReturnStmt(stmt *Return) any {
+	if stmt.Value == nil {
+		return "(return)"
+	}
+	return p.parenthesize("return", stmt.Value)
+}
+
+func (p *AstPrinter) VisitVarStmt(stmt *Var) any {
+	if stmt.Initializer == nil {
+		return "(var " + stmt.Name.Lexeme + ")"
+	}
+	return p.parenthesize("var "+stmt.Name.Lexeme, stmt.Initializer)
+}
+
+func (p *AstPrinter) VisitWhileStmt(stmt *While) any {
+	return p.group("while "+p.Print(stmt.Condition), []Stmt{stmt.Body})
+}
+
 // ---- Helper ----
 
+func (p *AstPrinter) group(name string, stmts []Stmt) string {
+	var b strings.Builder
+
+	b.WriteString("(")
+	b.WriteString(name)
+
+	for _, stmt := range stmts {
+		b.WriteString(" ")
+		b.WriteString(p.PrintStmt(stmt))
+	}
+
+	b.WriteString(")")
+	return b.String()
+}
+
+
 func (p *AstPrinter) parenthesize(name string, exprs ...Expr) string {
 	var b strings.Builder
 
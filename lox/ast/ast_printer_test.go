@@ -1,6 +1,7 @@
 package ast
 
 import (
+    "strings"
     "testing"
 
     "example.com/golox/lox/scanner"
@@ -216,6 +217,8 @@ func (fakeExpr) Accept(v ExprVisitor) any {
     return 99 
 }
 
+func (fakeExpr) Extent() Span { return Span{} }
+
 func TestPrintFallbackToSprintForNonStringResult(t *testing.T) {
     p := &AstPrinter{}
     got := p.Print(fakeExpr{})
@@ -245,3 +248,100 @@ func TestAstPrinterCompositeExample(t *testing.T) {
         t.Fatalf("expected %q, got %q", want, got)
     }
 }
+
+func TestPrintProgramStatements(t *testing.T) {
+    stmts := []Stmt{
+        &Var{Name: tok(scanner.IDENTIFIER, "a"), Initializer: &Literal{Value: 1.0}},
+        &While{
+            Condition: &Variable{Name: tok(scanner.IDENTIFIER, "a")},
+            Body: &Block{Statements: []Stmt{
+                &Print{Expression: &Variable{Name: tok(scanner.IDENTIFIER, "a")}},
+            }},
+        },
+        &Function{
+            Name:   tok(scanner.IDENTIFIER, "f"),
+            Params: []scanner.Token{tok(scanner.IDENTIFIER, "x")},
+            Body:   []Stmt{&Return{Value: &Variable{Name: tok(scanner.IDENTIFIER, "x")}}},
+        },
+    }
+
+    got := (&AstPrinter{}).PrintProgram(stmts)
+    want := "(var a 1)\n(while a (block (print a)))\n(fun f (x) (return x))\n"
+
+    if got != want {
+        t.Fatalf("expected %q, got %q", want, got)
+    }
+}
+
+func TestDOTLabelsNodesAndEdges(t *testing.T) {
+    stmts := []Stmt{
+        &Print{Expression: &Binary{
+            Left:     &Literal{Value: 1.0},
+            Operator: tok(scanner.PLUS, "+"),
+            Right:    &Literal{Value: "a \"b\""},
+        }},
+        &Function{
+            Name:   tok(scanner.IDENTIFIER, "f"),
+            Params: []scanner.Token{tok(scanner.IDENTIFIER, "x"), tok(scanner.IDENTIFIER, "y")},
+            Body:   []Stmt{&Return{Keyword: tok(scanner.RETURN, "return")}},
+        },
+    }
+
+    got := DOT(stmts)
+    want := `digraph ast {
+  node [shape=box, fontname="monospace"];
+  n0 [label="program"];
+  n1 [label="Print"];
+  n2 [label="Binary\noperator: +"];
+  n3 [label="Literal\nvalue: 1"];
+  n2 -> n3 [label="left"];
+  n4 [label="Literal\nvalue: \"a \\\"b\\\"\""];
+  n2 -> n4 [label="right"];
+  n1 -> n2 [label="expression"];
+  n0 -> n1 [label="0"];
+  n5 [label="Function\nname: f\nparams: x y"];
+  n6 [label="Return\nkeyword: return"];
+  n5 -> n6 [label="body[0]"];
+  n0 -> n5 [label="1"];
+}
+`
+    if got != want {
+        t.Fatalf("got\n%s\nwant\n%s", got, want)
+    }
+}
+
+func TestInspectVisitsInSourceOrderAndPrunes(t *testing.T) {
+    // if (a) { print -b; } else fun f() { return c; }
+    program := &If{
+        Condition:  &Variable{Name: tok(scanner.IDENTIFIER, "a")},
+        ThenBranch: &Block{Statements: []Stmt{&Print{Expression: &Unary{Operator: tok(scanner.MINUS, "-"), Right: &Variable{Name: tok(scanner.IDENTIFIER, "b")}}}}},
+        ElseBranch: &Function{Name: tok(scanner.IDENTIFIER, "f"), Body: []Stmt{&Return{Value: &Variable{Name: tok(scanner.IDENTIFIER, "c")}}}},
+    }
+
+    var names []string
+    Inspect(program, func(node any) bool {
+        if v, ok := node.(*Variable); ok {
+            names = append(names, v.Name.Lexeme)
+        }
+        _, isFunction := node.(*Function)
+        return !isFunction
+    })
+
+    if got := strings.Join(names, " "); got != "a b" {
+        t.Fatalf("expected to visit a b and skip the function body, got %q", got)
+    }
+}
+
+func TestTokenSpanOfMultilineString(t *testing.T) {
+    // "a
+    // bc" starting at column 5 of line 1, so the scanner reports line 2.
+    token := scanner.Token{Type: scanner.STRING, Lexeme: "\"a\nbc\"", Line: 2, Column: 5, Offset: 4, Length: 6}
+    got := TokenSpan(token)
+    want := Span{Start: Pos{Offset: 4, Line: 1, Column: 5}, End: Pos{Offset: 10, Line: 2, Column: 4}}
+    if got != want {
+        t.Fatalf("got %+v, want %+v", got, want)
+    }
+    if joined := Join(Span{}, got); joined != got {
+        t.Errorf("joining with an invalid span should be a no-op, got %+v", joined)
+    }
+}
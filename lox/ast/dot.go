@@ -0,0 +1,103 @@
+package ast
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"example.com/golox/lox/scanner"
+)
+
+// DOT renders a program as a Graphviz digraph, for "dot -Tsvg". Each node
+// is a box labelled with its type and its tokens and values, one field a
+// line, and each child hangs off an edge labelled with the field it is
+// in, numbered when the field is a list.
+func DOT(statements []Stmt) string {
+	d := &dotWriter{}
+	d.b.WriteString("digraph ast {\n")
+	d.b.WriteString("  node [shape=box, fontname=\"monospace\"];\n")
+	root := d.node([]string{"program"})
+	for i, stmt := range statements {
+		d.edge(root, d.tree(reflect.ValueOf(stmt)), strconv.Itoa(i))
+	}
+	d.b.WriteString("}\n")
+	return d.b.String()
+}
+
+type dotWriter struct {
+	b    strings.Builder
+	next int
+}
+
+// tree writes the node v points to and everything under it, and returns
+// its ID.
+func (d *dotWriter) tree(v reflect.Value) string {
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	s := v.Elem()
+
+	type child struct {
+		label string
+		value reflect.Value
+	}
+	var children []child
+	label := []string{s.Type().Name()}
+	for i := 0; i < s.NumField(); i++ {
+		field, value := s.Type().Field(i), s.Field(i)
+		name := fieldName(field.Name)
+		switch {
+		case field.Type == nodeType:
+		case field.Type == tokenType:
+			label = append(label, name+": "+value.Interface().(scanner.Token).Lexeme)
+		case field.Type.Kind() == reflect.Slice && field.Type.Elem() == tokenType:
+			lexemes := make([]string, value.Len())
+			for j := range lexemes {
+				lexemes[j] = value.Index(j).Interface().(scanner.Token).Lexeme
+			}
+			label = append(label, name+": "+strings.Join(lexemes, " "))
+		case field.Type.Kind() == reflect.Slice:
+			for j := 0; j < value.Len(); j++ {
+				children = append(children, child{fmt.Sprintf("%s[%d]", name, j), value.Index(j)})
+			}
+		case field.Type == exprType || field.Type == stmtType || field.Type.Kind() == reflect.Pointer:
+			if !value.IsNil() {
+				children = append(children, child{name, value})
+			}
+		default:
+			label = append(label, name+": "+literal(value.Interface()))
+		}
+	}
+
+	id := d.node(label)
+	for _, c := range children {
+		d.edge(id, d.tree(c.value), c.label)
+	}
+	return id
+}
+
+func (d *dotWriter) node(label []string) string {
+	id := "n" + strconv.Itoa(d.next)
+	d.next++
+	for i, line := range label {
+		label[i] = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(line)
+	}
+	fmt.Fprintf(&d.b, "  %s [label=\"%s\"];\n", id, strings.Join(label, `\n`))
+	return id
+}
+
+func (d *dotWriter) edge(from, to, label string) {
+	fmt.Fprintf(&d.b, "  %s -> %s [label=\"%s\"];\n", from, to, label)
+}
+
+// literal writes a Literal's value as it would appear in Lox.
+func literal(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case string:
+		return strconv.Quote(v)
+	}
+	return fmt.Sprint(value)
+}
@@ -4,12 +4,14 @@ import "example.com/golox/lox/scanner"
 
 type Expr interface {
 	Accept(v ExprVisitor) any
+	Extent() Span
 }
 
 type ExprVisitor interface {
 	VisitAssignExpr(*Assign) any
 	VisitBinaryExpr(*Binary) any
 	VisitCallExpr(*Call) any
+	VisitErrorExpr(*Error) any
 	VisitGetExpr(*Get) any
 	VisitGroupingExpr(*Grouping) any
 	VisitLiteralExpr(*Literal) any
@@ -22,6 +24,7 @@ type ExprVisitor interface {
 }
 
 type Assign struct {
+	Node
 	Name scanner.Token
 	Value Expr
 }
@@ -31,6 +34,7 @@ func (n *Assign) Accept(v ExprVisitor) any {
 }
 
 type Binary struct {
+	Node
 	Left Expr
 	Operator scanner.Token
 	Right Expr
@@ -41,6 +45,7 @@ func (n *Binary) Accept(v ExprVisitor) any {
 }
 
 type Call struct {
+	Node
 	Callee Expr
 	Paren scanner.Token
 	Arguments []Expr
@@ -50,7 +55,17 @@ func (n *Call) Accept(v ExprVisitor) any {
 	return v.VisitCallExpr(n)
 }
 
+type Error struct {
+	Node
+	Tokens []scanner.Token
+}
+
+func (n *Error) Accept(v ExprVisitor) any {
+	return v.VisitErrorExpr(n)
+}
+
 type Get struct {
+	Node
 	Object Expr
 	Name scanner.Token
 }
@@ -60,6 +75,7 @@ func (n *Get) Accept(v ExprVisitor) any {
 }
 
 type Grouping struct {
+	Node
 	Expression Expr
 }
 
@@ -68,6 +84,7 @@ func (n *Grouping) Accept(v ExprVisitor) any {
 }
 
 type Literal struct {
+	Node
 	Value any
 }
 
@@ -76,6 +93,7 @@ func (n *Literal) Accept(v ExprVisitor) any {
 }
 
 type Logical struct {
+	Node
 	Left Expr
 	Operator scanner.Token
 	Right Expr
@@ -86,6 +104,7 @@ func (n *Logical) Accept(v ExprVisitor) any {
 }
 
 type Set struct {
+	Node
 	Object Expr
 	Name scanner.Token
 	Value Expr
@@ -96,6 +115,7 @@ func (n *Set) Accept(v ExprVisitor) any {
 }
 
 type Super struct {
+	Node
 	Keyword scanner.Token
 	Method scanner.Token
 }
@@ -105,6 +125,7 @@ func (n *Super) Accept(v ExprVisitor) any {
 }
 
 type This struct {
+	Node
 	Keyword scanner.Token
 }
 
@@ -113,6 +134,7 @@ func (n *This) Accept(v ExprVisitor) any {
 }
 
 type Unary struct {
+	Node
 	Operator scanner.Token
 	Right Expr
 }
@@ -122,6 +144,7 @@ func (n *Unary) Accept(v ExprVisitor) any {
 }
 
 type Variable struct {
+	Node
 	Name scanner.Token
 }
 
@@ -0,0 +1,69 @@
+package ast
+
+// Inspect walks the tree rooted at node, which is an Expr or a Stmt, in
+// source order. It calls f for each node; if f returns false, the node's
+// children are skipped. Nil children are not visited.
+func Inspect(node any, f func(node any) bool) {
+	switch node.(type) {
+	case Expr, Stmt:
+		if !f(node) {
+			return
+		}
+	default:
+		return
+	}
+
+	switch n := node.(type) {
+	case *Assign:
+		Inspect(n.Value, f)
+	case *Binary:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *Call:
+		Inspect(n.Callee, f)
+		for _, arg := range n.Arguments {
+			Inspect(arg, f)
+		}
+	case *Get:
+		Inspect(n.Object, f)
+	case *Grouping:
+		Inspect(n.Expression, f)
+	case *Logical:
+		Inspect(n.Left, f)
+		Inspect(n.Right, f)
+	case *Set:
+		Inspect(n.Object, f)
+		Inspect(n.Value, f)
+	case *Unary:
+		Inspect(n.Right, f)
+
+	case *Block:
+		for _, stmt := range n.Statements {
+			Inspect(stmt, f)
+		}
+	case *Class:
+		Inspect(n.Superclass, f)
+		for _, method := range n.Methods {
+			Inspect(method, f)
+		}
+	case *Expression:
+		Inspect(n.Expression, f)
+	case *Function:
+		for _, stmt := range n.Body {
+			Inspect(stmt, f)
+		}
+	case *If:
+		Inspect(n.Condition, f)
+		Inspect(n.ThenBranch, f)
+		Inspect(n.ElseBranch, f)
+	case *Print:
+		Inspect(n.Expression, f)
+	case *Return:
+		Inspect(n.Value, f)
+	case *Var:
+		Inspect(n.Initializer, f)
+	case *While:
+		Inspect(n.Condition, f)
+		Inspect(n.Body, f)
+	}
+}
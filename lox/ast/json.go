@@ -0,0 +1,330 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"example.com/golox/lox/scanner"
+)
+
+// JSONVersion is the version of the document written by EncodeJSON. It
+// only changes when a document stops meaning what it used to; new fields
+// may be added without one.
+const JSONVersion = 1
+
+// EncodeJSON writes a program as a JSON document:
+//
+//	{
+//	  "version": 1,
+//	  "statements": [
+//	    {
+//	      "type": "Print",
+//	      "span": {"start": {"offset": 0, "line": 1, "column": 1}, "end": {...}},
+//	      "expression": {
+//	        "type": "Literal",
+//	        "span": {...},
+//	        "value": 3
+//	      }
+//	    }
+//	  ]
+//	}
+//
+// Every node is an object whose "type" is the name of its Go type, such
+// as "Binary" or "While", followed by "span" when the node came from
+// source, and then its fields in the order they are declared, named as
+// in tool/generate_ast.go: "left", "operator", "thenBranch" and so on. A
+// child node is an object, a list of them an array, and a missing child,
+// such as an if without an else, is null. An empty list may be null too.
+//
+// A token is an object with its "type", such as "IDENTIFIER", "lexeme",
+// "line", "column", "offset" and "length", and, for NUMBER and STRING
+// tokens, the "literal" value. A Literal's "value" is null, a boolean, a
+// number or a string.
+func EncodeJSON(statements []Stmt) ([]byte, error) {
+	nodes := make([]any, len(statements))
+	for i, stmt := range statements {
+		nodes[i] = encodeNode(reflect.ValueOf(stmt))
+	}
+	return json.MarshalIndent(object{
+		{"version", JSONVersion},
+		{"statements", nodes},
+	}, "", "  ")
+}
+
+// DecodeJSON reads a document written by EncodeJSON back into a
+// program. The statements are new nodes, ready to be resolved and run.
+func DecodeJSON(data []byte) ([]Stmt, error) {
+	var doc struct {
+		Version    int
+		Statements []json.RawMessage
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("ast: %w", err)
+	}
+	if doc.Version != JSONVersion {
+		return nil, fmt.Errorf("ast: unsupported document version %d", doc.Version)
+	}
+
+	statements := make([]Stmt, len(doc.Statements))
+	for i, raw := range doc.Statements {
+		v, err := decodeField(stmtType, raw)
+		if err != nil {
+			return nil, fmt.Errorf("ast: statement %d: %w", i, err)
+		}
+		stmt, ok := v.Interface().(Stmt)
+		if !ok {
+			return nil, fmt.Errorf("ast: statement %d is null", i)
+		}
+		statements[i] = stmt
+	}
+	return statements, nil
+}
+
+// nodeTypes are the node types a document may name, by name.
+var nodeTypes = map[string]reflect.Type{}
+
+func init() {
+	for _, node := range []any{
+		Assign{}, Binary{}, Call{}, Error{}, Get{}, Grouping{}, Literal{},
+		Logical{}, Set{}, Super{}, This{}, Unary{}, Variable{},
+		Bad{}, Block{}, Class{}, Expression{}, Function{}, Print{}, If{}, Return{},
+		Var{}, While{},
+	} {
+		t := reflect.TypeOf(node)
+		nodeTypes[t.Name()] = t
+	}
+}
+
+var (
+	exprType  = reflect.TypeOf((*Expr)(nil)).Elem()
+	stmtType  = reflect.TypeOf((*Stmt)(nil)).Elem()
+	tokenType = reflect.TypeOf(scanner.Token{})
+	nodeType  = reflect.TypeOf(Node{})
+)
+
+// object is a JSON object that keeps its keys in order.
+type object []member
+
+type member struct {
+	key   string
+	value any
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, m := range o {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(m.key)
+		value, err := json.Marshal(m.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// fieldName is the name of a field in a document: the Go name with a
+// lower-case first letter.
+func fieldName(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// encodeNode encodes a pointer to a node, or a nil one as null.
+func encodeNode(v reflect.Value) any {
+	if !v.IsValid() || v.IsNil() {
+		return nil
+	}
+	if v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	s := v.Elem()
+	obj := object{{"type", s.Type().Name()}}
+	for i := 0; i < s.NumField(); i++ {
+		field, value := s.Type().Field(i), s.Field(i)
+		if field.Type == nodeType {
+			if span := value.Interface().(Node).Span; span.IsValid() {
+				obj = append(obj, member{"span", encodeSpan(span)})
+			}
+			continue
+		}
+		obj = append(obj, member{fieldName(field.Name), encodeField(value)})
+	}
+	return obj
+}
+
+func encodeField(v reflect.Value) any {
+	switch {
+	case v.Type() == tokenType:
+		return encodeToken(v.Interface().(scanner.Token))
+	case v.Kind() == reflect.Slice:
+		if v.IsNil() {
+			return nil
+		}
+		items := make([]any, v.Len())
+		for i := range items {
+			items[i] = encodeField(v.Index(i))
+		}
+		return items
+	case v.Type() == exprType || v.Type() == stmtType || v.Kind() == reflect.Pointer:
+		return encodeNode(v)
+	}
+	return v.Interface() // a Literal's value
+}
+
+func encodeToken(token scanner.Token) object {
+	obj := object{
+		{"type", token.Type.String()},
+		{"lexeme", token.Lexeme},
+		{"line", token.Line},
+		{"column", token.Column},
+		{"offset", token.Offset},
+		{"length", token.Length},
+	}
+	if token.Literal != nil {
+		obj = append(obj, member{"literal", token.Literal})
+	}
+	return obj
+}
+
+func encodeSpan(span Span) object {
+	pos := func(p Pos) object {
+		return object{{"offset", p.Offset}, {"line", p.Line}, {"column", p.Column}}
+	}
+	return object{{"start", pos(span.Start)}, {"end", pos(span.End)}}
+}
+
+// decodeField decodes raw into a value of type t, one of the types a
+// node's field can have.
+func decodeField(t reflect.Type, raw json.RawMessage) (reflect.Value, error) {
+	switch {
+	case t == tokenType:
+		token, err := decodeToken(raw)
+		return reflect.ValueOf(token), err
+	case t.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(raw, &items); err != nil {
+			return reflect.Value{}, err
+		}
+		if items == nil {
+			return reflect.Zero(t), nil
+		}
+		slice := reflect.MakeSlice(t, len(items), len(items))
+		for i, item := range items {
+			v, err := decodeField(t.Elem(), item)
+			if err != nil {
+				return reflect.Value{}, fmt.Errorf("item %d: %w", i, err)
+			}
+			slice.Index(i).Set(v)
+		}
+		return slice, nil
+	case t == exprType || t == stmtType || t.Kind() == reflect.Pointer:
+		return decodeNode(t, raw)
+	}
+
+	// A Literal's value.
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return reflect.Value{}, err
+	}
+	switch value.(type) {
+	case nil, bool, float64, string:
+	default:
+		return reflect.Value{}, fmt.Errorf("literal value %s is not null, a boolean, a number or a string", raw)
+	}
+	v := reflect.New(t).Elem()
+	if value != nil {
+		v.Set(reflect.ValueOf(value))
+	}
+	return v, nil
+}
+
+// decodeNode decodes a node that is to be stored as a t: Expr, Stmt or
+// a pointer to a particular node type.
+func decodeNode(t reflect.Type, raw json.RawMessage) (reflect.Value, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &fields); err != nil {
+		return reflect.Value{}, err
+	}
+	if fields == nil {
+		return reflect.Zero(t), nil
+	}
+
+	var name string
+	if err := json.Unmarshal(fields["type"], &name); err != nil {
+		return reflect.Value{}, fmt.Errorf("node has no type")
+	}
+	nt, ok := nodeTypes[name]
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("unknown node type %q", name)
+	}
+	node := reflect.New(nt)
+	if !node.Type().AssignableTo(t) {
+		want := t.Name()
+		if t.Kind() == reflect.Pointer {
+			want = t.Elem().Name()
+		}
+		return reflect.Value{}, fmt.Errorf("%s is not a %s", name, want)
+	}
+
+	s := node.Elem()
+	for i := 0; i < s.NumField(); i++ {
+		field := nt.Field(i)
+		if field.Type == nodeType {
+			if span, ok := fields["span"]; ok {
+				if err := json.Unmarshal(span, &s.Field(i).Addr().Interface().(*Node).Span); err != nil {
+					return reflect.Value{}, fmt.Errorf("%s.span: %w", name, err)
+				}
+			}
+			continue
+		}
+		key := fieldName(field.Name)
+		raw, ok := fields[key]
+		if !ok {
+			return reflect.Value{}, fmt.Errorf("%s has no %s", name, key)
+		}
+		v, err := decodeField(field.Type, raw)
+		if err != nil {
+			return reflect.Value{}, fmt.Errorf("%s.%s: %w", name, key, err)
+		}
+		s.Field(i).Set(v)
+	}
+	return node, nil
+}
+
+func decodeToken(raw json.RawMessage) (scanner.Token, error) {
+	var doc struct {
+		Type    string
+		Lexeme  string
+		Line    int
+		Column  int
+		Offset  int
+		Length  int
+		Literal any
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return scanner.Token{}, err
+	}
+	tt, ok := scanner.TokenTypeOf(doc.Type)
+	if !ok {
+		return scanner.Token{}, fmt.Errorf("unknown token type %q", doc.Type)
+	}
+	return scanner.Token{
+		Type:    tt,
+		Lexeme:  doc.Lexeme,
+		Literal: doc.Literal,
+		Line:    doc.Line,
+		Column:  doc.Column,
+		Offset:  doc.Offset,
+		Length:  doc.Length,
+	}, nil
+}
@@ -0,0 +1,150 @@
+package ast_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+func parse(t *testing.T, src string) []ast.Stmt {
+	t.Helper()
+	var statements []ast.Stmt
+	if diagnostics := shared.Collect(func() {
+		statements = parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+	}); len(diagnostics) > 0 {
+		t.Fatalf("syntax errors: %v", shared.Errors(diagnostics))
+	}
+	return statements
+}
+
+// execute resolves and runs a program in a new interpreter and returns
+// what it printed, or the resolve errors.
+func execute(statements []ast.Stmt) string {
+	in := interpreter.NewInterpreter()
+	var out bytes.Buffer
+	in.SetOutput(&out, &out)
+	if diagnostics := shared.Collect(func() {
+		resolver.NewResolver(in).Resolve(statements)
+	}); len(diagnostics) > 0 {
+		return shared.Errors(diagnostics).Error()
+	}
+	in.Interpret(statements)
+	return out.String()
+}
+
+func roundTrip(t *testing.T, statements []ast.Stmt) []ast.Stmt {
+	t.Helper()
+	data, err := ast.EncodeJSON(statements)
+	if err != nil {
+		t.Fatalf("EncodeJSON: %v", err)
+	}
+	decoded, err := ast.DecodeJSON(data)
+	if err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	return decoded
+}
+
+func TestJSONRoundTripRunsTheExamples(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/*.lox")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, path := range paths {
+		src, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := execute(parse(t, string(src)))
+		if got := execute(roundTrip(t, parse(t, string(src)))); got != want {
+			t.Errorf("%s: output changed after a round trip\ngot:\n%s\nwant:\n%s", path, got, want)
+		}
+	}
+}
+
+func TestJSONKeepsEveryField(t *testing.T) {
+	src := `class A < B { init(x) { this.x = -x; } m() { return super.m(); } }
+for (var i = 0; i < 2 and !false or nil; i = i + 1) { print (i) * 1.5 + "s"; }
+if (A) f(a.b); else while (true) return;
+`
+	statements := parse(t, src)
+	if decoded := roundTrip(t, statements); !reflect.DeepEqual(decoded, statements) {
+		t.Errorf("round trip changed the tree\ngot:  %s\nwant: %s",
+			(&ast.AstPrinter{}).PrintProgram(decoded), (&ast.AstPrinter{}).PrintProgram(statements))
+	}
+
+	data, _ := ast.EncodeJSON(parse(t, "print a;"))
+	for _, want := range []string{`"type": "Print"`, `"type": "IDENTIFIER"`, `"lexeme": "a"`, `"line": 1`, `"column": 7`} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("expected %s in\n%s", want, data)
+		}
+	}
+}
+
+func TestPrintersCoverAParsedScript(t *testing.T) {
+	statements := parse(t, `class A < B { init(x) { this.x = -x; } m() { return super.m(); } }
+var v; v = "s";
+for (var i = 0; i < 2 and !false or nil; i = i + 1) { print (i) * 1.5; }
+if (A) f(a.b); else while (true) return;
+`)
+	want := `(class A < B (fun init (x) (; (set x this (- x)))) (fun m () (return (call (super m)))))
+(var v)
+(; (assign v s))
+(block (var i 0) (while (or (and (< i 2) (! false)) nil) (block (block (print (* (group i) 1.5))) (; (assign i (+ i 1))))))
+(if A (; (call f (get b a))) (while true (return)))
+`
+	if got := (&ast.AstPrinter{}).PrintProgram(statements); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	// Every node of the tree is a node of the graph.
+	nodes := 1 // the program
+	for _, stmt := range statements {
+		ast.Inspect(stmt, func(any) bool { nodes++; return true })
+	}
+	if got := strings.Count(ast.DOT(statements), "[label=\""); got != 2*nodes-1 {
+		t.Errorf("expected %d nodes and %d edges, got %d labels", nodes, nodes-1, got)
+	}
+}
+
+func TestJSONNamesEveryNodeType(t *testing.T) {
+	for _, visitor := range []reflect.Type{
+		reflect.TypeOf((*ast.ExprVisitor)(nil)).Elem(),
+		reflect.TypeOf((*ast.StmtVisitor)(nil)).Elem(),
+	} {
+		for i := 0; i < visitor.NumMethod(); i++ {
+			name := strings.TrimPrefix(visitor.Method(i).Name, "Visit")
+			name = strings.TrimSuffix(strings.TrimSuffix(name, "Expr"), "Stmt")
+			doc := `{"version": 1, "statements": [{"type": "` + name + `"}]}`
+			if _, err := ast.DecodeJSON([]byte(doc)); err != nil && strings.Contains(err.Error(), "unknown node type") {
+				t.Errorf("%s cannot be read back: %v", name, err)
+			}
+		}
+	}
+}
+
+func TestDecodeJSONRejectsBadDocuments(t *testing.T) {
+	tests := map[string]string{
+		`{"version": 2, "statements": []}`:                                                                   "unsupported document version 2",
+		`{"version": 1, "statements": [{"type": "Nope"}]}`:                                                   `unknown node type "Nope"`,
+		`{"version": 1, "statements": [{"type": "Variable"}]}`:                                               "Variable is not a Stmt",
+		`{"version": 1, "statements": [{"type": "Print"}]}`:                                                  "Print has no expression",
+		`{"version": 1, "statements": [null]}`:                                                               "statement 0 is null",
+		`{"version": 1, "statements": [{"type": "Print", "expression": {"type": "Literal", "value": [1]}}]}`: "is not null, a boolean, a number or a string",
+	}
+	for doc, want := range tests {
+		if _, err := ast.DecodeJSON([]byte(doc)); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%s: expected an error containing %q, got %v", doc, want, err)
+		}
+	}
+}
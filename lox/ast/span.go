@@ -0,0 +1,77 @@
+package ast
+
+import (
+	"strings"
+
+	"example.com/golox/lox/scanner"
+)
+
+// Pos is a place in the source: a byte offset, and the line and column,
+// both from 1, of that byte.
+type Pos struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+// Span is the source a node was parsed from. Start is its first byte and
+// End is just past its last.
+type Span struct {
+	Start Pos
+	End   Pos
+}
+
+// IsValid reports whether s came from source. Nodes that the parser or
+// the optimizer synthesize have no span.
+func (s Span) IsValid() bool {
+	return s.Start.Line > 0
+}
+
+// Len is the number of bytes s covers.
+func (s Span) Len() int {
+	return s.End.Offset - s.Start.Offset
+}
+
+// TokenSpan is the span of a single token.
+func TokenSpan(token scanner.Token) Span {
+	if token.Line == 0 {
+		return Span{}
+	}
+	start := Pos{Offset: token.Offset, Line: token.Line, Column: token.Column}
+	end := Pos{Offset: token.Offset + token.Length, Line: token.Line, Column: token.Column + token.Length}
+
+	// Only a string can span lines, and its Line is where it ends.
+	if breaks := strings.Count(token.Lexeme, "\n"); breaks > 0 {
+		start.Line -= breaks
+		end.Column = len(token.Lexeme) - strings.LastIndex(token.Lexeme, "\n")
+	}
+	return Span{Start: start, End: end}
+}
+
+// Join returns the smallest span that covers both a and b. An invalid
+// span is ignored.
+func Join(a, b Span) Span {
+	switch {
+	case !a.IsValid():
+		return b
+	case !b.IsValid():
+		return a
+	}
+	if b.Start.Offset < a.Start.Offset {
+		a.Start = b.Start
+	}
+	if b.End.Offset > a.End.Offset {
+		a.End = b.End
+	}
+	return a
+}
+
+// Node is embedded in every expression and statement.
+type Node struct {
+	Span Span
+}
+
+// Extent returns the source the node covers.
+func (n *Node) Extent() Span {
+	return n.Span
+}
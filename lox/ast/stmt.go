@@ -4,9 +4,11 @@ import "example.com/golox/lox/scanner"
 
 type Stmt interface {
 	Accept(v StmtVisitor) any
+	Extent() Span
 }
 
 type StmtVisitor interface {
+	VisitBadStmt(*Bad) any
 	VisitBlockStmt(*Block) any
 	VisitClassStmt(*Class) any
 	VisitExpressionStmt(*Expression) any
@@ -18,7 +20,17 @@ type StmtVisitor interface {
 	VisitWhileStmt(*While) any
 }
 
+type Bad struct {
+	Node
+	Tokens []scanner.Token
+}
+
+func (n *Bad) Accept(v StmtVisitor) any {
+	return v.VisitBadStmt(n)
+}
+
 type Block struct {
+	Node
 	Statements []Stmt
 }
 
@@ -27,6 +39,7 @@ func (n *Block) Accept(v StmtVisitor) any {
 }
 
 type Class struct {
+	Node
 	Name scanner.Token
 	Superclass Expr
 	Methods []*Function
@@ -37,6 +50,7 @@ func (n *Class) Accept(v StmtVisitor) any {
 }
 
 type Expression struct {
+	Node
 	Expression Expr
 }
 
@@ -45,6 +59,7 @@ func (n *Expression) Accept(v StmtVisitor) any {
 }
 
 type Function struct {
+	Node
 	Name scanner.Token
 	Params []scanner.Token
 	Body []Stmt
@@ -55,6 +70,7 @@ func (n *Function) Accept(v StmtVisitor) any {
 }
 
 type Print struct {
+	Node
 	Expression Expr
 }
 
@@ -63,6 +79,7 @@ func (n *Print) Accept(v StmtVisitor) any {
 }
 
 type If struct {
+	Node
 	Condition Expr
 	ThenBranch Stmt
 	ElseBranch Stmt
@@ -73,6 +90,7 @@ func (n *If) Accept(v StmtVisitor) any {
 }
 
 type Return struct {
+	Node
 	Keyword scanner.Token
 	Value Expr
 }
@@ -82,6 +100,7 @@ func (n *Return) Accept(v StmtVisitor) any {
 }
 
 type Var struct {
+	Node
 	Name scanner.Token
 	Initializer Expr
 }
@@ -91,6 +110,7 @@ func (n *Var) Accept(v StmtVisitor) any {
 }
 
 type While struct {
+	Node
 	Condition Expr
 	Body Stmt
 }
@@ -0,0 +1,154 @@
+// Package codes lists every error glox reports. Each has an ID, such as
+// L0107, that never changes once released, so tools can match on it
+// instead of on the message.
+//
+// IDs are grouped by the phase that reports them: L00xx scanning, L01xx
+// parsing, L02xx resolving and L03xx running. Messages show the ID, and
+// "glox explain L0107" prints the long form of an error, with an example
+// of the mistake and its fix.
+package codes
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Phase is the stage of glox that reports an error.
+type Phase string
+
+const (
+	Scan    Phase = "scan"
+	Parse   Phase = "parse"
+	Resolve Phase = "resolve"
+	Runtime Phase = "runtime"
+)
+
+// Code is one kind of error.
+type Code struct {
+	ID    string
+	Phase Phase
+	// Message is a format string for the message; most take no arguments.
+	Message string
+}
+
+// Format returns the message with args filled in.
+func (c Code) Format(args ...any) string {
+	if len(args) == 0 {
+		return c.Message
+	}
+	return fmt.Sprintf(c.Message, args...)
+}
+
+// Label introduces errors of this kind in messages, as in
+// "Error[L0107]: Expect ';' after value.". It is just "Error" for the
+// zero Code.
+func (c Code) Label() string {
+	if c.ID == "" {
+		return "Error"
+	}
+	return "Error[" + c.ID + "]"
+}
+
+var registry = map[string]Code{}
+
+func define(id string, phase Phase, message string) Code {
+	if _, ok := registry[id]; ok {
+		panic("codes: duplicate ID " + id)
+	}
+	code := Code{ID: id, Phase: phase, Message: message}
+	registry[id] = code
+	return code
+}
+
+// Lookup returns the code with the given ID.
+func Lookup(id string) (Code, bool) {
+	code, ok := registry[id]
+	return code, ok
+}
+
+// All returns every code, ordered by ID.
+func All() []Code {
+	all := make([]Code, 0, len(registry))
+	for _, code := range registry {
+		all = append(all, code)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].ID < all[j].ID })
+	return all
+}
+
+// Scanning.
+var (
+	UnexpectedCharacter = define("L0001", Scan, "Unexpected character.")
+	UnterminatedString  = define("L0002", Scan, "Unterminated string.")
+	InvalidNumber       = define("L0003", Scan, "Invalid number literal: %s")
+)
+
+// Parsing.
+var (
+	ExpectExpression            = define("L0101", Parse, "Expect expression.")
+	ExpectEndOfExpression       = define("L0102", Parse, "Expect end of expression.")
+	InvalidAssignmentTarget     = define("L0103", Parse, "Invalid assignment target.")
+	ExpectParenAfterExpression  = define("L0104", Parse, "Expect ')' after expression.")
+	ExpectParenAfterArguments   = define("L0105", Parse, "Expect ')' after arguments.")
+	TooManyArguments            = define("L0106", Parse, "Can't have more than 255 arguments.")
+	ExpectSemicolonAfterValue   = define("L0107", Parse, "Expect ';' after value.")
+	ExpectSemicolonAfterExpr    = define("L0108", Parse, "Expect ';' after expression.")
+	ExpectSemicolonAfterVar     = define("L0109", Parse, "Expect ';' after variable declaration.")
+	ExpectVariableName          = define("L0110", Parse, "Expect variable name.")
+	ExpectFunctionName          = define("L0111", Parse, "Expect %s name.")
+	ExpectParenAfterName        = define("L0112", Parse, "Expect '(' after %s name.")
+	ExpectParameterName         = define("L0113", Parse, "Expect parameter name.")
+	TooManyParameters           = define("L0114", Parse, "Can't have more than 255 parameters.")
+	ExpectParenAfterParameters  = define("L0115", Parse, "Expect ')' after parameters.")
+	ExpectBraceBeforeBody       = define("L0116", Parse, "Expect '{' before %s body.")
+	ExpectBraceAfterBlock       = define("L0117", Parse, "Expect '}' after block.")
+	ExpectParenAfterIf          = define("L0118", Parse, "Expect '(' after 'if'.")
+	ExpectParenAfterIfCondition = define("L0119", Parse, "Expect ')' after if condition.")
+	ExpectParenAfterWhile       = define("L0120", Parse, "Expect '(' after 'while'.")
+	ExpectParenAfterCondition   = define("L0121", Parse, "Expect ')' after condition.")
+	ExpectParenAfterFor         = define("L0122", Parse, "Expect '(' after 'for'.")
+	ExpectSemicolonAfterLoop    = define("L0123", Parse, "Expect ';' after loop condition.")
+	ExpectParenAfterForClauses  = define("L0124", Parse, "Expect ')' after for clauses.")
+	ExpectSemicolonAfterReturn  = define("L0125", Parse, "Expect ';' after return value.")
+	ExpectClassName             = define("L0126", Parse, "Expect class name.")
+	ExpectSuperclassName        = define("L0127", Parse, "Expect superclass name.")
+	ExpectBraceBeforeClassBody  = define("L0128", Parse, "Expect '{' before class body.")
+	ExpectBraceAfterClassBody   = define("L0129", Parse, "Expect '}' after class body.")
+	ExpectPropertyName          = define("L0130", Parse, "Expect property name after '.'.")
+	ExpectDotAfterSuper         = define("L0131", Parse, "Expect '.' after 'super'.")
+	ExpectSuperclassMethod      = define("L0132", Parse, "Expect superclass method name.")
+)
+
+// Resolving.
+var (
+	ReadInOwnInitializer = define("L0201", Resolve, "Can't read local variable in its own initializer.")
+	AlreadyDeclared      = define("L0202", Resolve, "Already a variable with this name in this scope.")
+	TopLevelReturn       = define("L0203", Resolve, "Can't return from top-level code.")
+	ReturnFromInit       = define("L0204", Resolve, "Can't return a value from an initializer.")
+	InheritFromSelf      = define("L0205", Resolve, "A class can't inherit from itself.")
+	ThisOutsideClass     = define("L0206", Resolve, "Can't use 'this' outside of a class.")
+	SuperOutsideClass    = define("L0207", Resolve, "Can't use 'super' outside of a class.")
+	SuperWithoutSuper    = define("L0208", Resolve, "Can't use 'super' in a class with no superclass.")
+)
+
+// Running.
+var (
+	OperandMustBeNumber  = define("L0301", Runtime, "Operand must be a number.")
+	OperandsMustBeNumber = define("L0302", Runtime, "Operands must be numbers.")
+	RightMustBeNumber    = define("L0303", Runtime, "Right operand must be a number.")
+	RightMustBeString    = define("L0304", Runtime, "Right operand must be a string.")
+	BadPlusOperands      = define("L0305", Runtime, "Operands must be two numbers or two strings.")
+	UndefinedVariable    = define("L0306", Runtime, "Undefined variable '%s'.")
+	UndefinedProperty    = define("L0307", Runtime, "Undefined property '%s'.")
+	OnlyInstancesProps   = define("L0308", Runtime, "Only instances have properties.")
+	OnlyInstancesFields  = define("L0309", Runtime, "Only instances have fields.")
+	NotCallable          = define("L0310", Runtime, "Can only call functions and classes.")
+	WrongArity           = define("L0311", Runtime, "Expected %d arguments but got %d.")
+	SuperclassNotClass   = define("L0312", Runtime, "Superclass must be a class.")
+	StackOverflow        = define("L0313", Runtime, "Stack overflow.")
+	Interrupted          = define("L0314", Runtime, "Interrupted.")
+	// NativeFailure is raised by native functions, such as the test
+	// assertions, with a message of their own.
+	NativeFailure = define("L0315", Runtime, "%s")
+	Internal      = define("L0399", Runtime, "Internal error: %s")
+)
@@ -0,0 +1,31 @@
+package codes
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestIDsMatchTheirPhase(t *testing.T) {
+	prefixes := map[Phase]string{Scan: "L00", Parse: "L01", Resolve: "L02", Runtime: "L03"}
+	for _, code := range All() {
+		if !strings.HasPrefix(code.ID, prefixes[code.Phase]) || len(code.ID) != 5 {
+			t.Errorf("%s (%s) does not fit the %s range", code.ID, code.Message, code.Phase)
+		}
+	}
+}
+
+func TestLookupAndFormat(t *testing.T) {
+	code, ok := Lookup("L0311")
+	if !ok || code != WrongArity {
+		t.Fatalf("expected L0311 to be WrongArity, got %+v", code)
+	}
+	if got := code.Format(1, 2); got != "Expected 1 arguments but got 2." {
+		t.Errorf("unexpected message %q", got)
+	}
+	if got := ExpectSemicolonAfterValue.Format(); got != "Expect ';' after value." {
+		t.Errorf("unexpected message %q", got)
+	}
+	if _, ok := Lookup("L9999"); ok {
+		t.Error("expected an unknown ID to be missing")
+	}
+}
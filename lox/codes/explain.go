@@ -0,0 +1,318 @@
+package codes
+
+// Explanation is the long form of a code, printed by "glox explain".
+type Explanation struct {
+	// Text says what the error means and how it usually comes about.
+	Text string
+	// Wrong is a script that reports the error, and Right the same script
+	// fixed. Either may be empty when no short script shows the error.
+	Wrong, Right string
+}
+
+// Explain returns the long form of c.
+func (c Code) Explain() Explanation {
+	return explanations[c.ID]
+}
+
+var explanations = map[string]Explanation{
+	// Scanning.
+	UnexpectedCharacter.ID: {
+		Text:  "The scanner found a character that cannot start any token. Outside of strings and comments, Lox only uses letters, digits, underscores, whitespace and the punctuation of its operators. Characters such as '@', '#', '%' and '$' only make sense inside a string.",
+		Wrong: `var tag = @name;`,
+		Right: `var tag = "@name";`,
+	},
+	UnterminatedString.ID: {
+		Text:  "A string literal has no closing double quote before the end of the file. Strings may span several lines, so the error is reported where the file ends rather than where the quote is missing.",
+		Wrong: `print "hello;`,
+		Right: `print "hello";`,
+	},
+	InvalidNumber.ID: {
+		Text:  "A number literal is too large to be stored. Lox numbers are 64-bit floating point values, so no literal can be larger than about 1.8e308; a literal with more than 309 digits before the decimal point is rejected.",
+		Wrong: `print 1000000000...000; // 310 digits`,
+		Right: `print 1000000000...000; // 300 digits`,
+	},
+
+	// Parsing.
+	ExpectExpression.ID: {
+		Text:  "The parser needed a value, such as a number, a string, a variable or a call, but found something else. This usually means an operand is missing, or an operator or comma is left over.",
+		Wrong: `print 1 + ;`,
+		Right: `print 1 + 2;`,
+	},
+	ExpectEndOfExpression.ID: {
+		Text:  "The REPL prints the value of an expression typed without a trailing semicolon, but there was more input after the expression. End statements with a semicolon, or enter one expression at a time.",
+		Wrong: `1 + 2 3`,
+		Right: `1 + 2`,
+	},
+	InvalidAssignmentTarget.ID: {
+		Text:  "The left side of '=' must be a variable or a property, such as 'a' or 'point.x'. Other expressions, like sums or calls, produce values that cannot be assigned to.",
+		Wrong: "var a = 1;\na + 1 = 3;",
+		Right: "var a = 1;\na = a + 2;",
+	},
+	ExpectParenAfterExpression.ID: {
+		Text:  "A parenthesized expression is missing its closing ')'. Count the parentheses: every '(' needs a matching ')'.",
+		Wrong: `print (1 + 2;`,
+		Right: `print (1 + 2);`,
+	},
+	ExpectParenAfterArguments.ID: {
+		Text:  "The arguments of a call must be separated by commas and end with ')'. The parser read an argument and then found neither.",
+		Wrong: "fun add(a, b) { return a + b; }\nprint add(1, 2;",
+		Right: "fun add(a, b) { return a + b; }\nprint add(1, 2);",
+	},
+	TooManyArguments.ID: {
+		Text:  "A call can pass at most 255 arguments. Group related values into an instance and pass that instead.",
+		Wrong: `f(a1, a2, a3, ..., a256);`,
+		Right: `f(record);`,
+	},
+	ExpectSemicolonAfterValue.ID: {
+		Text:  "A print statement must end with ';'. The parser read the value to print and then found something else, usually because the semicolon was left off.",
+		Wrong: `print "hello"`,
+		Right: `print "hello";`,
+	},
+	ExpectSemicolonAfterExpr.ID: {
+		Text:  "An expression statement, such as an assignment or a call, must end with ';'. The error is reported at the token after the expression, which is often on the next line.",
+		Wrong: "var a = 1;\na = 2\nprint a;",
+		Right: "var a = 1;\na = 2;\nprint a;",
+	},
+	ExpectSemicolonAfterVar.ID: {
+		Text:  "A variable declaration must end with ';', after the initializer if there is one.",
+		Wrong: "var a = 1\nprint a;",
+		Right: "var a = 1;\nprint a;",
+	},
+	ExpectVariableName.ID: {
+		Text:  "'var' must be followed by the name of the variable it declares. A name starts with a letter or underscore and cannot be a keyword.",
+		Wrong: `var = 5;`,
+		Right: `var count = 5;`,
+	},
+	ExpectFunctionName.ID: {
+		Text:  "'fun' must be followed by the name of the function it declares, and a method declaration must start with its name. Lox has no anonymous functions.",
+		Wrong: "fun (x) { return x * 2; }",
+		Right: "fun double(x) { return x * 2; }",
+	},
+	ExpectParenAfterName.ID: {
+		Text:  "The name of a function or method must be followed by its parameter list in parentheses, even when it takes no parameters.",
+		Wrong: `fun greet { print "hi"; }`,
+		Right: `fun greet() { print "hi"; }`,
+	},
+	ExpectParameterName.ID: {
+		Text:  "Each parameter of a function or method must be a name. Parameters have no default values, so literals and other expressions are not allowed in the parameter list.",
+		Wrong: "fun add(a, 1) { return a + 1; }",
+		Right: "fun add(a, b) { return a + b; }",
+	},
+	TooManyParameters.ID: {
+		Text:  "A function or method can take at most 255 parameters. Group related values into an instance and take that instead.",
+		Wrong: `fun f(a1, a2, a3, ..., a256) {}`,
+		Right: `fun f(record) {}`,
+	},
+	ExpectParenAfterParameters.ID: {
+		Text:  "Parameters must be separated by commas and the list must end with ')'.",
+		Wrong: "fun add(a b) { return a + b; }",
+		Right: "fun add(a, b) { return a + b; }",
+	},
+	ExpectBraceBeforeBody.ID: {
+		Text:  "The body of a function or method must be a block in braces, even when it is a single statement.",
+		Wrong: "fun add(a, b) return a + b;",
+		Right: "fun add(a, b) { return a + b; }",
+	},
+	ExpectBraceAfterBlock.ID: {
+		Text:  "A block is missing its closing '}'. The parser reads statements until it finds one, so the error is often reported at the end of the file, far from the block that is not closed.",
+		Wrong: "if (true) {\n  print \"yes\";",
+		Right: "if (true) {\n  print \"yes\";\n}",
+	},
+	ExpectParenAfterIf.ID: {
+		Text:  "The condition of an if statement must be in parentheses.",
+		Wrong: "var x = 2;\nif x > 1 print \"big\";",
+		Right: "var x = 2;\nif (x > 1) print \"big\";",
+	},
+	ExpectParenAfterIfCondition.ID: {
+		Text:  "The condition of an if statement is missing its closing ')'.",
+		Wrong: "var x = 2;\nif (x > 1 print \"big\";",
+		Right: "var x = 2;\nif (x > 1) print \"big\";",
+	},
+	ExpectParenAfterWhile.ID: {
+		Text:  "The condition of a while loop must be in parentheses.",
+		Wrong: "var i = 0;\nwhile i < 3 { i = i + 1; }",
+		Right: "var i = 0;\nwhile (i < 3) { i = i + 1; }",
+	},
+	ExpectParenAfterCondition.ID: {
+		Text:  "The condition of a while loop is missing its closing ')'.",
+		Wrong: "var i = 0;\nwhile (i < 3 { i = i + 1; }",
+		Right: "var i = 0;\nwhile (i < 3) { i = i + 1; }",
+	},
+	ExpectParenAfterFor.ID: {
+		Text:  "The clauses of a for loop must be in parentheses.",
+		Wrong: "for var i = 0; i < 3; i = i + 1) print i;",
+		Right: "for (var i = 0; i < 3; i = i + 1) print i;",
+	},
+	ExpectSemicolonAfterLoop.ID: {
+		Text:  "A for loop has three clauses, separated by semicolons: the initializer, the condition and the increment. Any of them may be empty, but both semicolons are required.",
+		Wrong: "for (var i = 0; i < 3) print i;",
+		Right: "for (var i = 0; i < 3; i = i + 1) print i;",
+	},
+	ExpectParenAfterForClauses.ID: {
+		Text:  "The clauses of a for loop are missing their closing ')', after the increment.",
+		Wrong: "for (var i = 0; i < 3; i = i + 1 print i;",
+		Right: "for (var i = 0; i < 3; i = i + 1) print i;",
+	},
+	ExpectSemicolonAfterReturn.ID: {
+		Text:  "A return statement must end with ';', after the returned value if there is one.",
+		Wrong: "fun one() { return 1 }",
+		Right: "fun one() { return 1; }",
+	},
+	ExpectClassName.ID: {
+		Text:  "'class' must be followed by the name of the class it declares.",
+		Wrong: "class {\n  speak() { print \"...\"; }\n}",
+		Right: "class Animal {\n  speak() { print \"...\"; }\n}",
+	},
+	ExpectSuperclassName.ID: {
+		Text:  "'<' in a class declaration must be followed by the name of the class to inherit from.",
+		Wrong: "class Animal {}\nclass Dog < {}",
+		Right: "class Animal {}\nclass Dog < Animal {}",
+	},
+	ExpectBraceBeforeClassBody.ID: {
+		Text:  "The methods of a class must be in braces after its name and superclass, even when there are none.",
+		Wrong: "class Dog\n  bark() { print \"woof\"; }\n}",
+		Right: "class Dog {\n  bark() { print \"woof\"; }\n}",
+	},
+	ExpectBraceAfterClassBody.ID: {
+		Text:  "A class body is missing its closing '}'.",
+		Wrong: "class Dog {\n  bark() { print \"woof\"; }",
+		Right: "class Dog {\n  bark() { print \"woof\"; }\n}",
+	},
+	ExpectPropertyName.ID: {
+		Text:  "A '.' must be followed by the name of the property or method to get or set.",
+		Wrong: "class Point { init() { this.x = 1; } }\nprint Point().;",
+		Right: "class Point { init() { this.x = 1; } }\nprint Point().x;",
+	},
+	ExpectDotAfterSuper.ID: {
+		Text:  "'super' is not a value on its own; it can only be used to get a method of the superclass, as in 'super.method'.",
+		Wrong: "class A { name() { return \"A\"; } }\nclass B < A {\n  name() { return super; }\n}",
+		Right: "class A { name() { return \"A\"; } }\nclass B < A {\n  name() { return super.name(); }\n}",
+	},
+	ExpectSuperclassMethod.ID: {
+		Text:  "'super.' must be followed by the name of a method of the superclass.",
+		Wrong: "class A { name() { return \"A\"; } }\nclass B < A {\n  name() { return super.(); }\n}",
+		Right: "class A { name() { return \"A\"; } }\nclass B < A {\n  name() { return super.name(); }\n}",
+	},
+
+	// Resolving.
+	ReadInOwnInitializer.ID: {
+		Text:  "A local variable is in scope from its own declaration, so its initializer cannot refer to an outer variable of the same name: it would be reading the new variable before it has a value. Give the new variable a different name.",
+		Wrong: "var a = \"outer\";\n{\n  var a = a;\n  print a;\n}",
+		Right: "var a = \"outer\";\n{\n  var b = a;\n  print b;\n}",
+	},
+	AlreadyDeclared.ID: {
+		Text:  "A block or function declares two local variables or parameters with the same name. Assign to the existing variable instead of declaring it again. Global variables may be redeclared.",
+		Wrong: "fun f() {\n  var a = 1;\n  var a = 2;\n  print a;\n}",
+		Right: "fun f() {\n  var a = 1;\n  a = 2;\n  print a;\n}",
+	},
+	TopLevelReturn.ID: {
+		Text:  "'return' can only be used inside a function or method. To end a script early, put its code in a function and return from that.",
+		Wrong: `return 1;`,
+		Right: "fun one() { return 1; }\nprint one();",
+	},
+	ReturnFromInit.ID: {
+		Text:  "An initializer always returns the new instance, so 'return' in 'init' cannot take a value. A bare 'return;' is allowed to leave the initializer early.",
+		Wrong: "class Point {\n  init(x) {\n    this.x = x;\n    return x;\n  }\n}",
+		Right: "class Point {\n  init(x) {\n    this.x = x;\n  }\n}",
+	},
+	InheritFromSelf.ID: {
+		Text:  "A class names itself as its superclass. A class can only inherit from a different class, declared before it.",
+		Wrong: "class Oops < Oops {}",
+		Right: "class Base {}\nclass Derived < Base {}",
+	},
+	ThisOutsideClass.ID: {
+		Text:  "'this' is the instance a method was called on, so it can only be used in methods, or in functions declared inside them.",
+		Wrong: "fun show() { print this; }",
+		Right: "class Box {\n  show() { print this; }\n}",
+	},
+	SuperOutsideClass.ID: {
+		Text:  "'super' refers to the superclass of the class a method belongs to, so it can only be used in methods.",
+		Wrong: "fun speak() { super.speak(); }",
+		Right: "class Animal { speak() {} }\nclass Dog < Animal {\n  speak() { super.speak(); }\n}",
+	},
+	SuperWithoutSuper.ID: {
+		Text:  "'super' was used in a method of a class that does not inherit from another class, so there is no superclass to look methods up on.",
+		Wrong: "class Dog {\n  speak() { super.speak(); }\n}",
+		Right: "class Animal { speak() {} }\nclass Dog < Animal {\n  speak() { super.speak(); }\n}",
+	},
+
+	// Running.
+	OperandMustBeNumber.ID: {
+		Text:  "Unary '-' only works on numbers. Lox does not convert other values to numbers.",
+		Wrong: `print -"five";`,
+		Right: `print -5;`,
+	},
+	OperandsMustBeNumber.ID: {
+		Text:  "Arithmetic operators other than '+', and the comparisons '<', '<=', '>' and '>=', only work on two numbers. Lox does not convert other values to numbers.",
+		Wrong: `print "3" * 2;`,
+		Right: `print 3 * 2;`,
+	},
+	RightMustBeNumber.ID: {
+		Text:  "The left operand of '+' is a number, so the right one must be a number too. Lox does not convert between numbers and strings.",
+		Wrong: `print 1 + "2";`,
+		Right: `print 1 + 2;`,
+	},
+	RightMustBeString.ID: {
+		Text:  "The left operand of '+' is a string, so the right one must be a string too. Lox does not convert other values to strings when concatenating.",
+		Wrong: `print "total: " + 3;`,
+		Right: `print "total: " + "3";`,
+	},
+	BadPlusOperands.ID: {
+		Text:  "'+' adds two numbers or concatenates two strings, and its left operand is neither. This often means a variable is still nil.",
+		Wrong: "var count;\nprint count + 1;",
+		Right: "var count = 0;\nprint count + 1;",
+	},
+	UndefinedVariable.ID: {
+		Text:  "No variable with this name is in scope. Global variables must be declared with 'var', 'fun' or 'class' before the code that uses them runs, including before they are assigned to. Check the spelling of the name.",
+		Wrong: "total = 1;\nprint total;",
+		Right: "var total = 1;\nprint total;",
+	},
+	UndefinedProperty.ID: {
+		Text:  "The instance has no field and its class no method with this name. Fields only exist once they have been assigned, usually in 'init'.",
+		Wrong: "class Point {}\nvar p = Point();\nprint p.x;",
+		Right: "class Point {}\nvar p = Point();\np.x = 1;\nprint p.x;",
+	},
+	OnlyInstancesProps.ID: {
+		Text:  "Only instances of classes have properties. Strings, numbers, booleans, nil, functions and classes have none to get.",
+		Wrong: "var name = \"lox\";\nprint name.length;",
+		Right: "class Name { init(s) { this.text = s; } }\nvar name = Name(\"lox\");\nprint name.text;",
+	},
+	OnlyInstancesFields.ID: {
+		Text:  "Only instances of classes have fields. Strings, numbers, booleans, nil, functions and classes cannot have fields set on them.",
+		Wrong: "var point = 1;\npoint.x = 2;",
+		Right: "class Point {}\nvar point = Point();\npoint.x = 2;",
+	},
+	NotCallable.ID: {
+		Text:  "Only functions, methods and classes can be called. The value before the parentheses is something else, often a field or variable that shadows a function.",
+		Wrong: "var greet = \"hi\";\ngreet();",
+		Right: "fun greet() { print \"hi\"; }\ngreet();",
+	},
+	WrongArity.ID: {
+		Text:  "A function, method or class was called with a different number of arguments than it has parameters. A class takes the parameters of its 'init' method. Lox has no optional parameters; pass nil for values you do not have.",
+		Wrong: "fun add(a, b) { return a + b; }\nprint add(1);",
+		Right: "fun add(a, b) { return a + b; }\nprint add(1, 2);",
+	},
+	SuperclassNotClass.ID: {
+		Text:  "The name after '<' in a class declaration refers to a value that is not a class.",
+		Wrong: "var Base = \"not a class\";\nclass Derived < Base {}",
+		Right: "class Base {}\nclass Derived < Base {}",
+	},
+	StackOverflow.ID: {
+		Text:  "The calls in progress at once needed more stack than glox allows, usually because a recursive function has no base case or never reaches it. The stack a call needs grows with how deeply the statements and expressions in its function nest. Calls in tail position, like 'return f(n - 1);', do not use up the stack.",
+		Wrong: "fun depth(n) { return 1 + depth(n + 1); }\nprint depth(0);",
+		Right: "fun depth(n) {\n  if (n == 0) return 0;\n  return 1 + depth(n - 1);\n}\nprint depth(100);",
+	},
+	Interrupted.ID: {
+		Text:  "The program was stopped with Ctrl+C while it was running in the REPL. The REPL keeps the globals defined so far and waits for the next input.",
+		Wrong: `while (true) {}`,
+		Right: "var i = 0;\nwhile (i < 10) i = i + 1;",
+	},
+	NativeFailure.ID: {
+		Text:  "A built-in function failed, with a message of its own. The assertions available to 'glox test', such as assertEqual, fail this way when their check does not hold.",
+		Wrong: `assertEqual(1 + 1, 3);`,
+		Right: `assertEqual(1 + 1, 2);`,
+	},
+	Internal.ID: {
+		Text: "glox reached a state that should be impossible. This is a bug in glox rather than in the script; please report it, along with the script that caused it.",
+	},
+}
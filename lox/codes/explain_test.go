@@ -0,0 +1,76 @@
+package codes_test
+
+import (
+	"io"
+	"testing"
+
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// Examples that elide code, or only fail in the REPL or under glox test.
+var notRunnable = map[codes.Code]bool{
+	codes.InvalidNumber:         true,
+	codes.ExpectEndOfExpression: true,
+	codes.TooManyArguments:      true,
+	codes.TooManyParameters:     true,
+	codes.Interrupted:           true,
+	codes.NativeFailure:         true,
+}
+
+// firstError runs src as glox would and returns the code of the first
+// error it reports.
+func firstError(src string) (codes.Code, bool) {
+	in := interpreter.NewInterpreter()
+	in.SetOutput(io.Discard, io.Discard)
+
+	diagnostics := shared.Collect(func() {
+		statements := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+		if !shared.HadError {
+			resolver.NewResolver(in).Resolve(statements)
+		}
+		if !shared.HadError {
+			in.Interpret(statements)
+		}
+	})
+	shared.ResetErrors()
+
+	if len(diagnostics) > 0 {
+		return diagnostics[0].Code, true
+	}
+	if rt, ok := in.LastError(); ok {
+		return rt.Code, true
+	}
+	return codes.Code{}, false
+}
+
+func TestEveryCodeIsExplained(t *testing.T) {
+	for _, code := range codes.All() {
+		explanation := code.Explain()
+		if explanation.Text == "" {
+			t.Errorf("%s has no explanation", code.ID)
+		}
+		if (explanation.Wrong == "") != (explanation.Right == "") {
+			t.Errorf("%s has only one of its examples", code.ID)
+		}
+	}
+}
+
+func TestExamplesReportTheirCode(t *testing.T) {
+	for _, code := range codes.All() {
+		explanation := code.Explain()
+		if explanation.Wrong == "" || notRunnable[code] {
+			continue
+		}
+		if got, ok := firstError(explanation.Wrong); !ok || got != code {
+			t.Errorf("%s: wrong example reported %q, want %s:\n%s", code.ID, got.ID, code.ID, explanation.Wrong)
+		}
+		if got, ok := firstError(explanation.Right); ok {
+			t.Errorf("%s: right example reported %s:\n%s", code.ID, got.ID, explanation.Right)
+		}
+	}
+}
@@ -0,0 +1,248 @@
+// Package conformance checks Lox scripts against the expectations written
+// in their comments, in the style of the Crafting Interpreters test suite:
+//
+//	print 1 + 2;  // expect: 3
+//	return 1;     // expect error at 'return': Can't return from top-level code.
+//	// [line 9] Error at end: Expect '}' after block.
+//	f();          // expect runtime error: Can only call functions and classes.
+//
+// A script passes when its output, its errors and its exit code are
+// exactly what the comments describe. "expect error" is an error on the
+// comment's own line; an error on another line starts with "[line N]".
+// Other comments, even ones that start with "Error", are not read.
+package conformance
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// Exit codes, matching the glox command.
+const (
+	exitOK           = 0
+	exitDataError    = 65
+	exitRuntimeError = 70
+)
+
+var (
+	expectOutput  = regexp.MustCompile(`// expect: ?(.*)$`)
+	expectError   = regexp.MustCompile(`// expect (error\b.*)$`)
+	expectLineErr = regexp.MustCompile(`// \[[Ll]ine (\d+)\] (Error.*)$`)
+	expectRuntime = regexp.MustCompile(`// expect runtime error: (.+)$`)
+)
+
+// Expectations are what a script's comments say should happen.
+type Expectations struct {
+	Output []string
+	// Errors are the static errors, as "[line N] Error at 'x': message".
+	Errors []string
+	// RuntimeError is the message of the runtime error that should end
+	// the program, reported at RuntimeErrorLine.
+	RuntimeError     string
+	RuntimeErrorLine int
+}
+
+// Parse reads the expectations from a script's comments.
+func Parse(src string) Expectations {
+	var e Expectations
+	for i, line := range strings.Split(src, "\n") {
+		n := i + 1
+		line = strings.TrimRight(line, "\r")
+		if m := expectOutput.FindStringSubmatch(line); m != nil {
+			e.Output = append(e.Output, m[1])
+		} else if m := expectRuntime.FindStringSubmatch(line); m != nil {
+			e.RuntimeError, e.RuntimeErrorLine = m[1], n
+		} else if m := expectLineErr.FindStringSubmatch(line); m != nil {
+			e.Errors = append(e.Errors, "[line "+m[1]+"] "+m[2])
+		} else if m := expectError.FindStringSubmatch(line); m != nil {
+			e.Errors = append(e.Errors, fmt.Sprintf("[line %d] E%s", n, m[1][1:]))
+		}
+	}
+	return e
+}
+
+// ExitCode is the status glox should exit with.
+func (e Expectations) ExitCode() int {
+	switch {
+	case len(e.Errors) > 0:
+		return exitDataError
+	case e.RuntimeError != "":
+		return exitRuntimeError
+	}
+	return exitOK
+}
+
+// transcript renders the expectations in the form execute reports.
+func (e Expectations) transcript() []string {
+	lines := prefixed("out", e.Output)
+	lines = append(lines, prefixed("err", e.Errors)...)
+	if e.RuntimeError != "" {
+		lines = append(lines, fmt.Sprintf("err: [line %d] runtime error: %s", e.RuntimeErrorLine, e.RuntimeError))
+	}
+	return append(lines, "exit: "+strconv.Itoa(e.ExitCode()))
+}
+
+func prefixed(prefix string, lines []string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		out[i] = prefix + ": " + line
+	}
+	return out
+}
+
+// Result compares what a script was expected to do with what it did. Both
+// are transcripts of "out:" and "err:" lines followed by "exit: N".
+type Result struct {
+	Path     string
+	Expected []string
+	Actual   []string
+}
+
+func (r Result) Passed() bool {
+	return strings.Join(r.Expected, "\n") == strings.Join(r.Actual, "\n")
+}
+
+// Diff shows how the actual transcript differs from the expected one.
+func (r Result) Diff() string {
+	return Diff(r.Expected, r.Actual)
+}
+
+// Discover lists the .lox files under each path, sorted. A path naming a
+// file is taken as is.
+func Discover(paths ...string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, ".lox") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Run runs the script at path in a fresh interpreter and checks it.
+func Run(path string) (Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Result{}, err
+	}
+	src := string(data)
+	return Result{
+		Path:     path,
+		Expected: Parse(src).transcript(),
+		Actual:   execute(src),
+	}, nil
+}
+
+// execute runs src as glox would and returns the transcript of what
+// happened. The interpreter's own report of a runtime error, with its
+// stack trace, is left out.
+func execute(src string) []string {
+	shared.ResetErrors()
+	in := interpreter.NewInterpreter()
+	var stdout bytes.Buffer
+	in.SetOutput(&stdout, io.Discard)
+
+	var program []ast.Stmt
+	diagnostics := shared.Collect(func() {
+		program = parser.NewParser(scanner.NewScanner(src).WithInterner(in.Strings()).ScanTokens()).Parse()
+	})
+	if len(diagnostics) == 0 {
+		diagnostics = shared.Collect(func() {
+			resolver.NewResolver(in).Resolve(program)
+		})
+	}
+	if len(diagnostics) > 0 {
+		var lines []string
+		for _, diag := range diagnostics {
+			lines = append(lines, fmt.Sprintf("err: [line %d] Error%s: %s", diag.Line, diag.Where, diag.Message))
+		}
+		return append(lines, "exit: "+strconv.Itoa(exitDataError))
+	}
+
+	in.Interpret(program)
+	lines := prefixed("out", splitLines(stdout.String()))
+	exit := exitOK
+	if rt, ok := in.LastError(); ok {
+		exit = exitRuntimeError
+		lines = append(lines, fmt.Sprintf("err: [line %d] runtime error: %s", rt.Token.Line, rt.Message))
+	}
+	return append(lines, "exit: "+strconv.Itoa(exit))
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+// Diff is a line diff of two transcripts: lines only in want start with
+// "-", lines only in got with "+", and shared lines with a space.
+func Diff(want, got []string) string {
+	// lcs[i][j] is the length of the longest common subsequence of
+	// want[i:] and got[j:].
+	lcs := make([][]int, len(want)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(got)+1)
+	}
+	for i := len(want) - 1; i >= 0; i-- {
+		for j := len(got) - 1; j >= 0; j-- {
+			if want[i] == got[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var b strings.Builder
+	i, j := 0, 0
+	for i < len(want) || j < len(got) {
+		switch {
+		case i < len(want) && j < len(got) && want[i] == got[j]:
+			b.WriteString("  " + want[i] + "\n")
+			i++
+			j++
+		case i < len(want) && (j == len(got) || lcs[i+1][j] >= lcs[i][j+1]):
+			b.WriteString("- " + want[i] + "\n")
+			i++
+		default:
+			b.WriteString("+ " + got[j] + "\n")
+			j++
+		}
+	}
+	return b.String()
+}
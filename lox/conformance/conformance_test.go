@@ -0,0 +1,106 @@
+package conformance
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseReadsEveryKindOfExpectation(t *testing.T) {
+	e := Parse(`print 1; // expect: 1
+print "a"; // expect: a
+// [line 9] Error at end: Expect ';' after value.
+return 1; // expect error at 'return': Can't return from top-level code.
+f(); // expect runtime error: Can only call functions and classes.
+// just a comment
+// Error messages in prose are not expectations.
+`)
+	if strings.Join(e.Output, "|") != "1|a" {
+		t.Errorf("unexpected output: %q", e.Output)
+	}
+	want := "[line 9] Error at end: Expect ';' after value.|[line 4] Error at 'return': Can't return from top-level code."
+	if strings.Join(e.Errors, "|") != want {
+		t.Errorf("unexpected errors: %q", e.Errors)
+	}
+	if e.RuntimeError != "Can only call functions and classes." || e.RuntimeErrorLine != 5 {
+		t.Errorf("unexpected runtime error: %q at %d", e.RuntimeError, e.RuntimeErrorLine)
+	}
+	if e.ExitCode() != 65 {
+		t.Errorf("static errors should win, got exit %d", e.ExitCode())
+	}
+}
+
+func TestDiffMarksMissingAndExtraLines(t *testing.T) {
+	got := Diff([]string{"out: 1", "out: 2", "exit: 0"}, []string{"out: 1", "out: 3", "exit: 0"})
+	want := "  out: 1\n- out: 2\n+ out: 3\n  exit: 0\n"
+	if got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunDetectsMismatches(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]struct {
+		src  string
+		pass bool
+		diff string
+	}{
+		"output.lox":  {src: "print 1; // expect: 1\nprint 2; // expect: 3\n", diff: "- out: 3\n+ out: 2\n"},
+		"missing.lox": {src: "print 1;\n", diff: "+ out: 1\n"},
+		"runtime.lox": {
+			src:  "print 1; // expect: 1\nnil(); // expect runtime error: Can only call functions and classes.\n",
+			pass: true,
+		},
+		"wrong_line.lox": {
+			src:  "// expect runtime error: Can only call functions and classes.\nnil();\n",
+			diff: "- err: [line 1] runtime error: Can only call functions and classes.\n+ err: [line 2] runtime error: Can only call functions and classes.\n",
+		},
+		"static.lox": {
+			src:  "print 1;\nvar a = ; // expect error at ';': Expect expression.\n",
+			pass: true,
+		},
+		"unexpected_error.lox": {src: "print;\n", diff: "- exit: 0\n+ err: [line 1] Error at ';': Expect expression.\n+ exit: 65\n"},
+	}
+
+	for name, c := range cases {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(c.src), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		result, err := Run(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Passed() != c.pass {
+			t.Errorf("%s: expected passed=%v, diff:\n%s", name, c.pass, result.Diff())
+			continue
+		}
+		if !c.pass && !strings.Contains(result.Diff(), c.diff) {
+			t.Errorf("%s: expected diff to contain\n%s\ngot\n%s", name, c.diff, result.Diff())
+		}
+	}
+}
+
+// TestExamples holds the scripts in examples/ to the expectations in their
+// comments.
+func TestExamples(t *testing.T) {
+	files, err := Discover("../../examples")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no examples found")
+	}
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			result, err := Run(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.Passed() {
+				t.Errorf("mismatch (- expected, + actual):\n%s", result.Diff())
+			}
+		})
+	}
+}
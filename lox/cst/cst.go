@@ -0,0 +1,232 @@
+// Package cst is a lossless concrete syntax tree. Every byte of the
+// source belongs to exactly one token: whitespace, newlines, comments and
+// bytes the scanner rejected are kept as trivia in front of the token
+// they precede, and the EOF token holds whatever trails the program.
+// Printing a tree gives back the source byte for byte, so refactoring
+// tools can edit one part of a program and keep the rest as written.
+//
+// The nodes mirror the ast the parser builds from the same tokens, and
+// each one knows the ast.Expr or ast.Stmt it stands for.
+package cst
+
+import (
+	"sort"
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// Token is a token the parser sees, with the trivia in front of it.
+type Token struct {
+	Leading []scanner.Token
+	scanner.Token
+}
+
+// Node is a piece of syntax. Its children are the nodes and tokens it is
+// made of, in source order.
+type Node struct {
+	// AST is the ast.Expr or ast.Stmt the node stands for, or nil for the
+	// root of a tree. A for loop stands for the statement it desugars to.
+	AST      any
+	Children []Child
+}
+
+// Child is a *Node or a *Token.
+type Child interface {
+	writeTo(b *strings.Builder)
+}
+
+// Tree is a parsed program.
+type Tree struct {
+	Root       *Node
+	Statements []ast.Stmt
+
+	// Diagnostics are the scan and parse errors, which are not printed.
+	// A tree is built, and is still lossless, when there are some.
+	Diagnostics []shared.Diagnostic
+
+	nodes    map[any]*Node
+	forLoops map[ast.Stmt]*parser.ForLoop
+}
+
+// Parse scans and parses src, keeping its trivia.
+func Parse(src string) *Tree {
+	var all []scanner.Token
+	t := &Tree{nodes: map[any]*Node{}}
+	t.Diagnostics = shared.Collect(func() {
+		all = scanner.NewScanner(src).WithTrivia().ScanTokens()
+	})
+
+	var (
+		significant []scanner.Token
+		tokens      []*Token
+		trivia      []scanner.Token
+	)
+	for _, token := range all {
+		if token.Type.IsTrivia() {
+			trivia = append(trivia, token)
+			continue
+		}
+		significant = append(significant, token)
+		tokens = append(tokens, &Token{Leading: trivia, Token: token})
+		trivia = nil
+	}
+
+	p := parser.NewParser(significant)
+	statements, syntax := p.ParsePartial()
+	t.Statements = statements
+	t.Diagnostics = append(t.Diagnostics, syntax...)
+
+	t.forLoops = p.ForLoops()
+	b := &builder{tree: t, tokens: tokens, forLoops: t.forLoops}
+	t.Root = &Node{}
+	var roots []any
+	for _, stmt := range statements {
+		roots = append(roots, stmt)
+	}
+	b.fill(t.Root, roots, 0, len(tokens))
+	return t
+}
+
+// String is the source the tree was parsed from.
+func (t *Tree) String() string {
+	var b strings.Builder
+	t.Root.writeTo(&b)
+	return b.String()
+}
+
+// Node returns the node that stands for n, an ast.Expr or ast.Stmt of
+// the tree, or nil if the parser synthesized n.
+func (t *Tree) Node(n any) *Node {
+	return t.nodes[n]
+}
+
+// ForLoop returns the for loop as written when stmt is the statement
+// the parser desugared one into, and nil otherwise.
+func (t *Tree) ForLoop(stmt ast.Stmt) *parser.ForLoop {
+	return t.forLoops[stmt]
+}
+
+// Tokens returns the tokens under n, in source order.
+func (n *Node) Tokens() []*Token {
+	var tokens []*Token
+	for _, child := range n.Children {
+		switch c := child.(type) {
+		case *Token:
+			tokens = append(tokens, c)
+		case *Node:
+			tokens = append(tokens, c.Tokens()...)
+		}
+	}
+	return tokens
+}
+
+// Text is the source n covers: its tokens and the trivia between them,
+// but not the trivia in front of its first token.
+func (n *Node) Text() string {
+	var b strings.Builder
+	n.writeTo(&b)
+	tokens := n.Tokens()
+	if len(tokens) == 0 {
+		return ""
+	}
+	skip := 0
+	for _, trivia := range tokens[0].Leading {
+		skip += len(trivia.Lexeme)
+	}
+	return b.String()[skip:]
+}
+
+func (n *Node) writeTo(b *strings.Builder) {
+	for _, child := range n.Children {
+		child.writeTo(b)
+	}
+}
+
+func (t *Token) writeTo(b *strings.Builder) {
+	for _, trivia := range t.Leading {
+		b.WriteString(trivia.Lexeme)
+	}
+	b.WriteString(t.Lexeme)
+}
+
+type builder struct {
+	tree     *Tree
+	tokens   []*Token
+	forLoops map[ast.Stmt]*parser.ForLoop
+}
+
+// fill gives node the tokens from index first up to last, grouping the
+// ones each of the ast nodes covers under a child node of its own.
+func (b *builder) fill(node *Node, children []any, first, last int) {
+	type placed struct {
+		ast         any
+		first, last int
+	}
+	var spans []placed
+	for _, child := range children {
+		span := child.(interface{ Extent() ast.Span }).Extent()
+		if !span.IsValid() {
+			continue
+		}
+		from := max(b.index(span.Start.Offset), first)
+		to := min(b.index(span.End.Offset), last)
+		spans = append(spans, placed{child, from, max(from, to)})
+	}
+	sort.SliceStable(spans, func(i, j int) bool { return spans[i].first < spans[j].first })
+
+	i := first
+	for _, s := range spans {
+		// An ast node that overlaps the one before it, as only a
+		// desugared statement can, keeps no tokens of its own.
+		if s.first < i {
+			continue
+		}
+		for ; i < s.first; i++ {
+			node.Children = append(node.Children, b.tokens[i])
+		}
+		child := &Node{AST: s.ast}
+		b.tree.nodes[s.ast] = child
+		b.fill(child, b.children(s.ast), s.first, s.last)
+		node.Children = append(node.Children, child)
+		i = s.last
+	}
+	for ; i < last; i++ {
+		node.Children = append(node.Children, b.tokens[i])
+	}
+}
+
+// index is the index of the first token that starts at or after offset.
+func (b *builder) index(offset int) int {
+	return sort.Search(len(b.tokens), func(i int) bool {
+		return b.tokens[i].Offset >= offset
+	})
+}
+
+// children are the ast nodes directly under n. A desugared for loop has
+// its clauses as written instead of the statements made from them.
+func (b *builder) children(n any) []any {
+	var children []any
+	if stmt, ok := n.(ast.Stmt); ok && b.forLoops[stmt] != nil {
+		loop := b.forLoops[stmt]
+		for _, clause := range []any{loop.Initializer, loop.Condition, loop.Increment, loop.Body} {
+			ast.Inspect(clause, func(c any) bool {
+				children = append(children, c)
+				return false
+			})
+		}
+		return children
+	}
+
+	ast.Inspect(n, func(c any) bool {
+		if c == n {
+			return true
+		}
+		children = append(children, c)
+		return false
+	})
+	return children
+}
@@ -0,0 +1,129 @@
+package cst
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/scanner"
+)
+
+func TestTreesPrintTheirSourceBackExactly(t *testing.T) {
+	sources := map[string]string{
+		"empty":        "",
+		"only trivia":  "  // nothing here\r\n\n\t",
+		"crlf":         "var a = 1;\r\nprint a; // one\r\n",
+		"no newline":   "print 1 + 2;",
+		"for loop":     "for (var i = 0; i < 3; i = i + 1) { print i; } // done\n",
+		"syntax error": "var = ;\nclass C { m( { }\nprint f(1, );\n",
+		"scan errors":  "print @ 1; # \"unterminated\nstring",
+		"missing }":    "fun f() {\n  print 1;\n",
+	}
+	paths, err := filepath.Glob("../../examples/*.lox")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources[filepath.Base(path)] = string(data)
+	}
+
+	for name, src := range sources {
+		if got := Parse(src).String(); got != src {
+			t.Errorf("%s: round trip changed the source\ngot:  %q\nwant: %q", name, got, src)
+		}
+	}
+}
+
+func TestTriviaLeadsTheNextToken(t *testing.T) {
+	tree := Parse("print 1; // one\n\n")
+	tokens := tree.Root.Tokens()
+	if len(tokens) != 4 {
+		t.Fatalf("expected print, 1, ';' and EOF, got %d tokens", len(tokens))
+	}
+	if len(tokens[1].Leading) != 1 || tokens[1].Leading[0].Type != scanner.WHITESPACE {
+		t.Errorf("expected one space before 1, got %v", tokens[1].Leading)
+	}
+
+	var types []scanner.TokenType
+	for _, trivia := range tokens[3].Leading {
+		types = append(types, trivia.Type)
+	}
+	want := []scanner.TokenType{scanner.WHITESPACE, scanner.COMMENT, scanner.NEWLINE, scanner.NEWLINE}
+	if len(types) != len(want) {
+		t.Fatalf("expected EOF to hold %v, got %v", want, types)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("expected EOF to hold %v, got %v", want, types)
+		}
+	}
+}
+
+func TestNodesMapToTheAST(t *testing.T) {
+	src := `class A < B {
+  init(x) { this.x = x; } // keep
+}
+for (var i = 0; i < 2; i = i + 1) print A(i).x;
+if (!true) { print "no"; } else print -(1 + 2) * 3;
+`
+	tree := Parse(src)
+	if len(tree.Diagnostics) != 0 {
+		t.Fatalf("unexpected diagnostics: %v", tree.Diagnostics)
+	}
+
+	mapped := 0
+	for _, stmt := range tree.Statements {
+		ast.Inspect(stmt, func(n any) bool {
+			node := tree.Node(n)
+			if node == nil {
+				return true
+			}
+			mapped++
+			if node.AST != n {
+				t.Errorf("node for %T stands for %T", n, node.AST)
+			}
+			span := n.(interface{ Extent() ast.Span }).Extent()
+			if want := src[span.Start.Offset:span.End.Offset]; node.Text() != want {
+				t.Errorf("%T: got %q, want %q", n, node.Text(), want)
+			}
+			return true
+		})
+	}
+	if mapped < 25 {
+		t.Errorf("expected most of the ast to be mapped, got %d nodes", mapped)
+	}
+
+	// The for loop's node stands for the statement it desugars to, and
+	// holds its clauses as written.
+	loop := tree.Node(tree.Statements[1])
+	if loop == nil || loop.Text() != "for (var i = 0; i < 2; i = i + 1) print A(i).x;" {
+		t.Fatalf("unexpected for loop node: %v", loop)
+	}
+	clauses := 0
+	for _, child := range loop.Children {
+		if _, ok := child.(*Node); ok {
+			clauses++
+		}
+	}
+	if clauses != 4 {
+		t.Errorf("expected the for loop to have 4 clauses, got %d", clauses)
+	}
+}
+
+func TestErrorsStillBuildATree(t *testing.T) {
+	tree := Parse("print 1;\nvar = 2;\nprint 3;\n")
+	if len(tree.Diagnostics) == 0 {
+		t.Fatalf("expected a syntax error")
+	}
+	if len(tree.Statements) != 3 {
+		t.Fatalf("expected a partial tree of 3 statements, got %d", len(tree.Statements))
+	}
+	if got := tree.Node(tree.Statements[2]).Text(); got != "print 3;" {
+		t.Errorf("expected the statement after the error to map, got %q", got)
+	}
+}
@@ -0,0 +1,313 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const program = `class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+}
+
+fun scale(p, k) {
+  var result = Point(p.x * k, p.y * k);
+  return result;
+}
+
+var origin = Point(1, 2);
+var big = scale(origin, 10);
+print big.x + big.y;
+`
+
+// client drives a Server in-process, the way an editor would.
+type client struct {
+	t        *testing.T
+	writer   io.WriteCloser
+	messages chan map[string]json.RawMessage
+	seq      int
+	output   strings.Builder
+	done     chan error
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+
+	clientToServer, serverIn := io.Pipe()
+	serverOut, serverToClient := io.Pipe()
+
+	c := &client{
+		t:        t,
+		writer:   serverIn,
+		messages: make(chan map[string]json.RawMessage, 100),
+		done:     make(chan error, 1),
+	}
+	go func() {
+		c.done <- NewServer(clientToServer, serverToClient).Serve()
+		serverToClient.Close()
+	}()
+	go func() {
+		reader := bufio.NewReader(serverOut)
+		for {
+			body, err := readMessage(reader)
+			if err != nil {
+				close(c.messages)
+				return
+			}
+			var msg map[string]json.RawMessage
+			if err := json.Unmarshal(body, &msg); err == nil {
+				c.messages <- msg
+			}
+		}
+	}()
+	t.Cleanup(func() { serverIn.Close() })
+	return c
+}
+
+func field(msg map[string]json.RawMessage, name string) string {
+	var s string
+	_ = json.Unmarshal(msg[name], &s)
+	return s
+}
+
+// next returns the next message that is not program output, collecting
+// output along the way.
+func (c *client) next() map[string]json.RawMessage {
+	c.t.Helper()
+	for {
+		select {
+		case msg, ok := <-c.messages:
+			if !ok {
+				c.t.Fatalf("server closed the stream")
+			}
+			if field(msg, "event") == "output" {
+				var body OutputBody
+				_ = json.Unmarshal(msg["body"], &body)
+				c.output.WriteString(body.Output)
+				continue
+			}
+			return msg
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("timed out waiting for a message")
+		}
+	}
+}
+
+// request sends a request and decodes the body of its response.
+func (c *client) request(command string, args any, body any) {
+	c.t.Helper()
+	c.seq++
+	if err := writeMessage(c.writer, map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": args}); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+
+	msg := c.next()
+	if field(msg, "type") != "response" || field(msg, "command") != command {
+		c.t.Fatalf("expected %s response, got %v", command, msg)
+	}
+	var success bool
+	_ = json.Unmarshal(msg["success"], &success)
+	if !success {
+		c.t.Fatalf("%s failed: %s", command, msg["message"])
+	}
+	if body != nil {
+		if err := json.Unmarshal(msg["body"], body); err != nil {
+			c.t.Fatalf("decode %s body %s: %v", command, msg["body"], err)
+		}
+	}
+}
+
+// expectEvent waits for an event and decodes its body.
+func (c *client) expectEvent(name string, body any) {
+	c.t.Helper()
+	msg := c.next()
+	if field(msg, "type") != "event" || field(msg, "event") != name {
+		c.t.Fatalf("expected %s event, got %v", name, msg)
+	}
+	if body != nil {
+		if err := json.Unmarshal(msg["body"], body); err != nil {
+			c.t.Fatalf("decode %s event %s: %v", name, msg["body"], err)
+		}
+	}
+}
+
+func (c *client) launch(stopOnEntry bool, lines ...int) string {
+	c.t.Helper()
+	path := filepath.Join(c.t.TempDir(), "program.lox")
+	if err := os.WriteFile(path, []byte(program), 0o644); err != nil {
+		c.t.Fatal(err)
+	}
+
+	var caps Capabilities
+	c.request("initialize", map[string]any{"adapterID": "glox", "linesStartAt1": true}, &caps)
+	if !caps.SupportsConfigurationDoneRequest {
+		c.t.Fatalf("expected configurationDone support")
+	}
+	c.expectEvent("initialized", nil)
+	c.request("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+
+	breakpoints := []SourceBreakpoint{}
+	for _, line := range lines {
+		breakpoints = append(breakpoints, SourceBreakpoint{Line: line})
+	}
+	var set BreakpointsBody
+	c.request("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: breakpoints}, &set)
+	for i, bp := range set.Breakpoints {
+		if !bp.Verified {
+			c.t.Fatalf("breakpoint %d not verified: %+v", i, bp)
+		}
+	}
+	c.request("configurationDone", nil, nil)
+	return path
+}
+
+func (c *client) expectStop(reason string, line int) {
+	c.t.Helper()
+	var stopped StoppedBody
+	c.expectEvent("stopped", &stopped)
+	if stopped.Reason != reason {
+		c.t.Fatalf("expected stop reason %q, got %q", reason, stopped.Reason)
+	}
+
+	var trace StackTraceBody
+	c.request("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	if got := trace.StackFrames[0].Line; got != line {
+		c.t.Fatalf("expected to stop at line %d, got %d (%+v)", line, got, trace.StackFrames)
+	}
+}
+
+func (c *client) expectExit(code int) {
+	c.t.Helper()
+	var exited ExitedBody
+	c.expectEvent("exited", &exited)
+	if exited.ExitCode != code {
+		c.t.Fatalf("expected exit code %d, got %d", code, exited.ExitCode)
+	}
+	c.expectEvent("terminated", nil)
+}
+
+func TestBreakpointStackScopesAndVariables(t *testing.T) {
+	c := startServer(t)
+	path := c.launch(false, 10)
+	c.expectStop("breakpoint", 10)
+
+	var trace StackTraceBody
+	c.request("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	if len(trace.StackFrames) != 2 || trace.StackFrames[0].Name != "scale" || trace.StackFrames[1].Name != "<script>" ||
+		trace.StackFrames[1].Line != 14 || trace.StackFrames[0].Source.Path != path {
+		t.Fatalf("unexpected stack: %+v", trace.StackFrames)
+	}
+
+	var scopes ScopesBody
+	c.request("scopes", ScopesArguments{FrameID: trace.StackFrames[0].ID}, &scopes)
+	if len(scopes.Scopes) != 2 || scopes.Scopes[1].Name != "globals" || !scopes.Scopes[1].Expensive {
+		t.Fatalf("unexpected scopes: %+v", scopes.Scopes)
+	}
+
+	var locals VariablesBody
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &locals)
+	got := []string{}
+	for _, v := range locals.Variables {
+		got = append(got, v.Name+":"+v.Type)
+	}
+	if strings.Join(got, " ") != "p:Point k:number result:Point" {
+		t.Fatalf("unexpected locals: %v", got)
+	}
+
+	// Expand the instance's fields, then change one.
+	ref := locals.Variables[2].VariablesReference
+	var fields VariablesBody
+	c.request("variables", VariablesArguments{VariablesReference: ref}, &fields)
+	got = got[:0]
+	for _, v := range fields.Variables {
+		got = append(got, v.Name+"="+v.Value)
+	}
+	if strings.Join(got, " ") != "x=10 y=20" {
+		t.Fatalf("unexpected fields: %v", got)
+	}
+	var set SetVariableBody
+	c.request("setVariable", SetVariableArguments{VariablesReference: ref, Name: "x", Value: "k * 100"}, &set)
+	if set.Value != "1000" {
+		t.Fatalf("expected x to become 1000, got %+v", set)
+	}
+
+	var eval EvaluateBody
+	c.request("evaluate", EvaluateArguments{Expression: "p.y + k", FrameID: 1}, &eval)
+	if eval.Result != "12" {
+		t.Fatalf("expected evaluate to give 12, got %+v", eval)
+	}
+
+	c.request("continue", nil, nil)
+	c.expectExit(0)
+	if c.output.String() != "1020\n" {
+		t.Fatalf("expected the program to see the changed field, got %q", c.output.String())
+	}
+	c.request("disconnect", nil, nil)
+}
+
+func TestSteppingAndFunctionBreakpoints(t *testing.T) {
+	c := startServer(t)
+	c.launch(true)
+	c.expectStop("entry", 1)
+
+	var fbs BreakpointsBody
+	c.request("setFunctionBreakpoints", SetFunctionBreakpointsArguments{Breakpoints: []FunctionBreakpoint{{Name: "Point.init"}}}, &fbs)
+	if len(fbs.Breakpoints) != 1 || !fbs.Breakpoints[0].Verified {
+		t.Fatalf("unexpected function breakpoints: %+v", fbs)
+	}
+
+	c.request("next", nil, nil)
+	c.expectStop("step", 8)
+	c.request("continue", nil, nil)
+	c.expectStop("breakpoint", 3)
+	c.request("stepOut", nil, nil)
+	c.expectStop("step", 14)
+
+	// With the function breakpoint cleared, stepping over the constructor
+	// call in scale does not stop in init.
+	c.request("setFunctionBreakpoints", SetFunctionBreakpointsArguments{Breakpoints: []FunctionBreakpoint{}}, nil)
+	c.request("stepIn", nil, nil)
+	c.expectStop("step", 9)
+	c.request("next", nil, nil)
+	c.expectStop("step", 10)
+	c.request("continue", nil, nil)
+	c.expectExit(0)
+	c.request("disconnect", nil, nil)
+}
+
+func TestDisconnectWhileStoppedAbandonsTheProgram(t *testing.T) {
+	c := startServer(t)
+	c.launch(false, 15)
+	c.expectStop("breakpoint", 15)
+
+	c.request("disconnect", nil, nil)
+	if err := <-c.done; err != nil {
+		t.Fatalf("Serve returned %v", err)
+	}
+	if c.output.Len() != 0 {
+		t.Fatalf("expected no program output, got %q", c.output.String())
+	}
+}
+
+func TestRequestsNeedAStoppedProgram(t *testing.T) {
+	c := startServer(t)
+	c.request("initialize", nil, nil)
+	c.expectEvent("initialized", nil)
+
+	c.seq++
+	_ = writeMessage(c.writer, map[string]any{"seq": c.seq, "type": "request", "command": "stackTrace", "arguments": map[string]any{"threadId": 1}})
+	msg := c.next()
+	var success bool
+	_ = json.Unmarshal(msg["success"], &success)
+	if success || !strings.Contains(string(msg["message"]), "not stopped") {
+		t.Fatalf("expected stackTrace to fail, got %v", msg)
+	}
+}
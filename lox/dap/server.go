@@ -0,0 +1,583 @@
+// Package dap implements a Debug Adapter Protocol server so editors can
+// debug Lox programs. It is a frontend for debugger.Session: the program
+// runs on its own goroutine and, while it is stopped, the server answers
+// stack, scope and variable requests from the session's state.
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"example.com/golox/lox/debugger"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/shared"
+)
+
+// Lox programs are single threaded; this is the only thread reported.
+const threadID = 1
+
+type Server struct {
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	writer  io.Writer
+	seq     int
+
+	mu      sync.Mutex
+	session *debugger.Session
+	launch  LaunchArguments
+	stopped bool
+	handles []handle
+	resume  chan debugger.Action
+	done    chan struct{} // closed when the program ends; nil before it starts
+	lines   []int         // breakpoints requested before launch
+	funcs   []string
+}
+
+// handle is what a variablesReference points at: a scope of a frame, or
+// the fields of an instance.
+type handle struct {
+	frame    int
+	env      *interpreter.Environment
+	instance *interpreter.LoxInstance
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		reader: bufio.NewReader(r),
+		writer: w,
+		resume: make(chan debugger.Action),
+	}
+}
+
+// Serve handles requests until the client disconnects or closes the
+// stream. A program still running at that point is abandoned.
+func (s *Server) Serve() error {
+	defer s.stopProgram()
+
+	for {
+		body, err := readMessage(s.reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.event("output", OutputBody{Category: "console", Output: "invalid message: " + err.Error() + "\n"})
+			continue
+		}
+		if msg.Type != "request" {
+			continue
+		}
+
+		result, then, err := s.dispatch(msg)
+		if err != nil {
+			s.send(&response{Type: "response", RequestSeq: msg.Seq, Command: msg.Command, Message: err.Error()})
+			continue
+		}
+		s.send(&response{Type: "response", RequestSeq: msg.Seq, Command: msg.Command, Success: true, Body: result})
+		if then != nil {
+			then()
+		}
+		if msg.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+// dispatch handles one request. then, if set, runs after the response is
+// sent, so that events it causes reach the client in order.
+func (s *Server) dispatch(msg message) (result any, then func(), err error) {
+	switch msg.Command {
+	case "initialize":
+		return Capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsFunctionBreakpoints:      true,
+			SupportsSetVariable:              true,
+			SupportsEvaluateForHovers:        true,
+		}, func() { s.event("initialized", nil) }, nil
+
+	case "launch":
+		var args LaunchArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, s.load(args)
+
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		return s.setBreakpoints(args), nil, nil
+
+	case "setFunctionBreakpoints":
+		var args SetFunctionBreakpointsArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		return s.setFunctionBreakpoints(args), nil, nil
+
+	case "configurationDone":
+		return nil, nil, s.start()
+
+	case "threads":
+		return ThreadsBody{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil, nil
+
+	case "continue":
+		then, err := s.resumeWith(debugger.Continue)
+		return ContinueBody{AllThreadsContinued: true}, then, err
+	case "next":
+		then, err := s.resumeWith(debugger.StepOver)
+		return nil, then, err
+	case "stepIn":
+		then, err := s.resumeWith(debugger.StepIn)
+		return nil, then, err
+	case "stepOut":
+		then, err := s.resumeWith(debugger.StepOut)
+		return nil, then, err
+	case "pause":
+		if s.session == nil {
+			return nil, nil, errors.New("no program is running")
+		}
+		s.session.Pause()
+		return nil, nil, nil
+
+	case "stackTrace":
+		var args StackTraceArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.stackTrace(args)
+		return result, nil, err
+	case "scopes":
+		var args ScopesArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.scopes(args)
+		return result, nil, err
+	case "variables":
+		var args VariablesArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.variables(args)
+		return result, nil, err
+	case "setVariable":
+		var args SetVariableArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.setVariable(args)
+		return result, nil, err
+	case "evaluate":
+		var args EvaluateArguments
+		if err := decode(msg, &args); err != nil {
+			return nil, nil, err
+		}
+		result, err := s.evaluate(args)
+		return result, nil, err
+
+	case "disconnect", "terminate":
+		return nil, s.stopProgram, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported request %q", msg.Command)
+}
+
+func decode(msg message, v any) error {
+	if len(msg.Arguments) == 0 {
+		return nil
+	}
+	return json.Unmarshal(msg.Arguments, v)
+}
+
+// load prepares the program named by a launch request. It does not run
+// until configurationDone, so breakpoints can be set first.
+func (s *Server) load(args LaunchArguments) error {
+	if s.session != nil {
+		return errors.New("a program is already launched")
+	}
+	src, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+
+	in := interpreter.NewInterpreter()
+	in.SetOutput(outputWriter{s, "stdout"}, outputWriter{s, "stderr"})
+	session, err := debugger.NewSession(in, args.Program, string(src))
+	if err != nil {
+		return err
+	}
+	session.OnStop = s.onStop
+
+	s.session, s.launch = session, args
+	s.applyLineBreakpoints()
+	for _, name := range s.funcs {
+		session.SetBreakpoint(name)
+	}
+	return nil
+}
+
+func (s *Server) start() error {
+	if s.session == nil {
+		return errors.New("launch the program before configurationDone")
+	}
+	if s.done != nil {
+		return nil
+	}
+	s.done = make(chan struct{})
+	go s.run()
+	return nil
+}
+
+func (s *Server) run() {
+	defer close(s.done)
+
+	shared.ResetErrors()
+	finished := s.session.Run(s.launch.StopOnEntry)
+
+	exitCode := 0
+	if !finished || shared.HadRuntimeError {
+		exitCode = 1
+	}
+	s.event("exited", ExitedBody{ExitCode: exitCode})
+	s.event("terminated", nil)
+}
+
+// onStop runs on the program's goroutine. It publishes the stop and waits
+// for a resume request.
+func (s *Server) onStop(stop debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	s.stopped = true
+	s.handles = nil
+	s.mu.Unlock()
+
+	body := StoppedBody{Reason: stop.Reason, ThreadID: threadID, AllThreadsStopped: true}
+	if stop.Breakpoint != nil {
+		body.HitBreakpointIDs = []int{stop.Breakpoint.ID}
+	}
+	s.event("stopped", body)
+	return <-s.resume
+}
+
+func (s *Server) resumeWith(action debugger.Action) (func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return nil, errors.New("the program is not stopped")
+	}
+	s.stopped = false
+	return func() { s.resume <- action }, nil
+}
+
+// stopProgram abandons the program, whether it is stopped or running, and
+// waits for its goroutine to finish.
+func (s *Server) stopProgram() {
+	if s.done == nil {
+		return
+	}
+	s.session.Abort()
+	for {
+		select {
+		case <-s.done:
+			return
+		case s.resume <- debugger.Quit:
+		}
+	}
+}
+
+// whileStopped checks that frame and variable requests arrive while the
+// program is paused, when the session's state is stable.
+func (s *Server) whileStopped() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.stopped {
+		return errors.New("the program is not stopped")
+	}
+	return nil
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) BreakpointsBody {
+	s.lines = s.lines[:0]
+	for _, bp := range args.Breakpoints {
+		s.lines = append(s.lines, bp.Line)
+	}
+	if s.session == nil {
+		body := BreakpointsBody{Breakpoints: []Breakpoint{}}
+		for _, line := range s.lines {
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: false, Line: line, Message: "pending launch"})
+		}
+		return body
+	}
+	return s.applyLineBreakpoints()
+}
+
+func (s *Server) applyLineBreakpoints() BreakpointsBody {
+	body := BreakpointsBody{Breakpoints: []Breakpoint{}}
+	s.session.ClearLineBreakpoints()
+	for _, line := range s.lines {
+		bp, err := s.session.AddLineBreakpoint(line)
+		if err != nil {
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: false, Line: line, Message: err.Error()})
+			continue
+		}
+		body.Breakpoints = append(body.Breakpoints, Breakpoint{ID: bp.ID, Verified: true, Line: bp.Line})
+	}
+	return body
+}
+
+func (s *Server) setFunctionBreakpoints(args SetFunctionBreakpointsArguments) BreakpointsBody {
+	s.funcs = s.funcs[:0]
+	body := BreakpointsBody{Breakpoints: []Breakpoint{}}
+	if s.session != nil {
+		s.session.ClearFunctionBreakpoints()
+	}
+	for _, fb := range args.Breakpoints {
+		s.funcs = append(s.funcs, fb.Name)
+		if s.session == nil {
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: false, Message: "pending launch"})
+			continue
+		}
+		bp, err := s.session.SetBreakpoint(fb.Name)
+		if err != nil {
+			body.Breakpoints = append(body.Breakpoints, Breakpoint{Verified: false, Message: err.Error()})
+			continue
+		}
+		body.Breakpoints = append(body.Breakpoints, Breakpoint{ID: bp.ID, Verified: true})
+	}
+	return body
+}
+
+func (s *Server) stackTrace(args StackTraceArguments) (StackTraceBody, error) {
+	if err := s.whileStopped(); err != nil {
+		return StackTraceBody{}, err
+	}
+	frames := s.session.Frames()
+	source := &Source{Name: filepath.Base(s.session.Path()), Path: s.session.Path()}
+
+	body := StackTraceBody{StackFrames: []StackFrame{}, TotalFrames: len(frames)}
+	for level, frame := range frames {
+		if level < args.StartFrame || args.Levels > 0 && level >= args.StartFrame+args.Levels {
+			continue
+		}
+		body.StackFrames = append(body.StackFrames, StackFrame{
+			ID:     level + 1,
+			Name:   frame.Name,
+			Source: source,
+			Line:   frame.Line,
+			Column: 1,
+		})
+	}
+	return body, nil
+}
+
+func (s *Server) scopes(args ScopesArguments) (ScopesBody, error) {
+	if err := s.whileStopped(); err != nil {
+		return ScopesBody{}, err
+	}
+	level := args.FrameID - 1
+	scopes, err := s.session.Scopes(level)
+	if err != nil {
+		return ScopesBody{}, err
+	}
+
+	body := ScopesBody{Scopes: []Scope{}}
+	for _, scope := range scopes {
+		body.Scopes = append(body.Scopes, Scope{
+			Name:               scope.Name,
+			VariablesReference: s.newHandle(handle{frame: level, env: scope.Env}),
+			Expensive:          scope.Name == "globals",
+		})
+	}
+	return body, nil
+}
+
+func (s *Server) newHandle(h handle) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handles = append(s.handles, h)
+	return len(s.handles)
+}
+
+func (s *Server) handle(ref int) (handle, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if ref < 1 || ref > len(s.handles) {
+		return handle{}, fmt.Errorf("unknown variablesReference %d", ref)
+	}
+	return s.handles[ref-1], nil
+}
+
+func (s *Server) variables(args VariablesArguments) (VariablesBody, error) {
+	if err := s.whileStopped(); err != nil {
+		return VariablesBody{}, err
+	}
+	h, err := s.handle(args.VariablesReference)
+	if err != nil {
+		return VariablesBody{}, err
+	}
+
+	body := VariablesBody{Variables: []Variable{}}
+	if h.instance != nil {
+		for _, name := range h.instance.FieldNames() {
+			value, _ := h.instance.Field(name)
+			body.Variables = append(body.Variables, s.variable(h.frame, name, value))
+		}
+		return body, nil
+	}
+	for _, name := range h.env.Names() {
+		body.Variables = append(body.Variables, s.variable(h.frame, name, h.env.GetAt(0, name)))
+	}
+	return body, nil
+}
+
+// variable describes a value; instances get a reference so the client
+// can expand their fields.
+func (s *Server) variable(frame int, name string, value interpreter.Value) Variable {
+	v := Variable{Name: name, Value: display(value), Type: typeName(value)}
+	if instance, ok := value.AsObject().(*interpreter.LoxInstance); ok {
+		v.VariablesReference = s.newHandle(handle{frame: frame, instance: instance})
+	}
+	return v
+}
+
+func (s *Server) setVariable(args SetVariableArguments) (SetVariableBody, error) {
+	if err := s.whileStopped(); err != nil {
+		return SetVariableBody{}, err
+	}
+	h, err := s.handle(args.VariablesReference)
+	if err != nil {
+		return SetVariableBody{}, err
+	}
+	value, err := s.session.Evaluate(h.frame, args.Value)
+	if err != nil {
+		return SetVariableBody{}, err
+	}
+
+	if h.instance != nil {
+		h.instance.SetField(args.Name, value)
+	} else if h.env.Has(args.Name) {
+		h.env.Define(args.Name, value)
+	} else {
+		return SetVariableBody{}, fmt.Errorf("no variable named '%s' in this scope", args.Name)
+	}
+	v := s.variable(h.frame, args.Name, value)
+	return SetVariableBody{Value: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}, nil
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (EvaluateBody, error) {
+	if err := s.whileStopped(); err != nil {
+		return EvaluateBody{}, err
+	}
+	level := max(args.FrameID-1, 0)
+	value, err := s.session.Evaluate(level, args.Expression)
+	if err != nil {
+		return EvaluateBody{}, err
+	}
+	v := s.variable(level, "", value)
+	return EvaluateBody{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}, nil
+}
+
+func display(value interpreter.Value) string {
+	if str, ok := value.AsString(); ok {
+		return strconv.Quote(str)
+	}
+	return value.String()
+}
+
+func typeName(value interpreter.Value) string {
+	switch value.Kind() {
+	case interpreter.NilValue:
+		return "nil"
+	case interpreter.BoolValue:
+		return "boolean"
+	case interpreter.NumberValue:
+		return "number"
+	case interpreter.StringValue:
+		return "string"
+	}
+	switch obj := value.AsObject().(type) {
+	case *interpreter.LoxInstance:
+		return obj.Class.Name
+	case *interpreter.LoxClass:
+		return "class"
+	default:
+		return "function"
+	}
+}
+
+// outputWriter turns program output into output events.
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.event("output", OutputBody{Category: w.category, Output: string(p)})
+	return len(p), nil
+}
+
+func (s *Server) event(name string, body any) {
+	s.send(&event{Type: "event", Event: name, Body: body})
+}
+
+// send numbers and writes an outgoing message. Events come from the
+// program's goroutine as well as the request loop.
+func (s *Server) send(msg any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	switch m := msg.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+	_ = writeMessage(s.writer, msg)
+}
+
+// readMessage reads one Content-Length framed message body.
+func readMessage(r *bufio.Reader) ([]byte, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// writeMessage frames v as JSON with a Content-Length header.
+func writeMessage(w io.Writer, v any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = w.Write(body)
+	return err
+}
@@ -0,0 +1,196 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const consoleHelp = `Commands:
+  break|b SPEC         stop at a line ("12", "file.lox:12") or function ("f", "Class.m")
+  delete|d ID          remove a breakpoint
+  breakpoints|bl       list breakpoints
+  continue|c           run until the next breakpoint
+  step|s               step into the next statement
+  next|n               step over calls
+  out|o                run until the current function returns
+  backtrace|bt         show the call stack
+  frame|f N            select frame N for locals, print and set
+  locals|v             show every scope of the selected frame
+  print|p EXPR         evaluate an expression in the selected frame
+  set NAME = EXPR      assign to a variable visible from the selected frame
+  list|l               show source around the selected frame
+  quit|q               abandon the program`
+
+// Console is a line-oriented debugger frontend, in the style of gdb.
+// When its input ends, the program runs to completion without stopping.
+type Console struct {
+	session  *Session
+	input    *bufio.Scanner
+	out      io.Writer
+	frame    int
+	detached bool
+}
+
+func NewConsole(session *Session, r io.Reader, w io.Writer) *Console {
+	c := &Console{session: session, input: bufio.NewScanner(r), out: w}
+	session.OnStop = c.stopped
+	return c
+}
+
+// Run debugs the program from its first statement.
+func (c *Console) Run() {
+	if c.session.Run(true) {
+		fmt.Fprintln(c.out, "Program finished.")
+	} else {
+		fmt.Fprintln(c.out, "Program abandoned.")
+	}
+}
+
+func (c *Console) stopped(stop Stop) Action {
+	if c.detached {
+		return Continue
+	}
+	c.frame = 0
+
+	frames := c.session.Frames()
+	switch stop.Reason {
+	case "entry":
+		fmt.Fprintf(c.out, "Paused on entry at %s:%d\n", c.session.Path(), stop.Line)
+	case "breakpoint":
+		fmt.Fprintf(c.out, "Breakpoint %d, %s() at %s:%d\n", stop.Breakpoint.ID, frames[0].Name, c.session.Path(), stop.Line)
+	default:
+		fmt.Fprintf(c.out, "%s() at %s:%d\n", frames[0].Name, c.session.Path(), stop.Line)
+	}
+	c.printLine(stop.Line, false)
+
+	for {
+		fmt.Fprint(c.out, "(glox) ")
+		if !c.input.Scan() {
+			fmt.Fprintln(c.out)
+			c.detached = true
+			return Continue
+		}
+		if action, resume := c.command(strings.TrimSpace(c.input.Text())); resume {
+			return action
+		}
+	}
+}
+
+// command runs one command line. It reports whether the program should
+// resume, and how.
+func (c *Console) command(line string) (Action, bool) {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case "":
+		return 0, false
+	case "continue", "c":
+		return Continue, true
+	case "step", "s":
+		return StepIn, true
+	case "next", "n":
+		return StepOver, true
+	case "out", "o", "finish":
+		return StepOut, true
+	case "quit", "q":
+		return Quit, true
+
+	case "break", "b":
+		bp, err := c.session.SetBreakpoint(arg)
+		if err != nil {
+			c.errorf("%v", err)
+		} else {
+			fmt.Fprintln(c.out, bp)
+		}
+	case "delete", "d":
+		id, err := strconv.Atoi(arg)
+		if err != nil || !c.session.ClearBreakpoint(id) {
+			c.errorf("no breakpoint %q", arg)
+		}
+	case "breakpoints", "bl":
+		for _, bp := range c.session.Breakpoints() {
+			fmt.Fprintf(c.out, "%s (hit %d time(s))\n", bp, bp.Hits)
+		}
+
+	case "backtrace", "bt":
+		for i, frame := range c.session.Frames() {
+			marker := " "
+			if i == c.frame {
+				marker = "*"
+			}
+			fmt.Fprintf(c.out, "%s#%d %s() at %s:%d\n", marker, i, frame.Name, c.session.Path(), frame.Line)
+		}
+	case "frame", "f":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 || n >= len(c.session.Frames()) {
+			c.errorf("no frame %q", arg)
+			break
+		}
+		c.frame = n
+		frame := c.session.Frames()[n]
+		fmt.Fprintf(c.out, "#%d %s() at %s:%d\n", n, frame.Name, c.session.Path(), frame.Line)
+	case "locals", "v":
+		scopes, err := c.session.Scopes(c.frame)
+		if err != nil {
+			c.errorf("%v", err)
+			break
+		}
+		for _, scope := range scopes {
+			fmt.Fprintf(c.out, "%s:\n", scope.Name)
+			for _, v := range scope.Variables {
+				fmt.Fprintf(c.out, "  %s = %s\n", v.Name, Describe(v.Value))
+			}
+		}
+	case "print", "p":
+		value, err := c.session.Evaluate(c.frame, arg)
+		if err != nil {
+			c.errorf("%v", err)
+		} else {
+			fmt.Fprintln(c.out, Describe(value))
+		}
+	case "set":
+		target, src, ok := strings.Cut(arg, "=")
+		if !ok {
+			c.errorf("usage: set NAME = EXPR")
+			break
+		}
+		value, err := c.session.SetVariable(c.frame, strings.TrimSpace(target), strings.TrimSpace(src))
+		if err != nil {
+			c.errorf("%v", err)
+		} else {
+			fmt.Fprintf(c.out, "%s = %s\n", strings.TrimSpace(target), Describe(value))
+		}
+	case "list", "l":
+		if frames := c.session.Frames(); c.frame < len(frames) {
+			current := frames[c.frame].Line
+			for n := max(current-3, 1); n <= current+3; n++ {
+				c.printLine(n, n == current)
+			}
+		}
+
+	case "help", "h":
+		fmt.Fprintln(c.out, consoleHelp)
+	default:
+		c.errorf("unknown command %q; try \"help\"", name)
+	}
+	return 0, false
+}
+
+func (c *Console) printLine(n int, current bool) {
+	if n > len(c.session.source) {
+		return
+	}
+	marker := "  "
+	if current {
+		marker = "=>"
+	}
+	fmt.Fprintf(c.out, "%s %4d | %s\n", marker, n, c.session.SourceLine(n))
+}
+
+func (c *Console) errorf(format string, args ...any) {
+	fmt.Fprintf(c.out, "error: "+format+"\n", args...)
+}
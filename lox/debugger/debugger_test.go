@@ -0,0 +1,154 @@
+package debugger
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"example.com/golox/lox/interpreter"
+)
+
+const program = `class Point {
+  init(x, y) {
+    this.x = x;
+    this.y = y;
+  }
+  sum() {
+    var total = this.x + this.y;
+    return total;
+  }
+}
+
+fun makeAdder(n) {
+  fun add(m) {
+    var r = n + m;
+    return r;
+  }
+  return add;
+}
+
+var p = Point(1, 2);
+print p.sum();
+var add2 = makeAdder(2);
+print add2(40);
+`
+
+// debug runs program under a Console fed with commands and returns
+// everything written, program output included.
+func debug(t *testing.T, commands ...string) string {
+	t.Helper()
+	var out bytes.Buffer
+	in := interpreter.NewInterpreter()
+	in.SetOutput(&out, &out)
+
+	session, err := NewSession(in, "test.lox", program)
+	if err != nil {
+		t.Fatalf("NewSession: %v", err)
+	}
+	NewConsole(session, strings.NewReader(strings.Join(commands, "\n")+"\n"), &out).Run()
+	return out.String()
+}
+
+func expectContains(t *testing.T, out string, wants ...string) {
+	t.Helper()
+	for _, want := range wants {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestBreakpointsOnFunctionsAndLines(t *testing.T) {
+	out := debug(t, "b add", "b test.lox:21", "c", "c", "bt", "c")
+	expectContains(t, out,
+		"Paused on entry at test.lox:1",
+		"Breakpoint 1 in add()",
+		"Breakpoint 2 at line 21",
+		"Breakpoint 2, <script>() at test.lox:21",
+		"Breakpoint 1, add() at test.lox:14",
+		"*#0 add() at test.lox:14\n #1 <script>() at test.lox:23",
+		"3\n",
+		"42\n",
+		"Program finished.",
+	)
+}
+
+func TestBreakpointsMoveToTheNextStatement(t *testing.T) {
+	out := debug(t, "b 11", "b 99", "c")
+	expectContains(t, out,
+		"Breakpoint 1 at line 12",
+		"error: no code at or after line 99",
+		"Breakpoint 1, <script>() at test.lox:12",
+	)
+}
+
+func TestStepOverInAndOut(t *testing.T) {
+	out := debug(t, "b 20", "c", "n", "s", "s", "o", "q")
+	expectContains(t, out,
+		"Breakpoint 1, <script>() at test.lox:20",
+		// next steps over the constructor call.
+		"<script>() at test.lox:21",
+		// step enters sum, then moves through its body.
+		"sum() at test.lox:7",
+		"sum() at test.lox:8",
+		// out runs until the caller's next statement.
+		"<script>() at test.lox:22",
+		"Program abandoned.",
+	)
+	if strings.Contains(out, "42") {
+		t.Errorf("expected quit to stop the program, got:\n%s", out)
+	}
+}
+
+func TestInspectAndModifyVariables(t *testing.T) {
+	out := debug(t,
+		"b add", "b Point.sum", "c",
+		"p this.x + this.y", "set this.x = 10", "c",
+		"v", "set n = 100", "f 1", "p add2", "c")
+	expectContains(t, out,
+		"Breakpoint 2, sum() at test.lox:7",
+		"(glox) 3\n",
+		"error: no variable named 'this.x'",
+		// The closure captures n, shown as an outer scope of add's frame.
+		"scope 0:\n  m = 40\nscope 1:\n  n = 2\n  add = <fn add>\nglobals:",
+		"n = 100",
+		"#1 <script>() at test.lox:23",
+		"<fn add>",
+		"140\n",
+	)
+}
+
+func TestFailedEvaluationLeavesTheStackAlone(t *testing.T) {
+	out := debug(t, "b Point.sum", "c", "p makeAdder(1)(nil)", "bt", "o", "bt", "q")
+	expectContains(t, out,
+		"Breakpoint 1, sum() at test.lox:7",
+		"error: ",
+		"(glox) *#0 sum() at test.lox:7\n #1 <script>() at test.lox:21\n(glox) ",
+		// out still stops in the caller, which is back at the top level.
+		"<script>() at test.lox:22",
+		"(glox) *#0 <script>() at test.lox:22\n(glox) ",
+	)
+}
+
+func TestInstancesShowFieldsAndThis(t *testing.T) {
+	out := debug(t, "b 8", "c", "v", "set total = \"hi\"", "c")
+	expectContains(t, out,
+		"scope 0:\n  total = 3",
+		"this = <Point instance> {x = 1, y = 2}",
+		"p = <Point instance> {x = 1, y = 2}",
+		"total = \"hi\"",
+		"hi\n",
+	)
+}
+
+func TestEndOfInputRunsToCompletion(t *testing.T) {
+	out := debug(t, "b add")
+	expectContains(t, out, "3\n", "42\n", "Program finished.")
+}
+
+func TestSessionReportsCompileErrors(t *testing.T) {
+	_, err := NewSession(interpreter.NewInterpreter(), "bad.lox", "return 1;")
+	if err == nil || !strings.Contains(err.Error(), "Can't return from top-level code.") {
+		t.Fatalf("expected a resolver error, got %v", err)
+	}
+}
@@ -0,0 +1,515 @@
+// Package debugger runs Lox programs under control: it stops at
+// breakpoints, steps by statement and exposes the call stack and every
+// scope of each frame. Session is the engine shared by the frontends; the
+// terminal frontend is Console.
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// Action tells a stopped session how to resume.
+type Action int
+
+const (
+	Continue Action = iota
+	StepIn          // stop at the next statement
+	StepOver        // stop at the next statement in this frame or a caller
+	StepOut         // stop at the next statement in a caller
+	Quit            // abandon the program
+)
+
+// Stop describes why the program stopped.
+type Stop struct {
+	Reason     string // "entry", "breakpoint", "step" or "pause"
+	Line       int
+	Breakpoint *Breakpoint
+}
+
+// Breakpoint stops the program at a line or on entry to a function.
+type Breakpoint struct {
+	ID       int
+	Line     int    // 0 for function breakpoints
+	Function string // "f" or "Class.method"
+	Hits     int
+}
+
+func (b *Breakpoint) String() string {
+	if b.Function != "" {
+		return fmt.Sprintf("Breakpoint %d in %s()", b.ID, b.Function)
+	}
+	return fmt.Sprintf("Breakpoint %d at line %d", b.ID, b.Line)
+}
+
+// Frame is one level of the call stack. Level 0 is the innermost.
+type Frame struct {
+	Name string // function name, or "<script>" for top-level code
+	Line int    // current line, or the line of the call in outer frames
+	Env  *interpreter.Environment
+}
+
+// Scope is one environment in a frame's scope chain.
+type Scope struct {
+	Name      string // "scope N", counted outwards, or "globals"
+	Env       *interpreter.Environment
+	Variables []Variable
+}
+
+type Variable struct {
+	Name  string
+	Value interpreter.Value
+}
+
+// quitSignal unwinds the interpreter when the user quits.
+type quitSignal struct{}
+
+// Requests another goroutine can make of a running program.
+const (
+	pauseRequested = 1 + iota
+	abortRequested
+)
+
+type Session struct {
+	in      *interpreter.Interpreter
+	path    string
+	source  []string
+	program []ast.Stmt
+
+	lines    map[ast.Stmt]parser.LineRange
+	forLoops map[ast.Stmt]*parser.ForLoop
+	// stopLines are the lines on which some statement can stop, sorted.
+	stopLines []int
+	// entries maps the first statement of each function body to the names
+	// a function breakpoint may use for it.
+	entries map[ast.Stmt][]string
+
+	// breakpointsMu lets frontends edit breakpoints while the program
+	// runs on another goroutine.
+	breakpointsMu sync.Mutex
+	breakpoints   []*Breakpoint
+	nextID        int
+
+	// OnStop is called, on the interpreter's goroutine, whenever the
+	// program stops. The program stays paused until it returns.
+	OnStop func(stop Stop) Action
+
+	action    Action
+	atEntry   bool
+	interrupt atomic.Int32
+	depth     int // call depth where the last stop happened
+	current   ast.Stmt
+	line      int
+}
+
+// NewSession parses and resolves src with in. Syntax and resolution
+// errors are returned instead of printed, each with the line it points at.
+func NewSession(in *interpreter.Interpreter, path, src string) (*Session, error) {
+	s := &Session{
+		in:      in,
+		path:    path,
+		source:  strings.Split(src, "\n"),
+		entries: make(map[ast.Stmt][]string),
+		nextID:  1,
+	}
+
+	diagnostics := shared.Collect(func() {
+		p := parser.NewParser(scanner.NewScanner(src).WithInterner(in.Strings()).ScanTokens())
+		s.program = p.Parse()
+		s.lines, s.forLoops = p.Lines(), p.ForLoops()
+	})
+	if len(diagnostics) == 0 {
+		diagnostics = shared.Collect(func() {
+			resolver.NewResolver(in).Resolve(s.program)
+		})
+	}
+	if len(diagnostics) > 0 {
+		messages := make([]string, len(diagnostics))
+		for i, diag := range diagnostics {
+			messages[i] = diag.Caret(src)
+		}
+		return nil, errors.New(strings.Join(messages, "\n"))
+	}
+
+	seen := map[int]bool{}
+	for stmt, r := range s.lines {
+		if s.stoppable(stmt) && !seen[r.Start] {
+			seen[r.Start] = true
+			s.stopLines = append(s.stopLines, r.Start)
+		}
+	}
+	sort.Ints(s.stopLines)
+
+	for _, stmt := range s.program {
+		ast.Inspect(stmt, func(node any) bool {
+			switch n := node.(type) {
+			case *ast.Function:
+				if len(n.Body) > 0 {
+					s.entries[n.Body[0]] = append(s.entries[n.Body[0]], n.Name.Lexeme)
+				}
+			case *ast.Class:
+				for _, method := range n.Methods {
+					if len(method.Body) > 0 {
+						s.entries[method.Body[0]] = append(s.entries[method.Body[0]], n.Name.Lexeme+"."+method.Name.Lexeme)
+					}
+				}
+			}
+			return true
+		})
+	}
+	return s, nil
+}
+
+// stoppable reports whether the program can stop before stmt. Blocks are
+// skipped in favour of their first statement, except for desugared for
+// loops whose clauses have no statement of their own.
+func (s *Session) stoppable(stmt ast.Stmt) bool {
+	if _, ok := s.lines[stmt]; !ok {
+		return false
+	}
+	if _, isBlock := stmt.(*ast.Block); isBlock {
+		_, isFor := s.forLoops[stmt]
+		return isFor
+	}
+	return true
+}
+
+func (s *Session) Path() string { return s.path }
+
+// SourceLine returns line n of the program, counted from 1.
+func (s *Session) SourceLine(n int) string {
+	if n < 1 || n > len(s.source) {
+		return ""
+	}
+	return strings.TrimRight(s.source[n-1], "\r")
+}
+
+// Run executes the program, stopping before the first statement when
+// stopOnEntry is set. It returns false if the user quit.
+func (s *Session) Run(stopOnEntry bool) (finished bool) {
+	s.action = Continue
+	if stopOnEntry {
+		s.action = StepIn
+	}
+	s.depth = 0
+	s.atEntry = stopOnEntry
+	s.interrupt.Store(0)
+
+	s.in.SetHooks(s)
+	s.in.SetSource(strings.Join(s.source, "\n"))
+	defer func() {
+		s.in.SetHooks(nil)
+		s.current = nil
+		if r := recover(); r != nil {
+			if _, ok := r.(quitSignal); !ok {
+				panic(r)
+			}
+			finished = false
+		}
+	}()
+
+	s.in.Interpret(s.program)
+	return true
+}
+
+// Pause asks a running program to stop before its next statement. It may
+// be called from any goroutine.
+func (s *Session) Pause() {
+	s.interrupt.CompareAndSwap(0, pauseRequested)
+}
+
+// Abort makes a running program give up before its next statement, as if
+// the user had quit. It may be called from any goroutine.
+func (s *Session) Abort() {
+	s.interrupt.Store(abortRequested)
+}
+
+// BeforeStatement implements interpreter.Hooks.
+func (s *Session) BeforeStatement(in *interpreter.Interpreter, stmt ast.Stmt) {
+	request := s.interrupt.Load()
+	if request == abortRequested {
+		panic(quitSignal{})
+	}
+	if !s.stoppable(stmt) {
+		return
+	}
+	line := s.lines[stmt].Start
+	depth := in.CallDepth()
+
+	var stop *Stop
+	switch {
+	case request == pauseRequested:
+		s.interrupt.CompareAndSwap(pauseRequested, 0)
+		stop = &Stop{Reason: "pause", Line: line}
+	case s.action == StepIn,
+		s.action == StepOver && depth <= s.depth,
+		s.action == StepOut && depth < s.depth:
+		stop = &Stop{Reason: "step", Line: line}
+	}
+	if bp := s.breakpointFor(stmt, line); bp != nil {
+		stop = &Stop{Reason: "breakpoint", Line: line, Breakpoint: bp}
+	}
+	if stop == nil {
+		return
+	}
+	if s.atEntry {
+		s.atEntry = false
+		if stop.Breakpoint == nil {
+			stop.Reason = "entry"
+		}
+	}
+
+	s.current, s.line, s.depth = stmt, line, depth
+	action := Continue
+	if s.OnStop != nil {
+		action = s.OnStop(*stop)
+	}
+	if action == Quit {
+		panic(quitSignal{})
+	}
+	s.action = action
+}
+
+// breakpointFor finds the breakpoint, if any, for stmt and counts the hit.
+func (s *Session) breakpointFor(stmt ast.Stmt, line int) *Breakpoint {
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+
+	for _, bp := range s.breakpoints {
+		if bp.matches(line, s.entries[stmt]) {
+			bp.Hits++
+			return bp
+		}
+	}
+	return nil
+}
+
+func (bp *Breakpoint) matches(line int, entryNames []string) bool {
+	if bp.Function == "" {
+		return bp.Line == line
+	}
+	for _, name := range entryNames {
+		if name == bp.Function || strings.HasSuffix(name, "."+bp.Function) {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBreakpoint adds a breakpoint from a spec: "12", "file.lox:12", a
+// function name or "Class.method". Line breakpoints move to the first
+// line at or after the one asked for where a statement starts.
+func (s *Session) SetBreakpoint(spec string) (*Breakpoint, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, errors.New("empty breakpoint")
+	}
+
+	lineSpec := spec
+	if file, rest, ok := strings.Cut(spec, ":"); ok {
+		if filepath.Base(file) != filepath.Base(s.path) {
+			return nil, fmt.Errorf("no source file %q", file)
+		}
+		lineSpec = rest
+	}
+	if n, err := strconv.Atoi(lineSpec); err == nil {
+		return s.AddLineBreakpoint(n)
+	}
+
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+	bp := &Breakpoint{ID: s.nextID, Function: spec}
+	s.nextID++
+	s.breakpoints = append(s.breakpoints, bp)
+	return bp, nil
+}
+
+// AddLineBreakpoint adds a breakpoint on the first stoppable line at or
+// after line.
+func (s *Session) AddLineBreakpoint(line int) (*Breakpoint, error) {
+	i := sort.SearchInts(s.stopLines, line)
+	if i == len(s.stopLines) {
+		return nil, fmt.Errorf("no code at or after line %d", line)
+	}
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+	bp := &Breakpoint{ID: s.nextID, Line: s.stopLines[i]}
+	s.nextID++
+	s.breakpoints = append(s.breakpoints, bp)
+	return bp, nil
+}
+
+// ClearBreakpoint removes a breakpoint by ID.
+func (s *Session) ClearBreakpoint(id int) bool {
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+	for i, bp := range s.breakpoints {
+		if bp.ID == id {
+			s.breakpoints = append(s.breakpoints[:i], s.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// ClearLineBreakpoints removes every line breakpoint, keeping function
+// breakpoints.
+func (s *Session) ClearLineBreakpoints() {
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+	kept := s.breakpoints[:0]
+	for _, bp := range s.breakpoints {
+		if bp.Function != "" {
+			kept = append(kept, bp)
+		}
+	}
+	s.breakpoints = kept
+}
+
+// ClearFunctionBreakpoints removes every function breakpoint, keeping
+// line breakpoints.
+func (s *Session) ClearFunctionBreakpoints() {
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+	kept := s.breakpoints[:0]
+	for _, bp := range s.breakpoints {
+		if bp.Function == "" {
+			kept = append(kept, bp)
+		}
+	}
+	s.breakpoints = kept
+}
+
+func (s *Session) Breakpoints() []*Breakpoint {
+	s.breakpointsMu.Lock()
+	defer s.breakpointsMu.Unlock()
+	return append([]*Breakpoint(nil), s.breakpoints...)
+}
+
+// Frames returns the call stack while the program is stopped, innermost
+// first.
+func (s *Session) Frames() []Frame {
+	if s.current == nil {
+		return nil
+	}
+
+	stack := s.in.CallStack()
+	n := len(stack)
+	frameName := func(level int) string {
+		if i := n - 1 - level; i >= 0 {
+			return stack[i].Name
+		}
+		return "<script>"
+	}
+
+	frames := []Frame{{Name: frameName(0), Line: s.line, Env: s.in.Environment()}}
+	for level := 1; level <= n; level++ {
+		call := stack[n-level]
+		frames = append(frames, Frame{Name: frameName(level), Line: call.Line, Env: call.Caller})
+	}
+	return frames
+}
+
+func (s *Session) frame(level int) (Frame, error) {
+	frames := s.Frames()
+	if level < 0 || level >= len(frames) {
+		return Frame{}, fmt.Errorf("no frame %d", level)
+	}
+	return frames[level], nil
+}
+
+// Scopes returns the scope chain of a frame, innermost first. Closure
+// captures and a bound method's 'this' appear as outer scopes. Empty
+// scopes other than the globals are left out.
+func (s *Session) Scopes(level int) ([]Scope, error) {
+	frame, err := s.frame(level)
+	if err != nil {
+		return nil, err
+	}
+
+	var scopes []Scope
+	depth := 0
+	for env := frame.Env; env != nil; env = env.Enclosing() {
+		scope := Scope{Name: fmt.Sprintf("scope %d", depth), Env: env}
+		if env == s.in.Globals() {
+			scope.Name = "globals"
+		}
+		depth++
+		for _, name := range env.Names() {
+			scope.Variables = append(scope.Variables, Variable{Name: name, Value: env.GetAt(0, name)})
+		}
+		if len(scope.Variables) > 0 || scope.Name == "globals" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes, nil
+}
+
+// Evaluate evaluates a Lox expression in a frame.
+func (s *Session) Evaluate(level int, src string) (interpreter.Value, error) {
+	frame, err := s.frame(level)
+	if err != nil {
+		return interpreter.Nil, err
+	}
+
+	var expr ast.Expr
+	diagnostics := shared.Collect(func() {
+		tokens := scanner.NewScanner(src).WithInterner(s.in.Strings()).ScanTokens()
+		expr = parser.NewParser(tokens).ParseExpression()
+	})
+	if len(diagnostics) > 0 {
+		return interpreter.Nil, errors.New(diagnostics[0].Message)
+	}
+	return s.in.Evaluate(expr, frame.Env)
+}
+
+// SetVariable evaluates src in a frame and stores the result in the
+// innermost visible variable called name.
+func (s *Session) SetVariable(level int, name, src string) (interpreter.Value, error) {
+	frame, err := s.frame(level)
+	if err != nil {
+		return interpreter.Nil, err
+	}
+	value, err := s.Evaluate(level, src)
+	if err != nil {
+		return interpreter.Nil, err
+	}
+	for env := frame.Env; env != nil; env = env.Enclosing() {
+		if env.Has(name) {
+			env.Define(name, value)
+			return value, nil
+		}
+	}
+	return interpreter.Nil, fmt.Errorf("no variable named '%s'", name)
+}
+
+// Describe renders a value for display: strings are quoted and instances
+// list their fields.
+func Describe(v interpreter.Value) string {
+	if str, ok := v.AsString(); ok {
+		return strconv.Quote(str)
+	}
+	if instance, ok := v.AsObject().(*interpreter.LoxInstance); ok {
+		fields := instance.FieldNames()
+		parts := make([]string, len(fields))
+		for i, name := range fields {
+			value, _ := instance.Field(name)
+			parts[i] = name + " = " + value.String()
+		}
+		return instance.String() + " {" + strings.Join(parts, ", ") + "}"
+	}
+	return v.String()
+}
@@ -0,0 +1,143 @@
+// Package diagnostics writes errors and warnings for other programs to
+// read: as a JSON document of records, or as a SARIF 2.1.0 log that code
+// scanning dashboards can ingest.
+//
+// The JSON document looks like this, and only ever gains fields:
+//
+//	{
+//	  "version": 1,
+//	  "diagnostics": [
+//	    {
+//	      "file": "script.lox",
+//	      "line": 3,
+//	      "column": 9,
+//	      "length": 1,
+//	      "severity": "error",
+//	      "phase": "parse",
+//	      "code": "L0101",
+//	      "message": "Expect expression."
+//	    }
+//	  ]
+//	}
+//
+// A column or length of 0 means it is not known. Errors that are not
+// about the source, such as a file that cannot be read, have no phase or
+// code.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/shared"
+)
+
+// Version is the version of the JSON document.
+const Version = 1
+
+// Format is how diagnostics are written. It implements flag.Value.
+type Format string
+
+const (
+	Text  Format = "text"
+	JSON  Format = "json"
+	SARIF Format = "sarif"
+)
+
+func (f *Format) String() string {
+	if *f == "" {
+		return string(Text)
+	}
+	return string(*f)
+}
+
+func (f *Format) Set(s string) error {
+	switch Format(s) {
+	case Text, JSON, SARIF:
+		*f = Format(s)
+		return nil
+	}
+	return fmt.Errorf("unknown diagnostics format %q (want text, json or sarif)", s)
+}
+
+// Structured reports whether f is a machine-readable format.
+func (f Format) Structured() bool {
+	return f == JSON || f == SARIF
+}
+
+// Severity is how serious a record is.
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+)
+
+// Lint is the phase of records that come from lint rules.
+const Lint codes.Phase = "lint"
+
+// Record is one diagnostic.
+type Record struct {
+	File     string      `json:"file"`
+	Line     int         `json:"line"`
+	Column   int         `json:"column"`
+	Length   int         `json:"length"`
+	Severity Severity    `json:"severity"`
+	Phase    codes.Phase `json:"phase"`
+	Code     string      `json:"code"`
+	Message  string      `json:"message"`
+}
+
+// FromDiagnostic makes a record of a scan, parse or resolve error.
+func FromDiagnostic(file string, d shared.Diagnostic) Record {
+	return Record{
+		File:     file,
+		Line:     d.Line,
+		Column:   d.Column,
+		Length:   d.Length,
+		Severity: Error,
+		Phase:    d.Code.Phase,
+		Code:     d.Code.ID,
+		Message:  d.Message,
+	}
+}
+
+// FromRuntimeError makes a record of the error that stopped a program.
+func FromRuntimeError(file string, rt interpreter.RuntimeError) Record {
+	return Record{
+		File:     file,
+		Line:     rt.Token.Line,
+		Column:   rt.Token.Column,
+		Length:   rt.Token.Length,
+		Severity: Error,
+		Phase:    codes.Runtime,
+		Code:     rt.Code.ID,
+		Message:  rt.Message,
+	}
+}
+
+// Write writes records in a structured format.
+func Write(w io.Writer, format Format, records []Record) error {
+	if records == nil {
+		records = []Record{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+
+	switch format {
+	case JSON:
+		return enc.Encode(document{Version: Version, Diagnostics: records})
+	case SARIF:
+		return enc.Encode(sarif(records))
+	}
+	return fmt.Errorf("diagnostics: %q is not a structured format", format)
+}
+
+type document struct {
+	Version     int      `json:"version"`
+	Diagnostics []Record `json:"diagnostics"`
+}
@@ -0,0 +1,209 @@
+package diagnostics
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"testing"
+
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+var sample = []Record{
+	{File: "a.lox", Line: 2, Column: 9, Length: 1, Severity: Error, Phase: codes.Parse, Code: "L0101", Message: "Expect expression."},
+	{File: "a.lox", Line: 4, Column: 5, Severity: Warning, Phase: Lint, Code: "unused-variable", Message: "Local variable 'x' is never read."},
+}
+
+// The documents are compared byte for byte: tools depend on their shape.
+
+func TestWriteJSON(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, JSON, sample); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": 1,
+  "diagnostics": [
+    {
+      "file": "a.lox",
+      "line": 2,
+      "column": 9,
+      "length": 1,
+      "severity": "error",
+      "phase": "parse",
+      "code": "L0101",
+      "message": "Expect expression."
+    },
+    {
+      "file": "a.lox",
+      "line": 4,
+      "column": 5,
+      "length": 0,
+      "severity": "warning",
+      "phase": "lint",
+      "code": "unused-variable",
+      "message": "Local variable 'x' is never read."
+    }
+  ]
+}
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestWriteJSONWithoutRecords(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, JSON, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "{\n  \"version\": 1,\n  \"diagnostics\": []\n}\n"; out.String() != want {
+		t.Errorf("got %q, want %q", out.String(), want)
+	}
+}
+
+func TestWriteSARIF(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, SARIF, sample); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "version": "2.1.0",
+  "$schema": "https://json.schemastore.org/sarif-2.1.0.json",
+  "runs": [
+    {
+      "tool": {
+        "driver": {
+          "name": "glox",
+          "rules": [
+            {
+              "id": "L0101",
+              "shortDescription": {
+                "text": "Expect expression."
+              }
+            },
+            {
+              "id": "unused-variable"
+            }
+          ]
+        }
+      },
+      "results": [
+        {
+          "ruleId": "L0101",
+          "level": "error",
+          "message": {
+            "text": "Expect expression."
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "a.lox"
+                },
+                "region": {
+                  "startLine": 2,
+                  "startColumn": 9,
+                  "endColumn": 10
+                }
+              }
+            }
+          ],
+          "properties": {
+            "phase": "parse"
+          }
+        },
+        {
+          "ruleId": "unused-variable",
+          "level": "warning",
+          "message": {
+            "text": "Local variable 'x' is never read."
+          },
+          "locations": [
+            {
+              "physicalLocation": {
+                "artifactLocation": {
+                  "uri": "a.lox"
+                },
+                "region": {
+                  "startLine": 4,
+                  "startColumn": 5,
+                  "endColumn": 6
+                }
+              }
+            }
+          ],
+          "properties": {
+            "phase": "lint"
+          }
+        }
+      ]
+    }
+  ]
+}
+`
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestRecordsFromStaticErrors(t *testing.T) {
+	src := "var a = 1 @ 2;\nreturn a;\n"
+	diagnostics := shared.Collect(func() {
+		statements := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+		resolver.NewResolver(interpreter.NewInterpreter()).Resolve(statements)
+	})
+
+	want := []Record{
+		{File: "s.lox", Line: 1, Column: 11, Length: 1, Severity: Error, Phase: codes.Scan, Code: "L0001", Message: "Unexpected character."},
+		{File: "s.lox", Line: 1, Column: 13, Length: 1, Severity: Error, Phase: codes.Parse, Code: "L0109", Message: "Expect ';' after variable declaration."},
+		{File: "s.lox", Line: 2, Column: 1, Length: 6, Severity: Error, Phase: codes.Resolve, Code: "L0203", Message: "Can't return from top-level code."},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diagnostics)
+	}
+	for i, diag := range diagnostics {
+		if got := FromDiagnostic("s.lox", diag); got != want[i] {
+			t.Errorf("record %d: got %+v, want %+v", i, got, want[i])
+		}
+	}
+}
+
+func TestRecordFromRuntimeError(t *testing.T) {
+	in := interpreter.NewInterpreter()
+	in.SetOutput(io.Discard, io.Discard)
+	statements := parser.NewParser(scanner.NewScanner("var s = \"a\";\nprint s.b;\n").ScanTokens()).Parse()
+	resolver.NewResolver(in).Resolve(statements)
+	in.Interpret(statements)
+
+	rt, ok := in.LastError()
+	if !ok {
+		t.Fatal("expected a runtime error")
+	}
+	want := Record{File: "r.lox", Line: 2, Column: 9, Length: 1, Severity: Error, Phase: codes.Runtime, Code: "L0308", Message: "Only instances have properties."}
+	if got := FromRuntimeError("r.lox", rt); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestFormatFlag(t *testing.T) {
+	var format Format
+	flags := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.Var(&format, "diagnostics", "")
+
+	if format.Structured() || format.String() != "text" {
+		t.Errorf("expected text by default, got %q", format.String())
+	}
+	if err := flags.Parse([]string{"--diagnostics=sarif"}); err != nil || format != SARIF {
+		t.Errorf("expected sarif, got %q (%v)", format, err)
+	}
+	if err := flags.Parse([]string{"--diagnostics=xml"}); err == nil {
+		t.Error("expected an unknown format to be rejected")
+	}
+}
@@ -0,0 +1,118 @@
+package diagnostics
+
+import (
+	"sort"
+
+	"example.com/golox/lox/codes"
+)
+
+// The subset of SARIF 2.1.0 that glox produces. See
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html.
+
+const (
+	sarifVersion = "2.1.0"
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+)
+
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name  string      `json:"name"`
+	Rules []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string        `json:"id"`
+	ShortDescription *sarifMessage `json:"shortDescription,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string          `json:"ruleId"`
+	Level      Severity        `json:"level"`
+	Message    sarifMessage    `json:"message"`
+	Locations  []sarifLocation `json:"locations"`
+	Properties sarifProperties `json:"properties"`
+}
+
+type sarifProperties struct {
+	Phase codes.Phase `json:"phase"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine   int `json:"startLine"`
+	StartColumn int `json:"startColumn,omitempty"`
+	EndColumn   int `json:"endColumn,omitempty"`
+}
+
+// sarif builds a log with one run holding every record. Each code that
+// appears becomes a rule, described by its message when it is one of
+// glox's own error codes.
+func sarif(records []Record) sarifLog {
+	run := sarifRun{
+		Tool:    sarifTool{Driver: sarifDriver{Name: "glox", Rules: []sarifRule{}}},
+		Results: []sarifResult{},
+	}
+
+	seen := map[string]bool{}
+	for _, r := range records {
+		if !seen[r.Code] {
+			seen[r.Code] = true
+			rule := sarifRule{ID: r.Code}
+			if code, ok := codes.Lookup(r.Code); ok {
+				rule.ShortDescription = &sarifMessage{Text: code.Message}
+			}
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		region := sarifRegion{StartLine: r.Line}
+		if r.Column > 0 {
+			region.StartColumn = r.Column
+			region.EndColumn = r.Column + max(r.Length, 1)
+		}
+		run.Results = append(run.Results, sarifResult{
+			RuleID:  r.Code,
+			Level:   r.Severity,
+			Message: sarifMessage{Text: r.Message},
+			Locations: []sarifLocation{{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: r.File},
+				Region:           region,
+			}}},
+			Properties: sarifProperties{Phase: r.Phase},
+		})
+	}
+	sort.Slice(run.Tool.Driver.Rules, func(i, j int) bool {
+		return run.Tool.Driver.Rules[i].ID < run.Tool.Driver.Rules[j].ID
+	})
+
+	return sarifLog{Version: sarifVersion, Schema: sarifSchema, Runs: []sarifRun{run}}
+}
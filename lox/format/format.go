@@ -0,0 +1,429 @@
+// Package format prints Lox programs in a canonical style: two-space
+// indentation, one statement per line, braces on the line that opens them
+// and single spaces around binary operators. Comments are carried over
+// from the source by token: one written after a token stays after it, and
+// one on a line of its own stays before the token that follows it, so
+// formatting never drops or moves one.
+package format
+
+import (
+	"strconv"
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/cst"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+const indentUnit = "  "
+
+// Source formats a whole program. If src does not parse, it returns the
+// syntax errors, without printing them, as a shared.Errors.
+func Source(src string) (string, error) {
+	tree := cst.Parse(src)
+	if len(tree.Diagnostics) > 0 {
+		return "", shared.Errors(tree.Diagnostics)
+	}
+
+	f := &formatter{tree: tree, tokens: tree.Root.Tokens()}
+	f.statements(tree.Statements)
+	f.newline = true
+	f.comments()
+	if f.open {
+		f.out.WriteString("\n")
+	}
+	return f.out.String(), nil
+}
+
+// The formatter prints the program token by token, in the order the
+// source has them, and takes each token's comments from its trivia as it
+// goes. A line that a comment breaks in the middle of a statement goes on
+// one level deeper than the statement.
+type formatter struct {
+	out    strings.Builder
+	indent int
+
+	tree   *cst.Tree
+	tokens []*cst.Token
+	next   int // the source token printed next
+	shown  int // one past the last token whose leading comments are printed
+
+	// open is set while the current output line has something on it.
+	// space asks for a space before the next token, and newline for it to
+	// start a line at the current indentation. broken is set after a
+	// comment that ends a line, and start before a statement's first token.
+	open    bool
+	space   bool
+	newline bool
+	broken  bool
+	start   bool
+
+	// last is the source line of whatever was printed most recently and
+	// opened is set right after a '{', both used to keep blank lines.
+	last   int
+	opened bool
+}
+
+// token prints the next source token as text, with the comments around
+// it. A token that a comment pushed onto a new line is indented one level
+// deeper, unless it is aligned with the statement, as braces and "else" are.
+func (f *formatter) token(text string, aligned bool) {
+	f.comments()
+	token := f.tokens[f.next]
+	f.next++
+
+	if f.open && (f.newline || f.broken) {
+		f.out.WriteString("\n")
+		f.open = false
+	}
+	if !f.open {
+		if f.newline && f.start {
+			f.separate(ast.TokenSpan(token.Token).Start.Line)
+		}
+		f.writeIndent(!f.newline && !aligned)
+	} else if f.space {
+		f.out.WriteString(" ")
+	}
+	f.out.WriteString(text)
+	f.open = true
+	f.space, f.newline, f.broken, f.start, f.opened = false, false, false, false, false
+	f.last = token.Line
+
+	if comment, ok := f.trailing(); ok {
+		f.out.WriteString(" " + comment)
+		f.broken = true
+	}
+}
+
+// write prints the next source token as text, as part of the statement.
+func (f *formatter) write(text string) {
+	f.token(text, false)
+}
+
+func (f *formatter) writeIndent(hanging bool) {
+	depth := f.indent
+	if hanging {
+		depth++
+	}
+	f.out.WriteString(strings.Repeat(indentUnit, depth))
+}
+
+// trailing returns the comment written after the token just printed, on
+// the same source line.
+func (f *formatter) trailing() (string, bool) {
+	if f.next == len(f.tokens) {
+		return "", false
+	}
+	for _, trivia := range f.tokens[f.next].Leading {
+		switch trivia.Type {
+		case scanner.NEWLINE:
+			return "", false
+		case scanner.COMMENT:
+			return strings.TrimRight(trivia.Lexeme, "\r"), true
+		}
+	}
+	return "", false
+}
+
+// comments prints, each on a line of its own, the comments on the lines
+// before the next source token.
+func (f *formatter) comments() {
+	if f.shown > f.next {
+		return
+	}
+	f.shown = f.next + 1
+	own := f.next == 0
+	for _, trivia := range f.tokens[f.next].Leading {
+		switch trivia.Type {
+		case scanner.NEWLINE:
+			own = true
+		case scanner.COMMENT:
+			if !own {
+				continue // printed after the token before it
+			}
+			if f.open {
+				f.out.WriteString("\n")
+				f.open = false
+			}
+			if f.newline {
+				f.separate(trivia.Line)
+			}
+			f.writeIndent(!f.newline)
+			f.out.WriteString(strings.TrimRight(trivia.Lexeme, "\r") + "\n")
+			f.last = trivia.Line
+			f.broken, f.opened = false, false
+		}
+	}
+}
+
+// commented reports whether comments come before the next source token,
+// on lines of their own or after the token before it.
+func (f *formatter) commented() bool {
+	for _, trivia := range f.tokens[f.next].Leading {
+		if trivia.Type == scanner.COMMENT {
+			return true
+		}
+	}
+	return false
+}
+
+// separate keeps one blank line where the source had at least one before
+// source line line, except straight after an opening brace.
+func (f *formatter) separate(line int) {
+	if f.last > 0 && !f.opened && line > f.last+1 {
+		f.out.WriteString("\n")
+	}
+}
+
+func (f *formatter) statements(list []ast.Stmt) {
+	for _, stmt := range list {
+		f.newline, f.start = true, true
+		f.statement(stmt)
+	}
+}
+
+// statement prints stmt from the current position on the line, so that
+// bodies can follow their "if (...)" or "else" directly.
+func (f *formatter) statement(stmt ast.Stmt) {
+	if loop := f.tree.ForLoop(stmt); loop != nil {
+		f.write("for")
+		f.space = true
+		f.write("(")
+		switch init := loop.Initializer.(type) {
+		case *ast.Var:
+			f.varDecl(init)
+		case *ast.Expression:
+			f.expr(init.Expression)
+			f.write(";")
+		default:
+			f.write(";")
+		}
+		if loop.Condition != nil {
+			f.space = true
+			f.expr(loop.Condition)
+		}
+		f.write(";")
+		if loop.Increment != nil {
+			f.space = true
+			f.expr(loop.Increment)
+		}
+		f.write(")")
+		f.body(loop.Body)
+		return
+	}
+
+	switch s := stmt.(type) {
+	case *ast.Block:
+		f.block(s.Statements)
+	case *ast.Class:
+		f.write("class")
+		f.space = true
+		f.write(s.Name.Lexeme)
+		if super, ok := s.Superclass.(*ast.Variable); ok {
+			f.space = true
+			f.write("<")
+			f.space = true
+			f.write(super.Name.Lexeme)
+		}
+		f.space = true
+		f.token("{", true)
+		if len(s.Methods) == 0 && !f.broken && !f.commented() {
+			f.write("}")
+			return
+		}
+		f.indent++
+		f.opened = true
+		for _, method := range s.Methods {
+			f.newline, f.start = true, true
+			f.function(method)
+		}
+		f.close()
+	case *ast.Expression:
+		f.expr(s.Expression)
+		f.write(";")
+	case *ast.Function:
+		f.write("fun")
+		f.space = true
+		f.function(s)
+	case *ast.If:
+		f.write("if")
+		f.space = true
+		f.write("(")
+		f.expr(s.Condition)
+		f.write(")")
+		if then, ok := s.ThenBranch.(*ast.Block); ok && s.ElseBranch != nil {
+			f.space = true
+			f.block(then.Statements)
+			f.space = true
+			f.token("else", true)
+			f.body(s.ElseBranch)
+			return
+		}
+		f.body(s.ThenBranch)
+		if s.ElseBranch != nil {
+			f.newline = true
+			f.token("else", true)
+			f.body(s.ElseBranch)
+		}
+	case *ast.Print:
+		f.write("print")
+		f.space = true
+		f.expr(s.Expression)
+		f.write(";")
+	case *ast.Return:
+		f.write("return")
+		if s.Value != nil {
+			f.space = true
+			f.expr(s.Value)
+		}
+		f.write(";")
+	case *ast.Var:
+		f.varDecl(s)
+	case *ast.While:
+		f.write("while")
+		f.space = true
+		f.write("(")
+		f.expr(s.Condition)
+		f.write(")")
+		f.body(s.Body)
+	}
+}
+
+// body prints the statement an if, else, while or for runs, on the same
+// line unless a comment ends that line.
+func (f *formatter) body(stmt ast.Stmt) {
+	f.space = true
+	f.statement(stmt)
+}
+
+func (f *formatter) function(fn *ast.Function) {
+	f.write(fn.Name.Lexeme)
+	f.write("(")
+	for i, param := range fn.Params {
+		if i > 0 {
+			f.write(",")
+			f.space = true
+		}
+		f.write(param.Lexeme)
+	}
+	f.write(")")
+	f.space = true
+	f.block(fn.Body)
+}
+
+// block prints braces around statements, leaving the closing brace's line
+// unfinished so the caller can continue it.
+func (f *formatter) block(statements []ast.Stmt) {
+	f.token("{", true)
+	if len(statements) == 0 && !f.broken && !f.commented() {
+		f.write("}")
+		return
+	}
+	f.indent++
+	f.opened = true
+	f.statements(statements)
+	f.close()
+}
+
+// close prints the comments before a closing brace inside the braces, and
+// the brace on a line of its own.
+func (f *formatter) close() {
+	f.newline = true
+	f.comments()
+	f.indent--
+	f.newline = true
+	f.token("}", true)
+}
+
+func (f *formatter) varDecl(v *ast.Var) {
+	f.write("var")
+	f.space = true
+	f.write(v.Name.Lexeme)
+	if v.Initializer != nil {
+		f.space = true
+		f.write("=")
+		f.space = true
+		f.expr(v.Initializer)
+	}
+	f.write(";")
+}
+
+// expr prints an expression on one line, unless comments inside it break
+// the line. Parentheses come from Grouping nodes, so they are kept exactly
+// as written.
+func (f *formatter) expr(e ast.Expr) {
+	switch e := e.(type) {
+	case *ast.Assign:
+		f.write(e.Name.Lexeme)
+		f.operator("=")
+		f.expr(e.Value)
+	case *ast.Binary:
+		f.expr(e.Left)
+		f.operator(e.Operator.Lexeme)
+		f.expr(e.Right)
+	case *ast.Call:
+		f.expr(e.Callee)
+		f.write("(")
+		for i, arg := range e.Arguments {
+			if i > 0 {
+				f.write(",")
+				f.space = true
+			}
+			f.expr(arg)
+		}
+		f.write(")")
+	case *ast.Get:
+		f.expr(e.Object)
+		f.write(".")
+		f.write(e.Name.Lexeme)
+	case *ast.Grouping:
+		f.write("(")
+		f.expr(e.Expression)
+		f.write(")")
+	case *ast.Literal:
+		f.write(literal(e.Value))
+	case *ast.Logical:
+		f.expr(e.Left)
+		f.operator(e.Operator.Lexeme)
+		f.expr(e.Right)
+	case *ast.Set:
+		f.expr(e.Object)
+		f.write(".")
+		f.write(e.Name.Lexeme)
+		f.operator("=")
+		f.expr(e.Value)
+	case *ast.Super:
+		f.write("super")
+		f.write(".")
+		f.write(e.Method.Lexeme)
+	case *ast.This:
+		f.write("this")
+	case *ast.Unary:
+		f.write(e.Operator.Lexeme)
+		f.expr(e.Right)
+	case *ast.Variable:
+		f.write(e.Name.Lexeme)
+	}
+}
+
+// operator prints a binary operator with a space on each side.
+func (f *formatter) operator(text string) {
+	f.space = true
+	f.write(text)
+	f.space = true
+}
+
+func literal(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		return `"` + v + `"`
+	}
+	return ""
+}
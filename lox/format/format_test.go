@@ -0,0 +1,185 @@
+package format
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func formatted(t *testing.T, src string) string {
+	t.Helper()
+	out, err := Source(src)
+	if err != nil {
+		t.Fatalf("Source returned error: %v\nsource:\n%s", err, src)
+	}
+	return out
+}
+
+func TestFormatsStatementsAndExpressions(t *testing.T) {
+	src := `var a=1;var b ;
+fun add(x,y){return x+y;}
+class Foo<Bar{init(x){this.x=x;}get(){return super.get();}}
+if(a==1){print "one";}else if(a==2)print "two";else{print -a*(2+3.50);}
+while(a<10)a=a+1;
+print !true and nil or a.b.c(1,"s");
+`
+	want := `var a = 1;
+var b;
+fun add(x, y) {
+  return x + y;
+}
+class Foo < Bar {
+  init(x) {
+    this.x = x;
+  }
+  get() {
+    return super.get();
+  }
+}
+if (a == 1) {
+  print "one";
+} else if (a == 2) print "two";
+else {
+  print -a * (2 + 3.5);
+}
+while (a < 10) a = a + 1;
+print !true and nil or a.b.c(1, "s");
+`
+	if got := formatted(t, src); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestKeepsForLoops(t *testing.T) {
+	src := `for(var i=0;i<3;i=i+1)print i;
+for(;;){}
+for(i=0;;)print i;
+`
+	want := `for (var i = 0; i < 3; i = i + 1) print i;
+for (;;) {}
+for (i = 0;;) print i;
+`
+	if got := formatted(t, src); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestKeepsCommentsAndBlankLines(t *testing.T) {
+	src := `// header
+
+
+var a = 1;   // trailing
+fun f() {
+    // inside
+
+    print a; // after print
+  // before brace
+}
+// footer
+`
+	want := `// header
+
+var a = 1; // trailing
+fun f() {
+  // inside
+
+  print a; // after print
+  // before brace
+}
+// footer
+`
+	if got := formatted(t, src); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestKeepsCommentsAfterTheTokenTheyFollow(t *testing.T) {
+	tests := []struct {
+		name, src, want string
+	}{
+		{
+			"after an if header",
+			"if (x) // c\n  print x; else {print y;}\n",
+			"if (x) // c\n  print x;\nelse {\n  print y;\n}\n",
+		},
+		{
+			"after else",
+			"if (x) print x; else // c\n  print y;\n",
+			"if (x) print x;\nelse // c\n  print y;\n",
+		},
+		{
+			"after a then block",
+			"if (x) {print x;} // c\nelse print y;\n",
+			"if (x) {\n  print x;\n} // c\nelse print y;\n",
+		},
+		{
+			"after a for header",
+			"for (var i = 0; i < 3; i = i + 1) // c\n  print i;\nprint 2;\n",
+			"for (var i = 0; i < 3; i = i + 1) // c\n  print i;\nprint 2;\n",
+		},
+		{
+			"inside a for header",
+			"for (var i = 0; // a\ni < 3; // b\ni = i + 1) print i;\n",
+			"for (var i = 0; // a\n  i < 3; // b\n  i = i + 1) print i;\n",
+		},
+		{
+			"after a while header",
+			"while (i < 3) // c\n{i = i + 1;}\n",
+			"while (i < 3) // c\n{\n  i = i + 1;\n}\n",
+		},
+		{
+			"inside a while condition",
+			"while (x // c\n) print x;\n",
+			"while (x // c\n  ) print x;\n",
+		},
+		{
+			"on its own line in an expression",
+			"print 1 +\n// c\n2;\n",
+			"print 1 +\n  // c\n  2;\n",
+		},
+		{
+			"after an opening brace",
+			"class A { // c\n}\n{ // d\n}\n",
+			"class A { // c\n}\n{ // d\n}\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatted(t, tt.src)
+			if got != tt.want {
+				t.Fatalf("got:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if again := formatted(t, got); again != got {
+				t.Fatalf("formatting is not idempotent:\n%s", again)
+			}
+		})
+	}
+}
+
+func TestReportsSyntaxErrors(t *testing.T) {
+	_, err := Source("var = 1;")
+	if err == nil || !strings.Contains(err.Error(), "Expect variable name.") {
+		t.Fatalf("expected a syntax error, got %v", err)
+	}
+}
+
+func TestExamplesAreIdempotentAndKeepComments(t *testing.T) {
+	paths, err := filepath.Glob("../../examples/*.lox")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no examples found: %v", err)
+	}
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		once := formatted(t, string(data))
+		if twice := formatted(t, once); twice != once {
+			t.Errorf("%s: formatting is not idempotent:\n%s", path, twice)
+		}
+		if got, want := strings.Count(once, "//"), strings.Count(string(data), "//"); got != want {
+			t.Errorf("%s: %d comments after formatting, want %d", path, got, want)
+		}
+	}
+}
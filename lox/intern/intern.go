@@ -0,0 +1,39 @@
+// Package intern keeps one canonical copy of each identifier and string
+// literal so that equal names share storage. Comparing two canonical
+// copies with == then stops at their data pointers instead of reading
+// their content.
+package intern
+
+import "strings"
+
+// Table is an intern table. It is not safe for concurrent use; each
+// interpreter owns its own table.
+type Table struct {
+	strings map[string]string
+}
+
+func NewTable() *Table {
+	return &Table{strings: make(map[string]string)}
+}
+
+// Intern returns the canonical copy of s, adding it to the table if it is
+// new. The canonical copy is cloned so it never pins a larger source
+// buffer s may have been sliced from.
+func (t *Table) Intern(s string) string {
+	if canonical, ok := t.strings[s]; ok {
+		return canonical
+	}
+	canonical := strings.Clone(s)
+	t.strings[canonical] = canonical
+	return canonical
+}
+
+// Lookup returns the canonical copy of s if it has been interned.
+func (t *Table) Lookup(s string) (string, bool) {
+	canonical, ok := t.strings[s]
+	return canonical, ok
+}
+
+func (t *Table) Len() int {
+	return len(t.strings)
+}
@@ -0,0 +1,54 @@
+package intern
+
+import "testing"
+
+func TestInternReturnsCanonicalCopy(t *testing.T) {
+	table := NewTable()
+
+	source := "var count = count + 1;"
+	first := table.Intern(source[4:9])
+	second := table.Intern(source[12:17])
+
+	if first != "count" || second != "count" {
+		t.Fatalf("expected both to be %q, got %q and %q", "count", first, second)
+	}
+	if table.Len() != 1 {
+		t.Fatalf("expected 1 entry, got %d", table.Len())
+	}
+	// Interning a string again hands back the canonical copy rather than
+	// making another.
+	if allocs := testing.AllocsPerRun(10, func() { table.Intern(source[12:17]) }); allocs != 0 {
+		t.Fatalf("expected interning a known string not to allocate, got %v allocs", allocs)
+	}
+}
+
+func TestInternClonesNewStrings(t *testing.T) {
+	table := NewTable()
+	source := "abcdefghijklmnopqrstuvwxyz"
+	next := 0
+
+	// Each call interns a new slice of source, which must be copied so the
+	// table does not keep source alive.
+	allocs := testing.AllocsPerRun(10, func() {
+		table.Intern(source[next : next+3])
+		next++
+	})
+	if allocs < 1 {
+		t.Fatalf("expected a new string to be copied, got %v allocs", allocs)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	table := NewTable()
+	name := table.Intern("init")
+
+	if got, ok := table.Lookup("init"); !ok || got != name {
+		t.Fatalf("expected Lookup to return the canonical copy")
+	}
+	if _, ok := table.Lookup("missing"); ok {
+		t.Fatalf("did not expect Lookup to find an unknown string")
+	}
+	if table.Len() != 1 {
+		t.Fatalf("expected Lookup not to add entries, got %d", table.Len())
+	}
+}
@@ -1,7 +1,7 @@
 package interpreter
 
 type LoxCallable interface {
-	Call(in *Interpreter, arguments []any) any
+	Call(in *Interpreter, arguments []Value) Value
 
 	Arity() int
 }
\ No newline at end of file
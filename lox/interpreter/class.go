@@ -1,33 +1,57 @@
 package interpreter
 
-import "fmt"
+import (
+	"fmt"
+	"sort"
+)
 
 type LoxClass struct {
     Name string
     Superclass *LoxClass
-	Methods map[string]*LoxFunction
+
+    // methods are the class's own methods, read through FindMethod and
+    // MethodNames. They are fixed when the class is created, since
+    // methodNames and methodList mirror them.
+    methods map[string]*LoxFunction
+
+    // rootShape is the empty layout every new instance starts from.
+    rootShape *Shape
+
+    // methodNames and methodList mirror methods for small classes, which
+    // are searched by scanning rather than hashing.
+    methodNames []string
+    methodList  []*LoxFunction
 }
 
 func NewLoxClass(name string, superclass *LoxClass, methods map[string]*LoxFunction) *LoxClass {
-    return &LoxClass{
+    class := &LoxClass{
         Name:       name,
         Superclass: superclass,
-        Methods:    methods,
+        methods:    methods,
+        rootShape:  newRootShape(),
+    }
+
+    if len(methods) <= envScanLimit {
+        for methodName, m := range methods {
+            class.methodNames = append(class.methodNames, methodName)
+            class.methodList = append(class.methodList, m)
+        }
     }
+    return class
 }
 
 func (c *LoxClass) String() string {
     return fmt.Sprintf("<class %s>", c.Name)
 }
 
-func (c *LoxClass) Call(in *Interpreter, arguments []any) any {
+func (c *LoxClass) Call(in *Interpreter, arguments []Value) Value {
     instance := NewLoxInstance(c)
 
     if initializer := c.FindMethod("init"); initializer != nil {
         initializer.Bind(instance).Call(in, arguments)
     }
 
-    return instance
+    return Object(instance)
 }
 
 func (c *LoxClass) Arity() int {
@@ -37,8 +61,36 @@ func (c *LoxClass) Arity() int {
     return 0
 }
 
+// MethodNames lists the methods of the class and then those it inherits,
+// each class's in alphabetical order. An overridden method is listed once.
+func (c *LoxClass) MethodNames() []string {
+    var names []string
+    seen := map[string]bool{}
+    for class := c; class != nil; class = class.Superclass {
+        own := make([]string, 0, len(class.methods))
+        for name := range class.methods {
+            if !seen[name] {
+                seen[name] = true
+                own = append(own, name)
+            }
+        }
+        sort.Strings(own)
+        names = append(names, own...)
+    }
+    return names
+}
+
 func (c *LoxClass) FindMethod(name string) *LoxFunction {
-    if m, ok := c.Methods[name]; ok {
+    // Method names and property lookups are usually both interned by the
+    // scanner, and == checks the data pointers before the bytes, so
+    // scanning small classes is a run of pointer compares.
+    if len(c.methods) <= envScanLimit {
+        for i, methodName := range c.methodNames {
+            if methodName == name {
+                return c.methodList[i]
+            }
+        }
+    } else if m, ok := c.methods[name]; ok {
         return m
     }
 
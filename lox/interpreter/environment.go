@@ -1,53 +1,74 @@
 package interpreter
 
 import (
-	"fmt"
-
+	"example.com/golox/lox/codes"
 	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/suggest"
 )
 
+// envScanLimit is the number of variables above which an environment
+// builds a map for lookups instead of scanning its names.
+const envScanLimit = 8
 
+// Environment stores variables in definition order. Most environments
+// belong to a single call or block and hold a handful of names, so they
+// are scanned comparing interned names by pointer; larger ones, such as
+// the globals, switch to a map.
 type Environment struct {
 	enclosing *Environment
-	values map[string]any
+	names  []string
+	values []Value
+	index  map[string]int
 }
 
 func NewEnvironment() *Environment {
 	return &Environment{
 		enclosing: nil,
-		values: make(map[string]any),
 	}
 }
 
 func NewEnclosedEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
 		enclosing: enclosing,
-		values:    make(map[string]any),
 	}
 }
 
-func (env *Environment) Get(name scanner.Token) any {
-	if val, ok := env.values[name.Lexeme]; ok {
-		return val
+func (env *Environment) lookup(name string) (int, bool) {
+	if env.index != nil {
+		slot, ok := env.index[name]
+		return slot, ok
 	}
-	
-	if env.enclosing != nil {
-		return env.enclosing.Get(name)
+	for i, n := range env.names {
+		if n == name {
+			return i, true
+		}
 	}
+	return 0, false
+}
 
-	panic(RuntimeError{
-		Token:   name,
-		Message: fmt.Sprintf("Undefined variable '%s'.", name.Lexeme),
-	})
+func (env *Environment) Get(name scanner.Token) Value {
+	if value, ok := env.get(name.Lexeme); ok {
+		return value
+	}
+	panic(undefinedVariable(name, env))
 }
 
-func (env *Environment) GetAt(distance int, name string) any {
+func (env *Environment) get(name string) (Value, bool) {
+	for e := env; e != nil; e = e.enclosing {
+		if slot, ok := e.lookup(name); ok {
+			return e.values[slot], true
+		}
+	}
+	return Nil, false
+}
+
+func (env *Environment) GetAt(distance int, name string) Value {
 	environment := env.ancestor(distance)
-    if value, ok := environment.values[name]; ok {
-        return value
+    if slot, ok := environment.lookup(name); ok {
+        return environment.values[slot]
     }
     
-    return nil
+    return Nil
 }
 
 func (env *Environment) ancestor(distance int) *Environment {
@@ -61,28 +82,84 @@ func (env *Environment) ancestor(distance int) *Environment {
     return environment
 }
 
-func (env *Environment) Assign(name scanner.Token, value any) {
-	if _, ok := env.values[name.Lexeme]; ok {
-		env.values[name.Lexeme] = value
-		return
+func (env *Environment) Assign(name scanner.Token, value Value) {
+	if !env.assign(name.Lexeme, value) {
+		panic(undefinedVariable(name, env))
 	}
+}
 
-	if env.enclosing != nil {
-		env.enclosing.Assign(name, value)
-		return
+func (env *Environment) assign(name string, value Value) bool {
+	for e := env; e != nil; e = e.enclosing {
+		if slot, ok := e.lookup(name); ok {
+			e.values[slot] = value
+			return true
+		}
 	}
+	return false
+}
 
-	panic(RuntimeError{
-		Token:   name,
-		Message: fmt.Sprintf("Undefined variable '%s'.", name.Lexeme),
-	})
+// undefinedVariable is the error for a name that is not defined, with a
+// suggestion from the names visible in env.
+func undefinedVariable(name scanner.Token, env *Environment) RuntimeError {
+	rt := NewRuntimeError(name, codes.UndefinedVariable, name.Lexeme)
+	rt.Message += suggest.DidYouMean(name.Lexeme, env.Visible())
+	return rt
 }
 
-func (env *Environment) AssignAt(distance int, name scanner.Token, value any) {
+func (env *Environment) AssignAt(distance int, name scanner.Token, value Value) {
     environment := env.ancestor(distance)
-    environment.values[name.Lexeme] = value
+    environment.Define(name.Lexeme, value)
 }
 
-func (env *Environment) Define(name string, value any) {
-	env.values[name] = value
-}
\ No newline at end of file
+func (env *Environment) Define(name string, value Value) {
+	if slot, ok := env.lookup(name); ok {
+		env.values[slot] = value
+		return
+	}
+
+	env.names = append(env.names, name)
+	env.values = append(env.values, value)
+
+	if env.index != nil {
+		env.index[name] = len(env.names) - 1
+	} else if len(env.names) > envScanLimit {
+		env.index = make(map[string]int, len(env.names))
+		for i, n := range env.names {
+			env.index[n] = i
+		}
+	}
+}
+
+// Enclosing returns the environment this one is nested in, or nil for the
+// globals.
+func (env *Environment) Enclosing() *Environment {
+	return env.enclosing
+}
+
+// Names lists the variables defined directly in this environment, in
+// definition order.
+func (env *Environment) Names() []string {
+	return append([]string(nil), env.names...)
+}
+
+// Visible lists the names that can be read from this environment,
+// innermost first. A name hidden by an inner one is listed once.
+func (env *Environment) Visible() []string {
+	var names []string
+	seen := map[string]bool{}
+	for e := env; e != nil; e = e.enclosing {
+		for i := len(e.names) - 1; i >= 0; i-- {
+			if name := e.names[i]; !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// Has reports whether name is defined directly in this environment.
+func (env *Environment) Has(name string) bool {
+	_, ok := env.lookup(name)
+	return ok
+}
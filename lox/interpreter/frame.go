@@ -0,0 +1,179 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/scanner"
+)
+
+// Deep non-tail recursion ends in a runtime error instead of exhausting
+// the Go stack. The runtime stops a goroutine whose stack would grow past
+// 1GB on 64-bit platforms, and stacks grow by doubling, so a program gets
+// at most 512MB. Each frame is charged an estimate of the Go stack its
+// call uses, and a call that would take the total past stackBudget fails.
+//
+// The estimate depends on the callee's body: evaluating each level of
+// nested statements and expressions takes Go frames of its own. The costs
+// were measured under the race detector, whose frames are the largest; a
+// unary or binary expression takes up to 900 bytes there, a block about
+// 320 and a grouping about 220. Charging every level the most leaves the
+// rest of the budget, and the other half of the stack, as headroom.
+const (
+	stackBudget = 256 << 20
+	callCost    = 1 << 10
+	nestingCost = 1 << 10
+)
+
+// maxTraceFrames limits how many frames a runtime error prints.
+const maxTraceFrames = 20
+
+// CallFrame records one active Lox call for stack traces.
+type CallFrame struct {
+	Name string
+	// Line is where the call was made. After a tail call it is the line of
+	// the return statement that replaced the frame.
+	Line int
+	// Elided counts the tail calls that reused this frame.
+	Elided int
+	// Caller is the environment that was active where the call was made,
+	// which debuggers use to show the variables of outer frames.
+	Caller *Environment
+
+	// stack is the Go stack estimated to be used by this frame and all
+	// those outside it.
+	stack int
+}
+
+// tailCall is carried by a returnValue when a return statement in tail
+// position asks its caller to run another function in the same frame.
+type tailCall struct {
+	function  *LoxFunction
+	arguments []Value
+	line      int
+}
+
+func (in *Interpreter) pushFrame(callee LoxCallable, paren scanner.Token) {
+	stack := in.stackUse(len(in.frames)) + in.frameCost(callee)
+	if stack > stackBudget {
+		panic(NewRuntimeError(paren, codes.StackOverflow))
+	}
+	in.frames = append(in.frames, CallFrame{Name: callableName(callee), Line: paren.Line, Caller: in.environment, stack: stack})
+}
+
+func (in *Interpreter) popFrame() {
+	in.frames = in.frames[:len(in.frames)-1]
+}
+
+// elideFrame rewrites the innermost frame in place for a tail call.
+func (in *Interpreter) elideFrame(call *tailCall) {
+	if len(in.frames) == 0 {
+		return
+	}
+	top := &in.frames[len(in.frames)-1]
+	stack := in.stackUse(len(in.frames)-1) + in.frameCost(call.function)
+	if stack > stackBudget {
+		panic(NewRuntimeError(scanner.Token{Line: call.line}, codes.StackOverflow))
+	}
+	top.Name = callableName(call.function)
+	top.Line = call.line
+	top.Elided++
+	top.stack = stack
+}
+
+// stackUse is the Go stack estimated to be used by the outermost n frames.
+func (in *Interpreter) stackUse(n int) int {
+	if n == 0 {
+		return 0
+	}
+	return in.frames[n-1].stack
+}
+
+// frameCost estimates the Go stack a call of callee uses, up to the
+// deepest point in its body where it can call something else. A class
+// runs its initializer in the same frame.
+func (in *Interpreter) frameCost(callee LoxCallable) int {
+	var declaration *ast.Function
+	switch fn := callee.(type) {
+	case *LoxFunction:
+		declaration = fn.Declaration
+	case *LoxClass:
+		if initializer := fn.FindMethod("init"); initializer != nil {
+			declaration = initializer.Declaration
+		}
+	}
+	if declaration == nil {
+		return callCost
+	}
+
+	cost, ok := in.frameCosts[declaration]
+	if !ok {
+		if in.frameCosts == nil {
+			in.frameCosts = make(map[*ast.Function]int)
+		}
+		cost = callCost + nestingCost*(nesting(declaration)-1)
+		in.frameCosts[declaration] = cost
+	}
+	return cost
+}
+
+// nesting is how many levels of statements and expressions node has,
+// counting itself. A function or class declared inside it is one level:
+// its methods and body run in frames of their own.
+func nesting(node any) int {
+	deepest := 0
+	ast.Inspect(node, func(child any) bool {
+		if child == node {
+			return true
+		}
+		switch child.(type) {
+		case *ast.Function, *ast.Class:
+			deepest = max(deepest, 1)
+		default:
+			deepest = max(deepest, nesting(child))
+		}
+		return false
+	})
+	return deepest + 1
+}
+
+// CallStack returns a copy of the active frames, innermost last.
+func (in *Interpreter) CallStack() []CallFrame {
+	return append([]CallFrame(nil), in.frames...)
+}
+
+// CallDepth is the number of active Lox calls; it is 0 in top-level code.
+func (in *Interpreter) CallDepth() int {
+	return len(in.frames)
+}
+
+func (in *Interpreter) printStackTrace(w io.Writer) {
+	for i := len(in.frames) - 1; i >= 0; i-- {
+		if shown := len(in.frames) - 1 - i; shown == maxTraceFrames {
+			fmt.Fprintf(w, "  ... %d more frame(s)\n", i+1)
+			return
+		}
+
+		frame := in.frames[i]
+		fmt.Fprintf(w, "  in %s() called at line %d", frame.Name, frame.Line)
+		if frame.Elided > 0 {
+			fmt.Fprintf(w, " [%d tail call(s) elided]", frame.Elided)
+		}
+		fmt.Fprintln(w)
+	}
+}
+
+func callableName(callee LoxCallable) string {
+	switch fn := callee.(type) {
+	case *LoxFunction:
+		return fn.Declaration.Name.Lexeme
+	case *LoxClass:
+		return fn.Name
+	case *NativeFunction:
+		return fn.Name
+	default:
+		return "native"
+	}
+}
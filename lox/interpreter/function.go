@@ -7,7 +7,9 @@ import (
 )
 
 type returnValue struct {
-	Value any
+	Value Value
+	// tail is set when the return asks the caller to make a tail call.
+	tail *tailCall
 }
 
 type LoxFunction struct {
@@ -28,7 +30,28 @@ func (f *LoxFunction) Arity() int {
 	return len(f.Declaration.Params)
 }
 
-func (f *LoxFunction) Call(in *Interpreter, arguments []any) (result any) {
+// Call runs the function body. Tail calls made by the body come back as
+// pending calls and are run here in a loop, so a chain of them uses one
+// Go frame instead of one per call.
+func (f *LoxFunction) Call(in *Interpreter, arguments []Value) Value {
+    for {
+        if in.hooks != nil {
+            in.enterCall(f, arguments)
+        }
+        result, tail := f.invoke(in, arguments)
+        if tail == nil {
+            if in.hooks != nil {
+                in.exitCall(f, result)
+            }
+            return result
+        }
+
+        in.elideFrame(tail)
+        f, arguments = tail.function, tail.arguments
+    }
+}
+
+func (f *LoxFunction) invoke(in *Interpreter, arguments []Value) (result Value, tail *tailCall) {
     env := NewEnclosedEnvironment(f.Closure)
 
     for i, param := range f.Declaration.Params {
@@ -38,7 +61,9 @@ func (f *LoxFunction) Call(in *Interpreter, arguments []any) (result any) {
     defer func() {
         if r := recover(); r != nil {
             if rv, ok := r.(returnValue); ok {
-                if f.IsInitializer {
+                if rv.tail != nil {
+                    tail = rv.tail
+                } else if f.IsInitializer {
                     result = f.Closure.GetAt(0, "this")
                 } else {
                     result = rv.Value
@@ -50,7 +75,7 @@ func (f *LoxFunction) Call(in *Interpreter, arguments []any) (result any) {
             if f.IsInitializer {
                 result = f.Closure.GetAt(0, "this")
             } else {
-                result = nil
+                result = Nil
             }
         }
     }()
@@ -61,7 +86,7 @@ func (f *LoxFunction) Call(in *Interpreter, arguments []any) (result any) {
 
 func (f *LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
     env := NewEnclosedEnvironment(f.Closure)
-    env.Define("this", instance)
+    env.Define("this", Object(instance))
     return &LoxFunction{
         Declaration:   f.Declaration,
         Closure:       env,
@@ -72,4 +97,3 @@ func (f *LoxFunction) Bind(instance *LoxInstance) *LoxFunction {
 func (f *LoxFunction) String() string {
 	return fmt.Sprintf("<fn %s>", f.Declaration.Name.Lexeme)
 }
-
@@ -0,0 +1,120 @@
+package interpreter
+
+import "example.com/golox/lox/ast"
+
+// Hooks observe a running program. BeforeStatement is called from execute
+// for every statement, including each statement of a loop body or a
+// function, just before it runs. Debuggers and tracers implement it.
+type Hooks interface {
+	BeforeStatement(in *Interpreter, stmt ast.Stmt)
+}
+
+// CallHooks are Hooks that also observe calls of Lox functions, which
+// LoxFunction.Call reports. EnterCall is called once the call's frame is
+// pushed, so CallDepth counts it, and ExitCall as the function returns. A
+// function that ends in a tail call has no ExitCall: the call it makes
+// replaces it and is reported by EnterCall at the same depth.
+type CallHooks interface {
+	Hooks
+	EnterCall(in *Interpreter, fn *LoxFunction, arguments []Value)
+	ExitCall(in *Interpreter, fn *LoxFunction, result Value)
+}
+
+// SetHooks installs h, or removes the current hooks when h is nil.
+func (in *Interpreter) SetHooks(h Hooks) {
+	in.hooks = h
+}
+
+// enterCall and exitCall report a call to hooks that observe calls. They
+// are kept out of LoxFunction.Call, whose stack frame every Lox call pays
+// for.
+func (in *Interpreter) enterCall(fn *LoxFunction, arguments []Value) {
+	if h, ok := in.hooks.(CallHooks); ok {
+		h.EnterCall(in, fn, arguments)
+	}
+}
+
+func (in *Interpreter) exitCall(fn *LoxFunction, result Value) {
+	if h, ok := in.hooks.(CallHooks); ok {
+		h.ExitCall(in, fn, result)
+	}
+}
+
+// Environment returns the environment of the code that is running now.
+func (in *Interpreter) Environment() *Environment {
+	return in.environment
+}
+
+// Globals returns the global environment.
+func (in *Interpreter) Globals() *Environment {
+	return in.globals
+}
+
+// Evaluate runs expr as if it appeared in code whose environment is env,
+// as a debugger does when the program is stopped. Names are resolved
+// against env's scope chain when Evaluate is called, rather than by the
+// resolver, and forgotten when it returns. Hooks are not called while the
+// expression runs.
+func (in *Interpreter) Evaluate(expr ast.Expr, env *Environment) (value Value, err error) {
+	saved := map[ast.Expr]*int{}
+	resolve := func(expr ast.Expr, name string) {
+		if distance, ok := in.locals[expr]; ok {
+			saved[expr] = &distance
+		} else {
+			saved[expr] = nil
+		}
+		in.resolveIn(env, expr, name)
+	}
+	ast.Inspect(expr, func(node any) bool {
+		switch n := node.(type) {
+		case *ast.Variable:
+			resolve(n, n.Name.Lexeme)
+		case *ast.Assign:
+			resolve(n, n.Name.Lexeme)
+		case *ast.This:
+			resolve(n, "this")
+		case *ast.Super:
+			resolve(n, "super")
+		}
+		return true
+	})
+
+	depth := len(in.frames)
+	previous, hooks := in.environment, in.hooks
+	in.environment, in.hooks = env, nil
+	defer func() {
+		in.frames = in.frames[:depth]
+		in.environment, in.hooks = previous, hooks
+		for expr, distance := range saved {
+			if distance != nil {
+				in.locals[expr] = *distance
+			} else {
+				delete(in.locals, expr)
+			}
+		}
+		if r := recover(); r != nil {
+			rt, ok := r.(RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			err = rt
+		}
+	}()
+
+	return in.evaluate(expr), nil
+}
+
+// resolveIn records the distance from env to the scope defining name.
+// Names found only in the globals are left unresolved, as the resolver
+// would leave them.
+func (in *Interpreter) resolveIn(env *Environment, expr ast.Expr, name string) {
+	distance := 0
+	for scope := env; scope != nil && scope != in.globals; scope = scope.enclosing {
+		if scope.Has(name) {
+			in.Resolve(expr, distance)
+			return
+		}
+		distance++
+	}
+	delete(in.locals, expr)
+}
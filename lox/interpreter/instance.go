@@ -3,38 +3,74 @@ package interpreter
 import (
 	"fmt"
 
+	"example.com/golox/lox/codes"
 	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/suggest"
 )
 
 type LoxInstance struct {
     Class  *LoxClass
-    Fields map[string]any
+    shape  *Shape
+    values []Value
 }
 
 func NewLoxInstance(class *LoxClass) *LoxInstance {
     return &LoxInstance{
-        Class:  class,
-        Fields: make(map[string]any),
+        Class: class,
+        shape: class.rootShape,
     }
 }
 
-func (i *LoxInstance) Get(name scanner.Token) any {
-    if value, ok := i.Fields[name.Lexeme]; ok {
+func (i *LoxInstance) Get(name scanner.Token) Value {
+    if value, ok := i.Field(name.Lexeme); ok {
         return value
     }
 
 	if method := i.Class.FindMethod(name.Lexeme); method != nil {
-        return method.Bind(i) 
+        return Object(method.Bind(i))
     }
 
-    panic(RuntimeError{
-        Token:   name,
-        Message: fmt.Sprintf("Undefined property '%s'.", name.Lexeme),
-    })
+    panic(undefinedProperty(name, append(i.FieldNames(), i.Class.MethodNames()...)))
 }
 
-func (i *LoxInstance) Set(name scanner.Token, value any) {
-    i.Fields[name.Lexeme] = value
+// undefinedProperty is the error for a property that does not exist, with
+// a suggestion from the ones that do.
+func undefinedProperty(name scanner.Token, properties []string) RuntimeError {
+    rt := NewRuntimeError(name, codes.UndefinedProperty, name.Lexeme)
+    rt.Message += suggest.DidYouMean(name.Lexeme, properties)
+    return rt
+}
+
+func (i *LoxInstance) Set(name scanner.Token, value Value) {
+    i.SetField(name.Lexeme, value)
+}
+
+// Field returns the value stored in a field, ignoring methods.
+func (i *LoxInstance) Field(name string) (Value, bool) {
+    if slot, ok := i.shape.Lookup(name); ok {
+        return i.values[slot], true
+    }
+    return Nil, false
+}
+
+// SetField stores a field, adding it to the instance if it is new.
+func (i *LoxInstance) SetField(name string, value Value) {
+    if slot, ok := i.shape.Lookup(name); ok {
+        i.values[slot] = value
+        return
+    }
+
+    i.shape = i.shape.Transition(name)
+    i.values = append(i.values, value)
+}
+
+// FieldNames lists the instance's fields in the order they were added.
+func (i *LoxInstance) FieldNames() []string {
+    return i.shape.FieldNames()
+}
+
+func (i *LoxInstance) Shape() *Shape {
+    return i.shape
 }
 
 func (i *LoxInstance) String() string {
@@ -2,9 +2,12 @@ package interpreter
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/intern"
 	"example.com/golox/lox/scanner"
 	"example.com/golox/lox/shared"
 )
@@ -12,111 +15,246 @@ import (
 type RuntimeError struct {
 	Token   scanner.Token
 	Message string
+	Code    codes.Code
+}
+
+// NewRuntimeError returns the error of the given kind at token, with args
+// filling in its message.
+func NewRuntimeError(token scanner.Token, code codes.Code, args ...any) RuntimeError {
+	return RuntimeError{Token: token, Message: code.Format(args...), Code: code}
 }
 
 func (e RuntimeError) Error() string {
 	return e.Message
 }
 
+// Diagnostic describes e the way static errors are described, so it can
+// be shown against the source it came from.
+func (e RuntimeError) Diagnostic() shared.Diagnostic {
+	return shared.Diagnostic{
+		Line:    e.Token.Line,
+		Column:  e.Token.Column,
+		Length:  e.Token.Length,
+		Message: e.Message,
+		Code:    e.Code,
+	}
+}
+
 type Interpreter struct{
 	globals *Environment
 	environment *Environment
 	locals map[ast.Expr]int
+	tailCalls map[*ast.Return]bool
+	frames []CallFrame
+	// frameCosts caches frameCost for each function declaration.
+	frameCosts map[*ast.Function]int
+	strings *intern.Table
+	hooks Hooks
+	// lastError is the runtime error that ended the last program run.
+	lastError *RuntimeError
+	// source is the text of the program, quoted by runtime errors.
+	source string
+
+	stdout io.Writer
+	stderr io.Writer
 }
 
 func NewInterpreter() *Interpreter {
+	strings := intern.NewTable()
 	globals := NewEnvironment()
-	globals.Define("clock", ClockFn{})
+	globals.Define(strings.Intern("clock"), Object(ClockFn{}))
 
 	return &Interpreter{
 		globals: globals,
 		environment: globals,
+		strings: strings,
 	}
 }
 
+// SetOutput redirects what print statements write and where runtime
+// errors are reported. A nil writer means os.Stdout or os.Stderr, looked
+// up on each use.
+func (in *Interpreter) SetOutput(stdout, stderr io.Writer) {
+	in.stdout = stdout
+	in.stderr = stderr
+}
 
-func (in *Interpreter) evaluate(expr ast.Expr) any {
-	if expr == nil {
-		return nil
+// SetSource gives the interpreter the text of the program it runs, so a
+// runtime error can show the offending line underlined, as static errors
+// do. Leave it unset when the code that runs may come from more than one
+// text, as in a REPL, where the line would be quoted from the wrong one.
+func (in *Interpreter) SetSource(source string) {
+	in.source = source
+}
+
+func (in *Interpreter) outputs() (stdout, stderr io.Writer) {
+	stdout, stderr = in.stdout, in.stderr
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if stderr == nil {
+		stderr = os.Stderr
 	}
-	return expr.Accept(in)
+	return stdout, stderr
+}
+
+// Strings returns the interpreter's intern table. Scanners feeding this
+// interpreter should intern through it, and native functions can use it
+// to build names that compare by pointer with the program's own.
+func (in *Interpreter) Strings() *intern.Table {
+	return in.strings
+}
+
+// Intern returns the canonical copy of s in the interpreter's table.
+func (in *Interpreter) Intern(s string) string {
+	return in.strings.Intern(s)
+}
+
+// GlobalNames lists the names defined in the global environment.
+func (in *Interpreter) GlobalNames() []string {
+	return in.globals.Names()
+}
+
+// IsGlobal reports whether name is defined in the global environment.
+func (in *Interpreter) IsGlobal(name string) bool {
+	return in.globals.Has(name)
+}
+
+
+// evaluate dispatches on the concrete node type instead of going through
+// expr.Accept: the visitor interface returns any, and boxing every
+// intermediate Value into an interface would allocate on each operation.
+func (in *Interpreter) evaluate(expr ast.Expr) Value {
+	switch e := expr.(type) {
+	case nil:
+		return Nil
+	case *ast.Assign:
+		return in.VisitAssignExpr(e)
+	case *ast.Binary:
+		return in.VisitBinaryExpr(e)
+	case *ast.Call:
+		return in.VisitCallExpr(e)
+	case *ast.Get:
+		return in.VisitGetExpr(e)
+	case *ast.Grouping:
+		return in.VisitGroupingExpr(e)
+	case *ast.Literal:
+		return in.VisitLiteralExpr(e)
+	case *ast.Logical:
+		return in.VisitLogicalExpr(e)
+	case *ast.Set:
+		return in.VisitSetExpr(e)
+	case *ast.Super:
+		return in.VisitSuperExpr(e)
+	case *ast.This:
+		return in.VisitThisExpr(e)
+	case *ast.Unary:
+		return in.VisitUnaryExpr(e)
+	case *ast.Variable:
+		return in.VisitVariableExpr(e)
+	}
+
+	// The only other node is ast.Error.
+	unparsed(expr)
+	return Nil
+}
+
+// unparsed and unknownOperator panic for trees the parser never builds
+// into a program that runs: ones holding an ast.Error or ast.Bad left in
+// place of source that did not parse, or an operator its node can't
+// have. Such a tree can still come from elsewhere, as from
+// ast.DecodeJSON. They panic themselves, rather than return the error,
+// so that evaluate and the visitors that call them keep small Go stack
+// frames.
+func unparsed(node interface{ Extent() ast.Span }) {
+	span := node.Extent()
+	token := scanner.Token{Line: span.Start.Line, Column: span.Start.Column, Offset: span.Start.Offset, Length: span.Len()}
+	panic(NewRuntimeError(token, codes.Internal, "cannot run code that did not parse."))
+}
+
+func unknownOperator(operator *scanner.Token) {
+	panic(NewRuntimeError(*operator, codes.Internal, fmt.Sprintf("unknown operator '%s'.", operator.Lexeme)))
 }
 
-func (in *Interpreter) VisitLiteralExpr(expr *ast.Literal) any {
-	return expr.Value
+func (in *Interpreter) VisitLiteralExpr(expr *ast.Literal) Value {
+	return FromAny(expr.Value)
 }
 
-func (in *Interpreter) VisitGroupingExpr(expr *ast.Grouping) any {
+func (in *Interpreter) VisitGroupingExpr(expr *ast.Grouping) Value {
 	return in.evaluate(expr.Expression)
 }
 
-func (in *Interpreter) VisitUnaryExpr(expr *ast.Unary) any {
+func (in *Interpreter) VisitUnaryExpr(expr *ast.Unary) Value {
 	right := in.evaluate(expr.Right)
 
 	switch expr.Operator.Type {
 	case scanner.BANG:
-		return !isTruthy(right)
+		return Bool(!right.Truthy())
 	case scanner.MINUS:
 		checkNumberOperand(expr.Operator, right)
-		num := right.(float64)
-		return -num
+		return Number(-right.num)
 	}
 
-	// Unreachable 
-	return nil
+	unknownOperator(&expr.Operator)
+	return Nil
 }
 
-func (in *Interpreter) VisitBinaryExpr(expr *ast.Binary) any {
+func (in *Interpreter) VisitBinaryExpr(expr *ast.Binary) Value {
 	left := in.evaluate(expr.Left)
 	right := in.evaluate(expr.Right)
 
 	switch expr.Operator.Type {
 	case scanner.GREATER:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) > right.(float64)
+		return Bool(left.num > right.num)
 	case scanner.GREATER_EQUAL:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) >= right.(float64)
+		return Bool(left.num >= right.num)
 	case scanner.LESS:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) < right.(float64)
+		return Bool(left.num < right.num)
 	case scanner.LESS_EQUAL:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) <= right.(float64)
+		return Bool(left.num <= right.num)
 
 	case scanner.MINUS:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) - right.(float64)
+		return Number(left.num - right.num)
 	case scanner.PLUS:
-		if l, ok := left.(float64); ok {
-			if r, ok := right.(float64); ok {
-				return l + r
+		if left.IsNumber() {
+			if right.IsNumber() {
+				return Number(left.num + right.num)
 			}
-			panic(RuntimeError{Token: expr.Operator, Message: "Right operand must be a number."})
+			panic(NewRuntimeError(expr.Operator, codes.RightMustBeNumber))
 		}
 
-		if ls, ok := left.(string); ok {
-			if rs, ok := right.(string); ok {
-				return ls + rs
+		if ls, ok := left.AsString(); ok {
+			if rs, ok := right.AsString(); ok {
+				return String(ls + rs)
 			}
-			panic(RuntimeError{Token: expr.Operator, Message: "Right operand must be a string."})
+			panic(NewRuntimeError(expr.Operator, codes.RightMustBeString))
 		}
-		panic(RuntimeError{Token: expr.Operator, Message: "Operands must be two numbers or two strings."})
+		panic(NewRuntimeError(expr.Operator, codes.BadPlusOperands))
 
 	case scanner.SLASH:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) / right.(float64)
+		return Number(left.num / right.num)
 	case scanner.STAR:
 		checkNumberOperands(expr.Operator, left, right)
-		return left.(float64) * right.(float64)
+		return Number(left.num * right.num)
 
 	case scanner.BANG_EQUAL:
-		return !isEqual(left, right)
+		return Bool(!left.Equals(right))
 	case scanner.EQUAL_EQUAL:
-		return isEqual(left, right)
+		return Bool(left.Equals(right))
 	}
 
-	// Unreachable.
+	unknownOperator(&expr.Operator)
+	return Nil
+}
+
+func (in *Interpreter) VisitBadStmt(stmt *ast.Bad) any {
+	unparsed(stmt)
 	return nil
 }
 
@@ -127,12 +265,13 @@ func (in *Interpreter) VisitExpressionStmt(stmt *ast.Expression) any {
 
 func (in *Interpreter) VisitPrintStmt(stmt *ast.Print) any {
 	value := in.evaluate(stmt.Expression)
-	fmt.Println(stringify(value))
+	stdout, _ := in.outputs()
+	fmt.Fprintln(stdout, stringify(value))
 	return nil
 }
 
 func (in *Interpreter) VisitVarStmt(stmt *ast.Var) any { 
-	var value any = nil
+	value := Nil
 	if stmt.Initializer != nil {
 		value = in.evaluate(stmt.Initializer)
 	}
@@ -140,19 +279,19 @@ func (in *Interpreter) VisitVarStmt(stmt *ast.Var) any {
 	return nil
 }
 
-func (in *Interpreter) VisitAssignExpr(expr *ast.Assign) any {
+func (in *Interpreter) VisitAssignExpr(expr *ast.Assign) Value {
 	value := in.evaluate(expr.Value)
 
 	if distance, ok := in.locals[expr]; ok {
         in.environment.AssignAt(distance, expr.Name, value)
-    } else {
-        in.globals.Assign(expr.Name, value)
+    } else if !in.globals.assign(expr.Name.Lexeme, value) {
+        panic(undefinedVariable(expr.Name, in.environment))
     }
 
 	return value
 }
 
-func (in *Interpreter) VisitVariableExpr(expr *ast.Variable) any {
+func (in *Interpreter) VisitVariableExpr(expr *ast.Variable) Value {
 	return in.lookUpVariable(expr.Name, expr)
 }
 
@@ -163,7 +302,7 @@ func (in *Interpreter) VisitBlockStmt(stmt *ast.Block) any {
 }
 
 func (in *Interpreter) VisitIfStmt(stmt *ast.If) any {
-	if isTruthy(in.evaluate(stmt.Condition)) {
+	if in.evaluate(stmt.Condition).Truthy() {
 		in.execute(stmt.ThenBranch)
 	} else if stmt.ElseBranch != nil {
 		in.execute(stmt.ElseBranch)
@@ -171,15 +310,15 @@ func (in *Interpreter) VisitIfStmt(stmt *ast.If) any {
 	return nil
 }
 
-func (in *Interpreter) VisitLogicalExpr(expr *ast.Logical) any {
+func (in *Interpreter) VisitLogicalExpr(expr *ast.Logical) Value {
 	left := in.evaluate(expr.Left)
 
 	if expr.Operator.Type == scanner.OR {
-		if isTruthy(left) {
+		if left.Truthy() {
 			return left
 		}
 	} else {
-		if !isTruthy(left) {
+		if !left.Truthy() {
 			return left
 		}
 	}
@@ -188,46 +327,53 @@ func (in *Interpreter) VisitLogicalExpr(expr *ast.Logical) any {
 }
 
 func (in *Interpreter) VisitWhileStmt(stmt *ast.While) any {
-	for isTruthy(in.evaluate(stmt.Condition)) {
+	for in.evaluate(stmt.Condition).Truthy() {
 		in.execute(stmt.Body)
 	}
 	return nil
 }
 
-func (in *Interpreter) VisitCallExpr(expr *ast.Call) any {
+func (in *Interpreter) VisitCallExpr(expr *ast.Call) Value {
 	callee := in.evaluate(expr.Callee)
 
-	var arguments []any
+	arguments := make([]Value, 0, len(expr.Arguments))
 	for _, arguement := range expr.Arguments {
 		arguments = append(arguments, in.evaluate(arguement))
 	}
 
-	fn, ok := callee.(LoxCallable)
+	fn := checkCallable(callee, arguments, expr.Paren)
+
+	in.pushFrame(fn, expr.Paren)
+	result := fn.Call(in, arguments)
+	in.popFrame()
+	return result
+}
+
+func checkCallable(callee Value, arguments []Value, paren scanner.Token) LoxCallable {
+	fn, ok := callee.AsObject().(LoxCallable)
 	if !ok {
-		panic(RuntimeError{
-			Token: expr.Paren,
-			Message: "Can only call functions and classes.",
-		})
+		panic(NewRuntimeError(paren, codes.NotCallable))
 	}
 
 	if len(arguments) != fn.Arity() {
-		panic(RuntimeError{
-			Token: expr.Paren,
-			Message: fmt.Sprintf("Expected %d arguments but got %d.", fn.Arity(), len(arguments)),
-		})
+		panic(NewRuntimeError(paren, codes.WrongArity, fn.Arity(), len(arguments)))
 	}
 
-	return fn.Call(in, arguments)
+	return fn
 }
 
 func (in *Interpreter) VisitFunctionStmt(stmt *ast.Function) any {
     function := NewLoxFunction(stmt, in.environment, false)
-    in.environment.Define(stmt.Name.Lexeme, function)
+    in.environment.Define(stmt.Name.Lexeme, Object(function))
     return nil
 }
 
 func (in *Interpreter) VisitReturnStmt(stmt *ast.Return) any {
-	var value any = nil
+	if in.tailCalls[stmt] {
+		in.returnTailCall(stmt)
+	}
+
+	value := Nil
 	if stmt.Value != nil {
 		value = in.evaluate(stmt.Value)
 	}
@@ -241,28 +387,22 @@ func (in *Interpreter) VisitClassStmt(stmt *ast.Class) any {
         value := in.evaluate(stmt.Superclass)
 
         var ok bool
-        superclass, ok = value.(*LoxClass)
+        superclass, ok = value.AsObject().(*LoxClass)
         if !ok {
             if superVar, ok2 := stmt.Superclass.(*ast.Variable); ok2 {
-                panic(RuntimeError{
-                    Token:   superVar.Name,
-                    Message: "Superclass must be a class.",
-                })
+                panic(NewRuntimeError(superVar.Name, codes.SuperclassNotClass))
             }
-            panic(RuntimeError{
-                Token:   stmt.Name,
-                Message: "Superclass must be a class.",
-            })
+            panic(NewRuntimeError(stmt.Name, codes.SuperclassNotClass))
         }
     }
 
-    in.environment.Define(stmt.Name.Lexeme, nil)
+    in.environment.Define(stmt.Name.Lexeme, Nil)
 
     var previousEnv *Environment
     if superclass != nil {
         previousEnv = in.environment
         in.environment = NewEnclosedEnvironment(in.environment)
-        in.environment.Define("super", superclass)
+        in.environment.Define("super", Object(superclass))
     }
 
     methods := make(map[string]*LoxFunction)
@@ -278,32 +418,26 @@ func (in *Interpreter) VisitClassStmt(stmt *ast.Class) any {
         in.environment = previousEnv
     }
 
-    in.environment.Assign(stmt.Name, klass)
+    in.environment.Assign(stmt.Name, Object(klass))
     return nil
 }
 
-func (in *Interpreter) VisitGetExpr(expr *ast.Get) any {
+func (in *Interpreter) VisitGetExpr(expr *ast.Get) Value {
     object := in.evaluate(expr.Object)
 
-    if instance, ok := object.(*LoxInstance); ok {
+    if instance, ok := object.AsObject().(*LoxInstance); ok {
         return instance.Get(expr.Name)
     }
 
-    panic(RuntimeError{
-        Token:   expr.Name,
-        Message: "Only instances have properties.",
-    })
+    panic(NewRuntimeError(expr.Name, codes.OnlyInstancesProps))
 }
 
-func (in *Interpreter) VisitSetExpr(expr *ast.Set) any {
+func (in *Interpreter) VisitSetExpr(expr *ast.Set) Value {
     object := in.evaluate(expr.Object)
 
-    instance, ok := object.(*LoxInstance)
+    instance, ok := object.AsObject().(*LoxInstance)
     if !ok {
-        panic(RuntimeError{
-            Token:   expr.Name,
-            Message: "Only instances have fields.",
-        })
+        panic(NewRuntimeError(expr.Name, codes.OnlyInstancesFields))
     }
 
     value := in.evaluate(expr.Value)
@@ -311,67 +445,89 @@ func (in *Interpreter) VisitSetExpr(expr *ast.Set) any {
     return value
 }
 
-func (in *Interpreter) VisitThisExpr(expr *ast.This) any {
+func (in *Interpreter) VisitThisExpr(expr *ast.This) Value {
 	return in.lookUpVariable(expr.Keyword, expr)
 }
 
-func (in *Interpreter) VisitSuperExpr(expr *ast.Super) any {
+func (in *Interpreter) VisitSuperExpr(expr *ast.Super) Value {
     distance, ok := in.locals[expr]
     if !ok {
-        panic(RuntimeError{
-            Token:   expr.Keyword,
-            Message: "Internal error: no local distance for 'super'.",
-        })
+        panic(NewRuntimeError(expr.Keyword, codes.Internal, "no local distance for 'super'."))
     }
 
     superVal := in.environment.GetAt(distance, "super")
-    superclass, ok := superVal.(*LoxClass)
+    superclass, ok := superVal.AsObject().(*LoxClass)
     if !ok {
-        panic(RuntimeError{
-            Token:   expr.Keyword,
-            Message: "Superclass must be a class.",
-        })
+        panic(NewRuntimeError(expr.Keyword, codes.SuperclassNotClass))
     }
 
     thisVal := in.environment.GetAt(distance-1, "this")
-    object, ok := thisVal.(*LoxInstance)
+    object, ok := thisVal.AsObject().(*LoxInstance)
     if !ok {
-        panic(RuntimeError{
-            Token:   expr.Keyword,
-            Message: "Internal error: 'this' is not an instance.",
-        })
+        panic(NewRuntimeError(expr.Keyword, codes.Internal, "'this' is not an instance."))
     }
 
     method := superclass.FindMethod(expr.Method.Lexeme)
     if method == nil {
-        panic(RuntimeError{
-            Token:   expr.Method,
-            Message: fmt.Sprintf("Undefined property '%s'.", expr.Method.Lexeme),
-        })
+        panic(undefinedProperty(expr.Method, superclass.MethodNames()))
     }
 
-    return method.Bind(object)
+    return Object(method.Bind(object))
 }
 
 
 func (in *Interpreter) Interpret(statements []ast.Stmt) {
-	defer func() {
-		if r := recover(); r != nil {
-			if rt, ok := r.(RuntimeError); ok {
-				fmt.Fprintf(os.Stderr, "%s\n[line %d]\n", rt.Message, rt.Token.Line)
-				shared.HadRuntimeError = true
-			} else {
-				panic(r)
-			}
-		}
-	}()
+	in.lastError = nil
+	defer in.recoverRuntimeError()
 
 	for _, statement := range statements {
 		in.execute(statement)
 	}
 }
 
+// InterpretExpression evaluates a top-level expression, as the REPL does
+// to echo its value. A runtime error is reported as Interpret reports it,
+// and ok is false.
+func (in *Interpreter) InterpretExpression(expr ast.Expr) (value Value, ok bool) {
+	in.lastError = nil
+	defer in.recoverRuntimeError()
+
+	return in.evaluate(expr), true
+}
+
+// LastError returns the runtime error that ended the last call to
+// Interpret or InterpretExpression, if one did.
+func (in *Interpreter) LastError() (RuntimeError, bool) {
+	if in.lastError == nil {
+		return RuntimeError{}, false
+	}
+	return *in.lastError, true
+}
+
+// recoverRuntimeError reports a runtime error that ended the program and
+// clears the call stack. It must be deferred.
+func (in *Interpreter) recoverRuntimeError() {
+	if r := recover(); r != nil {
+		if rt, ok := r.(RuntimeError); ok {
+			_, stderr := in.outputs()
+			fmt.Fprintf(stderr, "%s: %s\n[line %d]\n", rt.Code.Label(), rt.Message, rt.Token.Line)
+			if excerpt := rt.Diagnostic().Excerpt(in.source); in.source != "" && excerpt != "" {
+				fmt.Fprintln(stderr, excerpt)
+			}
+			in.printStackTrace(stderr)
+			in.frames = in.frames[:0]
+			in.lastError = &rt
+			shared.HadRuntimeError = true
+		} else {
+			panic(r)
+		}
+	}
+}
+
 func (in *Interpreter) execute(stmt ast.Stmt) {
+	if in.hooks != nil {
+		in.hooks.BeforeStatement(in, stmt)
+	}
 	stmt.Accept(in)
 }
 
@@ -382,6 +538,43 @@ func (in *Interpreter) Resolve(expr ast.Expr, depth int) {
     in.locals[expr] = depth
 }
 
+// MarkTailCall records that stmt returns a call in tail position, so the
+// call can reuse the current frame instead of nesting a new one.
+func (in *Interpreter) MarkTailCall(stmt *ast.Return) {
+	if in.tailCalls == nil {
+		in.tailCalls = make(map[*ast.Return]bool)
+	}
+	in.tailCalls[stmt] = true
+}
+
+// returnTailCall evaluates the call in a tail-position return and, when
+// the callee is a plain Lox function, unwinds to the enclosing
+// LoxFunction.Call with the call still to be made. Other callees are
+// invoked normally and their result returned.
+func (in *Interpreter) returnTailCall(stmt *ast.Return) {
+	call := stmt.Value.(*ast.Call)
+
+	callee := in.evaluate(call.Callee)
+	arguments := make([]Value, 0, len(call.Arguments))
+	for _, argument := range call.Arguments {
+		arguments = append(arguments, in.evaluate(argument))
+	}
+
+	fn := checkCallable(callee, arguments, call.Paren)
+	if function, ok := fn.(*LoxFunction); ok && !function.IsInitializer {
+		panic(returnValue{tail: &tailCall{
+			function:  function,
+			arguments: arguments,
+			line:      stmt.Keyword.Line,
+		}})
+	}
+
+	in.pushFrame(fn, call.Paren)
+	value := fn.Call(in, arguments)
+	in.popFrame()
+	panic(returnValue{Value: value})
+}
+
 func (in *Interpreter) executeBlock(statements []ast.Stmt, environment *Environment) {
 	previous := in.environment
 	in.environment = environment
@@ -392,65 +585,32 @@ func (in *Interpreter) executeBlock(statements []ast.Stmt, environment *Environm
 	}
 }
 
-func (in *Interpreter) lookUpVariable(name scanner.Token, expr ast.Expr) any {
+func (in *Interpreter) lookUpVariable(name scanner.Token, expr ast.Expr) Value {
     if distance, ok := in.locals[expr]; ok {
         return in.environment.GetAt(distance, name.Lexeme)
     }
-    return in.globals.Get(name)
-}
-
-func isTruthy(object any) bool {
-	if object == nil {
-		return false
-	}
-	if b, ok := object.(bool); ok {
-		return b
-	}
-	return true
-}
-
-func isEqual(a, b any) bool {
-	if a == nil && b == nil {
-		return true
-	}
-	if a == nil {
-		return false
-	}
-	return a == b
+    if value, ok := in.globals.get(name.Lexeme); ok {
+        return value
+    }
+    // Suggest locals too: they may be what was meant.
+    panic(undefinedVariable(name, in.environment))
 }
 
-func stringify(object any) string {
-	if object == nil {
-		return "nil"
-	}
-
-	if num, ok := object.(float64); ok {
-		s := fmt.Sprintf("%g", num)
-		return s
-	}
-
-	return fmt.Sprint(object)
+func stringify(object Value) string {
+	return object.String()
 }
 
-func checkNumberOperand(operator scanner.Token, operand any) {
-	if _, ok := operand.(float64); ok {
+func checkNumberOperand(operator scanner.Token, operand Value) {
+	if operand.IsNumber() {
 		return
 	}
-	panic(RuntimeError{
-		Token:   operator,
-		Message: "Operand must be a number.",
-	})
+	panic(NewRuntimeError(operator, codes.OperandMustBeNumber))
 }
 
-func checkNumberOperands(operator scanner.Token, left, right any) {
-	_, l := left.(float64)
-	_, r := right.(float64)
-	if l && r {
+func checkNumberOperands(operator scanner.Token, left, right Value) {
+	if left.IsNumber() && right.IsNumber() {
 		return
 	}
 
-	panic(RuntimeError{
-		Token:   operator,
-		Message: "Operands must be numbers.",
-	})
+	panic(NewRuntimeError(operator, codes.OperandsMustBeNumber))
 }
\ No newline at end of file
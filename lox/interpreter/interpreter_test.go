@@ -2,11 +2,13 @@ package interpreter_test
 
 import (
     "bytes"
+    "fmt"
     "io"
     "os"
     "strings"
     "testing"
 
+    "example.com/golox/lox/ast"
     "example.com/golox/lox/interpreter"
     "example.com/golox/lox/parser"
     "example.com/golox/lox/resolver"
@@ -321,6 +323,27 @@ func TestUndefinedVariableRuntimeError(t *testing.T) {
     }
 }
 
+func TestUndefinedNamesSuggestCloseOnes(t *testing.T) {
+    tests := []struct {
+        src  string
+        want string
+    }{
+        {"var count = 1;\nprint coutn;", "Undefined variable 'coutn'. Did you mean 'count'?"},
+        {"var count = 1;\ncoutn = 2;", "Undefined variable 'coutn'. Did you mean 'count'?"},
+        {"fun f() { var total = 0; print totl; }\nf();", "Undefined variable 'totl'. Did you mean 'total'?"},
+        {"print velocity;", "Undefined variable 'velocity'.\n"},
+        {"class P { init() { this.xpos = 1; } }\nprint P().xpo;", "Undefined property 'xpo'. Did you mean 'xpos'?"},
+        {"class A { move() {} }\nclass B < A {}\nB().mvoe();", "Undefined property 'mvoe'. Did you mean 'move'?"},
+        {"class A { move() {} }\nclass B < A { go() { super.mov(); } }\nB().go();", "Undefined property 'mov'. Did you mean 'move'?"},
+    }
+    for _, tt := range tests {
+        stderr := captureStderr(t, func() { runLox(t, tt.src) })
+        if !strings.Contains(stderr, tt.want) {
+            t.Errorf("%q: expected %q in:\n%s", tt.src, tt.want, stderr)
+        }
+    }
+}
+
 func TestCallNonCallableRuntimeError(t *testing.T) {
     src := `
         var x = 123;
@@ -421,11 +444,12 @@ func TestNativeClockFunction(t *testing.T) {
         t.Fatalf("expected clock arity 0, got %d", cf.Arity())
     }
 
-    t1, ok1 := cf.Call(in, nil).(float64)
-    t2, ok2 := cf.Call(in, nil).(float64)
-    if !ok1 || !ok2 {
-        t.Fatalf("expected clock() to return float64, got %T and %T", t1, t2)
+    v1 := cf.Call(in, nil)
+    v2 := cf.Call(in, nil)
+    if !v1.IsNumber() || !v2.IsNumber() {
+        t.Fatalf("expected clock() to return numbers, got %v and %v", v1, v2)
     }
+    t1, t2 := v1.AsNumber(), v2.AsNumber()
     if t2 < t1 {
         t.Errorf("expected non-decreasing clock values, got t1=%v, t2=%v", t1, t2)
     }
@@ -434,3 +458,558 @@ func TestNativeClockFunction(t *testing.T) {
         t.Errorf("expected String() to contain '<native fn>', got %q", s)
     }
 }
+
+func prepareLoop(t testing.TB, iterations int) (*interpreter.Interpreter, []ast.Stmt) {
+    t.Helper()
+    shared.ResetErrors()
+
+    src := fmt.Sprintf(`
+        var i = 0;
+        var sum = 0;
+        while (i < %d) sum = sum + i * 2 - (i = i + 1) / 4;
+    `, iterations)
+    stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+
+    in := interpreter.NewInterpreter()
+    resolver.NewResolver(in).Resolve(stmts)
+    if shared.HadError {
+        t.Fatalf("unexpected error preparing loop")
+    }
+    return in, stmts
+}
+
+func TestNumericHotPathDoesNotAllocate(t *testing.T) {
+    smallIn, small := prepareLoop(t, 10)
+    largeIn, large := prepareLoop(t, 10000)
+
+    smallAllocs := testing.AllocsPerRun(20, func() { smallIn.Interpret(small) })
+    largeAllocs := testing.AllocsPerRun(20, func() { largeIn.Interpret(large) })
+
+    if largeAllocs > smallAllocs {
+        t.Fatalf("expected arithmetic loop not to allocate per iteration: %v allocs for 10 iterations, %v for 10000",
+            smallAllocs, largeAllocs)
+    }
+}
+
+func TestValueConversions(t *testing.T) {
+    cases := []struct {
+        in   any
+        kind interpreter.ValueKind
+        want string
+    }{
+        {nil, interpreter.NilValue, "nil"},
+        {true, interpreter.BoolValue, "true"},
+        {2.5, interpreter.NumberValue, "2.5"},
+        {"hi", interpreter.StringValue, "hi"},
+        {interpreter.ClockFn{}, interpreter.ObjectValue, "<native fn>"},
+    }
+
+    for _, tc := range cases {
+        v := interpreter.FromAny(tc.in)
+        if v.Kind() != tc.kind {
+            t.Errorf("FromAny(%v): expected kind %v, got %v", tc.in, tc.kind, v.Kind())
+        }
+        if v.String() != tc.want {
+            t.Errorf("FromAny(%v): expected %q, got %q", tc.in, tc.want, v.String())
+        }
+        if v.Any() != tc.in {
+            t.Errorf("FromAny(%v).Any() = %v, want round trip", tc.in, v.Any())
+        }
+    }
+
+    if !interpreter.Number(1).Equals(interpreter.Number(1)) || interpreter.Number(1).Equals(interpreter.String("1")) {
+        t.Errorf("unexpected Equals result between numbers and strings")
+    }
+    if interpreter.Nil.Truthy() || interpreter.Bool(false).Truthy() || !interpreter.Number(0).Truthy() {
+        t.Errorf("unexpected truthiness for nil/false/0")
+    }
+}
+
+func BenchmarkArithmeticLoop(b *testing.B) {
+    in, stmts := prepareLoop(b, 1000)
+    b.ReportAllocs()
+    b.ResetTimer()
+
+    for i := 0; i < b.N; i++ {
+        in.Interpret(stmts)
+    }
+}
+
+func TestInstancesShareShapesAndFieldsShadowMethods(t *testing.T) {
+    src := `
+        class Point {
+            init(x, y) {
+                this.x = x;
+                this.y = y;
+            }
+            sum() { return this.x + this.y; }
+        }
+
+        var a = Point(1, 2);
+        var b = Point(3, 4);
+        print a.sum();
+        print b.sum();
+
+        b.z = 5;          // dynamic field on one instance only
+        print b.z;
+
+        a.sum = "shadowed";
+        print a.sum;      // field wins over the method
+        print b.sum();
+    `
+    out, hadErr, hadRt := runLox(t, src)
+    if hadErr || hadRt {
+        t.Fatalf("unexpected error flags: hadError=%v, hadRuntimeError=%v", hadErr, hadRt)
+    }
+
+    lines := strings.Split(out, "\n")
+    want := []string{"3", "7", "5", "shadowed", "7"}
+    if len(lines) != len(want) {
+        t.Fatalf("expected %d lines, got %d (%q)", len(want), len(lines), out)
+    }
+    for i, w := range want {
+        if strings.TrimSpace(lines[i]) != w {
+            t.Errorf("line %d: expected %q, got %q", i, w, lines[i])
+        }
+    }
+}
+
+func TestShapeTransitionsAreShared(t *testing.T) {
+    class := interpreter.NewLoxClass("Foo", nil, map[string]*interpreter.LoxFunction{})
+    a := interpreter.NewLoxInstance(class)
+    b := interpreter.NewLoxInstance(class)
+
+    a.SetField("x", interpreter.Number(1))
+    a.SetField("y", interpreter.Number(2))
+    b.SetField("x", interpreter.Number(3))
+    b.SetField("y", interpreter.Number(4))
+
+    if a.Shape() != b.Shape() {
+        t.Fatalf("expected instances with the same field order to share a shape")
+    }
+
+    b.SetField("x", interpreter.Number(5))
+    if a.Shape() != b.Shape() {
+        t.Fatalf("expected overwriting a field to keep the shape")
+    }
+    if v, _ := a.Field("x"); v.AsNumber() != 1 {
+        t.Errorf("expected a.x to stay 1, got %v", v)
+    }
+
+    for i := 0; i < 20; i++ {
+        a.SetField(fmt.Sprintf("f%d", i), interpreter.Number(float64(i)))
+    }
+    if v, ok := a.Field("f17"); !ok || v.AsNumber() != 17 {
+        t.Errorf("expected f17 = 17 after growing past the scan limit, got %v (%v)", v, ok)
+    }
+    if names := a.FieldNames(); len(names) != 22 || names[0] != "x" || names[21] != "f19" {
+        t.Errorf("unexpected field order: %v", names)
+    }
+}
+
+func BenchmarkInstanceFields(b *testing.B) {
+    class := interpreter.NewLoxClass("Foo", nil, map[string]*interpreter.LoxFunction{})
+    b.ReportAllocs()
+
+    for i := 0; i < b.N; i++ {
+        inst := interpreter.NewLoxInstance(class)
+        inst.SetField("x", interpreter.Number(1))
+        inst.SetField("y", interpreter.Number(2))
+        inst.SetField("z", interpreter.Number(3))
+    }
+}
+
+func TestTailCallsDoNotGrowTheStack(t *testing.T) {
+    src := `
+        fun sumTo(n, acc) {
+            if (n == 0) return acc;
+            return sumTo(n - 1, acc + n);
+        }
+        print sumTo(300000, 0);
+
+        fun isEven(n) { if (n == 0) return true; return isOdd(n - 1); }
+        fun isOdd(n) { if (n == 0) return false; return isEven(n - 1); }
+        print isEven(300001);
+    `
+    out, hadErr, hadRt := runLox(t, src)
+    if hadErr || hadRt {
+        t.Fatalf("unexpected error flags: hadError=%v, hadRuntimeError=%v", hadErr, hadRt)
+    }
+
+    lines := strings.Split(out, "\n")
+    want := []string{"4.500015e+10", "false"}
+    if len(lines) != len(want) {
+        t.Fatalf("expected %d lines, got %d (%q)", len(want), len(lines), out)
+    }
+    for i, w := range want {
+        if strings.TrimSpace(lines[i]) != w {
+            t.Errorf("line %d: expected %q, got %q", i, w, lines[i])
+        }
+    }
+}
+
+func captureStderr(t *testing.T, f func()) string {
+    t.Helper()
+
+    old := os.Stderr
+    r, w, _ := os.Pipe()
+    os.Stderr = w
+
+    outCh := make(chan string)
+    go func() {
+        var buf bytes.Buffer
+        _, _ = io.Copy(&buf, r)
+        outCh <- buf.String()
+    }()
+
+    f()
+
+    w.Close()
+    os.Stderr = old
+    return <-outCh
+}
+
+func TestRuntimeErrorStackTraceMarksElidedFrames(t *testing.T) {
+    src := `
+        fun boom(n) {
+            if (n == 0) return nil + 1;
+            return boom(n - 1);
+        }
+        fun start() {
+            var x = boom(3);
+            return x;
+        }
+        start();
+    `
+    var hadRt bool
+    stderr := captureStderr(t, func() {
+        _, _, hadRt = runLox(t, src)
+    })
+
+    if !hadRt {
+        t.Fatalf("expected a runtime error")
+    }
+    for _, want := range []string{
+        "[line 3]",
+        "in boom() called at line 4 [3 tail call(s) elided]",
+        "in start() called at line 10",
+    } {
+        if !strings.Contains(stderr, want) {
+            t.Errorf("expected stack trace to contain %q, got:\n%s", want, stderr)
+        }
+    }
+}
+
+func TestRuntimeErrorsUnderlineTheSource(t *testing.T) {
+    src := "fun add(a, b) {\n  return a + b;\n}\nprint add(1, nil);\n"
+    stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+    in := interpreter.NewInterpreter()
+    resolver.NewResolver(in).Resolve(stmts)
+
+    var out bytes.Buffer
+    in.SetOutput(&out, &out)
+    in.Interpret(stmts)
+    if !strings.Contains(out.String(), "[line 2]\n  in add() called at line 4\n") {
+        t.Errorf("expected no excerpt without a source, got:\n%s", out.String())
+    }
+
+    // Without a source there is no line 1 either.
+    first := interpreter.NewInterpreter()
+    first.SetOutput(&out, &out)
+    out.Reset()
+    first.Interpret(parser.NewParser(scanner.NewScanner("print -nil;").ScanTokens()).Parse())
+    if strings.Contains(out.String(), " | ") {
+        t.Errorf("expected no excerpt without a source, got:\n%s", out.String())
+    }
+
+    in.SetSource(src)
+    out.Reset()
+    in.Interpret(stmts)
+    want := "[line 2]\n    2 |   return a + b;\n      |            ^\n  in add() called at line 4\n"
+    if !strings.Contains(out.String(), want) {
+        t.Errorf("expected the error to quote its line, got:\n%s", out.String())
+    }
+}
+
+func TestTreesThatDidNotParseFailLoudly(t *testing.T) {
+    // Trees such as these can come from ast.DecodeJSON.
+    tests := []struct {
+        stmt ast.Stmt
+        want string
+    }{
+        {&ast.Bad{}, "cannot run code that did not parse."},
+        {&ast.Print{Expression: &ast.Error{}}, "cannot run code that did not parse."},
+        {&ast.Expression{Expression: &ast.Unary{
+            Operator: scanner.Token{Type: scanner.PLUS, Lexeme: "+", Line: 1},
+            Right:    &ast.Literal{Value: 1.0},
+        }}, "unknown operator '+'."},
+    }
+    for _, tt := range tests {
+        in := interpreter.NewInterpreter()
+        var out bytes.Buffer
+        in.SetOutput(&out, &out)
+        in.Interpret([]ast.Stmt{tt.stmt})
+        if rt, ok := in.LastError(); !ok || !strings.Contains(rt.Message, tt.want) {
+            t.Errorf("expected an internal error containing %q, got %q", tt.want, out.String())
+        }
+    }
+}
+
+func TestDeepNonTailRecursionIsARuntimeError(t *testing.T) {
+    // Each call of the second function nests many more levels of Go calls
+    // than the first, so the limit has to allow for its body.
+    for _, src := range []string{`
+        fun down(n) {
+            if (n == 0) return 0;
+            return down(n - 1) + 1;
+        }
+        print down(1000000);
+    `, `
+        fun f(n) {
+            if (n == 0) return 0;
+            { { { return 1 + (1 + (1 + (1 + (1 + -(-f(n - 1)))))); } } }
+        }
+        print f(1000000);
+    `} {
+        var hadRt bool
+        stderr := captureStderr(t, func() {
+            _, _, hadRt = runLox(t, src)
+        })
+
+        if !hadRt || !strings.Contains(stderr, "Stack overflow.") {
+            t.Fatalf("expected stack overflow runtime error, got %q", stderr)
+        }
+        if !strings.Contains(stderr, "more frame(s)") {
+            t.Errorf("expected long stack trace to be truncated, got %q", stderr)
+        }
+    }
+}
+
+func TestRecursionWithinTheStackBudgetRuns(t *testing.T) {
+    src := `
+        fun down(n) {
+            if (n == 0) return 0;
+            return down(n - 1) + 1;
+        }
+        print down(20000);
+    `
+    out, _, hadRt := runLox(t, src)
+    if hadRt || strings.TrimSpace(out) != "20000" {
+        t.Errorf("expected 20000, got %q", out)
+    }
+}
+
+func TestInterpreterInternsProgramNames(t *testing.T) {
+    shared.ResetErrors()
+    in := interpreter.NewInterpreter()
+
+    src := `
+        class Counter {
+            init() { this.count = 0; }
+            bump() { this.count = this.count + 1; return this; }
+        }
+        var c = Counter();
+        c.bump().bump();
+    `
+    stmts := parser.NewParser(scanner.NewScanner(src).WithInterner(in.Strings()).ScanTokens()).Parse()
+    resolver.NewResolver(in).Resolve(stmts)
+    in.Interpret(stmts)
+
+    if shared.HadError || shared.HadRuntimeError {
+        t.Fatalf("unexpected error running program")
+    }
+
+    if _, ok := in.Strings().Lookup("count"); !ok {
+        t.Fatalf("expected 'count' to be interned by the scanner")
+    }
+    if n := in.Strings().Len(); in.Intern("count") != "count" || in.Strings().Len() != n {
+        t.Errorf("expected Intern to return the scanner's copy")
+    }
+    if _, ok := in.Strings().Lookup("clock"); !ok {
+        t.Errorf("expected native global names to be interned")
+    }
+}
+
+// hookRecorder evaluates an expression before each print statement, the
+// way a debugger inspects a stopped program.
+type hookRecorder struct {
+    expr  ast.Expr
+    seen  []string
+    depth []int
+}
+
+func (h *hookRecorder) BeforeStatement(in *interpreter.Interpreter, stmt ast.Stmt) {
+    if _, ok := stmt.(*ast.Print); !ok {
+        return
+    }
+    value, err := in.Evaluate(h.expr, in.Environment())
+    if err != nil {
+        h.seen = append(h.seen, "error: "+err.Error())
+    } else {
+        h.seen = append(h.seen, value.String())
+    }
+    h.depth = append(h.depth, in.CallDepth())
+}
+
+func TestHooksAndEvaluateInTheRunningEnvironment(t *testing.T) {
+    src := `
+        var x = "global";
+        fun f(x) {
+            var y = x * 2;
+            print y;
+        }
+        f(21);
+        print x;
+    `
+    stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+    in := interpreter.NewInterpreter()
+    resolver.NewResolver(in).Resolve(stmts)
+
+    var out bytes.Buffer
+    in.SetOutput(&out, &out)
+    expr := parser.NewParser(scanner.NewScanner("x").ScanTokens()).ParseExpression()
+    hooks := &hookRecorder{expr: expr}
+    in.SetHooks(hooks)
+    in.Interpret(stmts)
+
+    if got := fmt.Sprint(hooks.seen); got != "[21 global]" {
+        t.Errorf("expected x to resolve to the parameter, then the global; got %s", got)
+    }
+    if got := fmt.Sprint(hooks.depth); got != "[1 0]" {
+        t.Errorf("expected call depths [1 0], got %s", got)
+    }
+    if out.String() != "42\nglobal\n" {
+        t.Errorf("expected output to go to the writer set with SetOutput, got %q", out.String())
+    }
+}
+
+// trace runs src with a Tracer and returns what it logged.
+func trace(t *testing.T, src string, options interpreter.TraceOptions) string {
+    t.Helper()
+    stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+    in := interpreter.NewInterpreter()
+    resolver.NewResolver(in).Resolve(stmts)
+
+    var out, log bytes.Buffer
+    in.SetOutput(&out, &out)
+    in.SetHooks(interpreter.NewTracer(&log, options))
+    in.Interpret(stmts)
+    return log.String()
+}
+
+func TestCallFromGoRunsInAFrameOfItsOwn(t *testing.T) {
+    in := interpreter.NewInterpreter()
+    names := func() string {
+        var names []string
+        for _, frame := range in.CallStack() {
+            names = append(names, frame.Name)
+        }
+        return strings.Join(names, " ")
+    }
+    var inside, after string
+    in.DefineNative(&interpreter.NativeFunction{
+        Name:   "where",
+        Params: 0,
+        Fn: func(in *interpreter.Interpreter, args []interpreter.Value) interpreter.Value {
+            inside = names()
+            return interpreter.Nil
+        },
+    })
+    in.DefineNative(&interpreter.NativeFunction{
+        Name:   "callBack",
+        Params: 1,
+        Fn: func(in *interpreter.Interpreter, args []interpreter.Value) interpreter.Value {
+            if _, err := in.Call(args[0], nil); err != nil {
+                t.Errorf("unexpected error: %v", err)
+            }
+            after = names()
+            return interpreter.Nil
+        },
+    })
+
+    // The tail call in outer replaces the frame Call pushed, not the one
+    // of callBack.
+    src := `
+        fun inner() { where(); return 1; }
+        fun outer() { return inner(); }
+        callBack(outer);
+    `
+    stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+    resolver.NewResolver(in).Resolve(stmts)
+    var out bytes.Buffer
+    in.SetOutput(&out, &out)
+    in.Interpret(stmts)
+
+    if inside != "callBack inner where" {
+        t.Errorf("stack inside the call = %q, want %q", inside, "callBack inner where")
+    }
+    if after != "callBack" {
+        t.Errorf("stack after the call = %q, want %q", after, "callBack")
+    }
+}
+
+func TestTracerLogsStatementsCallsAndReturns(t *testing.T) {
+    src := `fun add(a, b) {
+  return a + b;
+}
+print add(1, 2);
+`
+    got := trace(t, src, interpreter.TraceOptions{})
+    want := `[line 1] (fun add (a b))
+[line 4] (print (call add 1 2))
+[line 4] call add(1, 2)
+  [line 2] (return (+ a b))
+[line 4] add returned 3
+`
+    if got != want {
+        t.Errorf("got\n%s\nwant\n%s", got, want)
+    }
+}
+
+func TestTracerForgetsAFunctionThatThrows(t *testing.T) {
+    in := interpreter.NewInterpreter()
+    var out, log bytes.Buffer
+    in.SetOutput(&out, &out)
+    in.SetHooks(interpreter.NewTracer(&log, interpreter.TraceOptions{CallsOnly: true, Function: "boom"}))
+
+    // Like lines of a REPL: the runtime error in boom ends the first
+    // program, and other is then called at the depth boom was.
+    for _, src := range []string{
+        "fun boom(n) { return n + nil; }\nfun other() { return 2; }\nboom(1);\n",
+        "other();\n",
+    } {
+        stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+        resolver.NewResolver(in).Resolve(stmts)
+        in.Interpret(stmts)
+    }
+
+    if !strings.Contains(out.String(), "must be a number.") {
+        t.Fatalf("expected boom to throw, got %q", out.String())
+    }
+    if want := "[line 3] call boom(1)\n"; log.String() != want {
+        t.Errorf("got\n%s\nwant\n%s", log.String(), want)
+    }
+}
+
+func TestTracerFilters(t *testing.T) {
+    src := `fun leaf(n) { return n; }
+fun fact(n) {
+  if (n <= 1) return leaf(1);
+  return n * fact(n - 1);
+}
+leaf(0);
+print fact(2);
+`
+    got := trace(t, src, interpreter.TraceOptions{CallsOnly: true, Function: "fact"})
+    // The tail call to leaf replaces fact(1), so it is logged at the same
+    // depth and returns in its place.
+    want := `[line 7] call fact(2)
+  [line 4] call fact(1)
+  [line 3] call leaf(1)
+  [line 3] leaf returned 1
+[line 7] fact returned 2
+`
+    if got != want {
+        t.Errorf("got\n%s\nwant\n%s", got, want)
+    }
+}
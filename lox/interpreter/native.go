@@ -3,16 +3,80 @@ package interpreter
 import (
 	"fmt"
 	"time"
+
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/scanner"
 )
 
 type ClockFn struct{}
 
 func (ClockFn) Arity() int { return 0 }
 
-func (ClockFn) Call(in *Interpreter, arguments []any) any {
-	return float64(time.Now().UnixNano()) / 1e9
+func (ClockFn) Call(in *Interpreter, arguments []Value) Value {
+	return Number(float64(time.Now().UnixNano()) / 1e9)
 }
 
 func (ClockFn) String() string { return "<native fn>"}
 
-var _ fmt.Stringer = ClockFn{}
\ No newline at end of file
+var _ fmt.Stringer = ClockFn{}
+
+// NativeFunction is a builtin written in Go. Embedders such as the test
+// runner use it to give programs functions of their own.
+type NativeFunction struct {
+	Name   string
+	Params int
+	Fn     func(in *Interpreter, arguments []Value) Value
+}
+
+func (n *NativeFunction) Arity() int { return n.Params }
+
+func (n *NativeFunction) Call(in *Interpreter, arguments []Value) Value {
+	return n.Fn(in, arguments)
+}
+
+func (n *NativeFunction) String() string { return "<native fn>" }
+
+// DefineNative adds fn to the globals under its name.
+func (in *Interpreter) DefineNative(fn *NativeFunction) {
+	in.globals.Define(in.strings.Intern(fn.Name), Object(fn))
+}
+
+// Fail raises a runtime error from a native function, reported at the
+// line of the call to it.
+func (in *Interpreter) Fail(message string) {
+	line := 0
+	if n := len(in.frames); n > 0 {
+		line = in.frames[n-1].Line
+	}
+	panic(NewRuntimeError(scanner.Token{Line: line}, codes.NativeFailure, message))
+}
+
+// Call calls a function or class with arguments from Go. The call gets a
+// frame of its own, as a call from Lox does, at the line of the innermost
+// active call. A runtime error in the call is returned rather than
+// reported, and leaves the call stack as it was.
+func (in *Interpreter) Call(callee Value, arguments []Value) (result Value, err error) {
+	depth, environment := len(in.frames), in.environment
+	defer func() {
+		if r := recover(); r != nil {
+			rt, ok := r.(RuntimeError)
+			if !ok {
+				panic(r)
+			}
+			in.frames, in.environment = in.frames[:depth], environment
+			err = rt
+		}
+	}()
+
+	line := 0
+	if depth > 0 {
+		line = in.frames[depth-1].Line
+	}
+	paren := scanner.Token{Line: line}
+	fn := checkCallable(callee, arguments, paren)
+
+	in.pushFrame(fn, paren)
+	result = fn.Call(in, arguments)
+	in.popFrame()
+	return result, nil
+}
@@ -0,0 +1,70 @@
+package interpreter
+
+// shapeScanLimit is the field count above which a shape builds a map for
+// lookups instead of scanning its names.
+const shapeScanLimit = 8
+
+// Shape describes the field layout of an instance: which names it has and
+// which slot of the instance's value slice holds each one. A shape's
+// layout never changes once built, so shapes are shared. Adding a field
+// moves an instance along a cached transition to the next shape, so every
+// instance that gains the same fields in the same order ends up pointing
+// at the same Shape.
+type Shape struct {
+	names       []string
+	index       map[string]int
+	transitions map[string]*Shape
+}
+
+func newRootShape() *Shape {
+	return &Shape{}
+}
+
+// Lookup returns the slot that holds the named field.
+func (s *Shape) Lookup(name string) (int, bool) {
+	if s.index == nil {
+		// Small shapes are cheaper to scan than to hash.
+		for i, n := range s.names {
+			if n == name {
+				return i, true
+			}
+		}
+		return 0, false
+	}
+	slot, ok := s.index[name]
+	return slot, ok
+}
+
+// Transition returns the shape reached by adding a field called name.
+func (s *Shape) Transition(name string) *Shape {
+	if next, ok := s.transitions[name]; ok {
+		return next
+	}
+
+	names := make([]string, len(s.names)+1)
+	copy(names, s.names)
+	names[len(s.names)] = name
+
+	next := &Shape{names: names}
+	if len(names) > shapeScanLimit {
+		next.index = make(map[string]int, len(names))
+		for i, n := range names {
+			next.index[n] = i
+		}
+	}
+
+	if s.transitions == nil {
+		s.transitions = make(map[string]*Shape)
+	}
+	s.transitions[name] = next
+	return next
+}
+
+// FieldNames lists the fields in the order they were added.
+func (s *Shape) FieldNames() []string {
+	return append([]string(nil), s.names...)
+}
+
+func (s *Shape) Len() int {
+	return len(s.names)
+}
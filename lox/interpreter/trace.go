@@ -0,0 +1,124 @@
+package interpreter
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"example.com/golox/lox/ast"
+)
+
+// TraceOptions narrow what a Tracer logs.
+type TraceOptions struct {
+	// CallsOnly logs calls and returns but not statements.
+	CallsOnly bool
+	// Function, when set, logs only what happens during calls of the
+	// function with this name, including the calls themselves.
+	Function string
+}
+
+// Tracer is a CallHooks that logs a running program: each statement
+// before it runs, and each call of a Lox function with its arguments and
+// the value it returns. Lines are indented by call depth and start with
+// the source line of the statement, or of the call.
+type Tracer struct {
+	w       io.Writer
+	options TraceOptions
+
+	// inside is the call depth of the outermost active call of
+	// options.Function, or 0 outside of one.
+	inside int
+}
+
+// NewTracer returns a Tracer that writes to w. Install it with SetHooks.
+func NewTracer(w io.Writer, options TraceOptions) *Tracer {
+	return &Tracer{w: w, options: options}
+}
+
+// BeforeStatement implements Hooks.
+func (t *Tracer) BeforeStatement(in *Interpreter, stmt ast.Stmt) {
+	// active is checked first, even when statements are not logged, so
+	// that it sees the depth drop after a runtime error.
+	if !t.active(in) || t.options.CallsOnly {
+		return
+	}
+	t.log(in.CallDepth(), stmt.Extent().Start.Line, summary(stmt))
+}
+
+// EnterCall implements CallHooks.
+func (t *Tracer) EnterCall(in *Interpreter, fn *LoxFunction, arguments []Value) {
+	if !t.active(in) {
+		if t.options.Function == "" || fn.Declaration.Name.Lexeme != t.options.Function {
+			return
+		}
+		t.inside = in.CallDepth()
+	}
+
+	values := make([]string, len(arguments))
+	for i, argument := range arguments {
+		values[i] = stringify(argument)
+	}
+	t.log(in.CallDepth()-1, t.callLine(in), fmt.Sprintf("call %s(%s)", callableName(fn), strings.Join(values, ", ")))
+}
+
+// ExitCall implements CallHooks.
+func (t *Tracer) ExitCall(in *Interpreter, fn *LoxFunction, result Value) {
+	if !t.active(in) {
+		return
+	}
+	t.log(in.CallDepth()-1, t.callLine(in), fmt.Sprintf("%s returned %s", callableName(fn), stringify(result)))
+	if in.CallDepth() == t.inside {
+		t.inside = 0
+	}
+}
+
+// active reports whether events are being logged now. A runtime error
+// unwinds calls without ExitCall, so a call of options.Function deeper
+// than the current depth has ended, and is forgotten: a later call at
+// the same depth, such as the next line of a REPL, is not inside it.
+func (t *Tracer) active(in *Interpreter) bool {
+	if in.CallDepth() < t.inside {
+		t.inside = 0
+	}
+	return t.options.Function == "" || t.inside > 0
+}
+
+// callLine is the line of the innermost active call.
+func (t *Tracer) callLine(in *Interpreter) int {
+	if n := len(in.frames); n > 0 {
+		return in.frames[n-1].Line
+	}
+	return 0
+}
+
+func (t *Tracer) log(depth, line int, text string) {
+	where := ""
+	if line > 0 {
+		where = fmt.Sprintf("[line %d] ", line)
+	}
+	fmt.Fprintf(t.w, "%s%s%s\n", strings.Repeat("  ", max(depth, 0)), where, text)
+}
+
+// summary describes a statement on one line. Statements that hold others
+// are shown by their header alone, since their bodies are logged as they
+// run.
+func summary(stmt ast.Stmt) string {
+	printer := &ast.AstPrinter{}
+	switch s := stmt.(type) {
+	case *ast.Block:
+		return "(block)"
+	case *ast.Class:
+		return "(class " + s.Name.Lexeme + ")"
+	case *ast.Function:
+		params := make([]string, len(s.Params))
+		for i, param := range s.Params {
+			params[i] = param.Lexeme
+		}
+		return "(fun " + s.Name.Lexeme + " (" + strings.Join(params, " ") + "))"
+	case *ast.If:
+		return "(if " + printer.Print(s.Condition) + ")"
+	case *ast.While:
+		return "(while " + printer.Print(s.Condition) + ")"
+	}
+	return printer.PrintStmt(stmt)
+}
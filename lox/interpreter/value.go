@@ -0,0 +1,167 @@
+package interpreter
+
+import "fmt"
+
+// ValueKind tags which member of a Value is meaningful.
+type ValueKind uint8
+
+const (
+	NilValue ValueKind = iota
+	BoolValue
+	NumberValue
+	StringValue
+	ObjectValue
+)
+
+// Value is a Lox runtime value. Numbers and booleans live inline in num so
+// arithmetic never has to box a float64 into an interface; strings and
+// heap objects (functions, classes, instances) are kept in obj.
+type Value struct {
+	kind ValueKind
+	num  float64
+	obj  any
+}
+
+// Nil is the Lox nil value and the zero Value.
+var Nil = Value{}
+
+func Bool(b bool) Value {
+	if b {
+		return Value{kind: BoolValue, num: 1}
+	}
+	return Value{kind: BoolValue}
+}
+
+func Number(n float64) Value {
+	return Value{kind: NumberValue, num: n}
+}
+
+func String(s string) Value {
+	return Value{kind: StringValue, obj: s}
+}
+
+// Object wraps a callable, class, instance or any other host object.
+func Object(o any) Value {
+	if o == nil {
+		return Nil
+	}
+	return Value{kind: ObjectValue, obj: o}
+}
+
+// FromAny converts a Go value, such as an ast.Literal's payload, into a
+// Value. Strings reuse the interface they arrived in, so converting a
+// literal does not allocate.
+func FromAny(v any) Value {
+	switch x := v.(type) {
+	case nil:
+		return Nil
+	case Value:
+		return x
+	case bool:
+		return Bool(x)
+	case float64:
+		return Number(x)
+	case int:
+		return Number(float64(x))
+	case string:
+		return Value{kind: StringValue, obj: v}
+	default:
+		return Object(v)
+	}
+}
+
+// Any converts the Value back into the untyped form used outside the
+// interpreter: nil, bool, float64, string or the wrapped object.
+func (v Value) Any() any {
+	switch v.kind {
+	case BoolValue:
+		return v.num != 0
+	case NumberValue:
+		return v.num
+	case StringValue, ObjectValue:
+		return v.obj
+	default:
+		return nil
+	}
+}
+
+func (v Value) Kind() ValueKind { return v.kind }
+
+func (v Value) IsNil() bool    { return v.kind == NilValue }
+func (v Value) IsBool() bool   { return v.kind == BoolValue }
+func (v Value) IsNumber() bool { return v.kind == NumberValue }
+func (v Value) IsString() bool { return v.kind == StringValue }
+func (v Value) IsObject() bool { return v.kind == ObjectValue }
+
+// AsBool returns the boolean payload; it is false for non-booleans.
+func (v Value) AsBool() bool {
+	return v.kind == BoolValue && v.num != 0
+}
+
+// AsNumber returns the numeric payload; it is 0 for non-numbers.
+func (v Value) AsNumber() float64 {
+	if v.kind != NumberValue {
+		return 0
+	}
+	return v.num
+}
+
+func (v Value) AsString() (string, bool) {
+	if v.kind != StringValue {
+		return "", false
+	}
+	return v.obj.(string), true
+}
+
+// AsObject returns the wrapped object, or nil for non-objects.
+func (v Value) AsObject() any {
+	if v.kind != ObjectValue {
+		return nil
+	}
+	return v.obj
+}
+
+// Truthy applies Lox's rule: nil and false are falsey, everything else is
+// truthy.
+func (v Value) Truthy() bool {
+	switch v.kind {
+	case NilValue:
+		return false
+	case BoolValue:
+		return v.num != 0
+	default:
+		return true
+	}
+}
+
+// Equals reports whether two values are equal under Lox's == operator.
+func (v Value) Equals(other Value) bool {
+	if v.kind != other.kind {
+		return false
+	}
+	switch v.kind {
+	case NilValue:
+		return true
+	case BoolValue, NumberValue:
+		return v.num == other.num
+	default:
+		return v.obj == other.obj
+	}
+}
+
+// String renders the value the way print shows it.
+func (v Value) String() string {
+	switch v.kind {
+	case NilValue:
+		return "nil"
+	case BoolValue:
+		if v.num != 0 {
+			return "true"
+		}
+		return "false"
+	case NumberValue:
+		return fmt.Sprintf("%g", v.num)
+	default:
+		return fmt.Sprint(v.obj)
+	}
+}
@@ -0,0 +1,200 @@
+// Package lint checks Lox programs for likely mistakes that are still
+// valid code. Rules build on the resolver's symbol table, so they see
+// exactly the scopes the interpreter will use, and each can be disabled.
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+type Severity int
+
+const (
+	Warning Severity = iota
+	Error
+)
+
+func (s Severity) String() string {
+	if s == Error {
+		return "Error"
+	}
+	return "Warning"
+}
+
+// Finding is one problem reported by a rule.
+type Finding struct {
+	Line int
+	// Column is where the finding starts, from 1, or 0 if not known.
+	Column   int
+	Rule     string
+	Severity Severity
+	Message  string
+	// Code is the error code of a ResolveRule finding.
+	Code codes.Code
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("[Line %d] %s [%s]: %s", f.Line, f.Severity, f.Rule, f.Message)
+}
+
+// ResolveRule is the ID given to the resolver's own static errors, which
+// are reported alongside the lint findings and cannot be disabled.
+const ResolveRule = "resolve"
+
+// Rule is a single check.
+type Rule struct {
+	ID          string
+	Description string
+	check       func(p *pass)
+}
+
+// Rules lists every rule in the order its findings are produced.
+var Rules = []Rule{
+	{"unused-variable", "local variable that is never read", checkUnusedVariables},
+	{"unused-parameter", "function parameter that is never read", checkUnusedParameters},
+	{"shadowed", "declaration that hides a variable from an enclosing scope", checkShadowed},
+	{"unreachable", "statement after a return in the same block", checkUnreachable},
+	{"undeclared-assignment", "assignment to a global that is never declared", checkUndeclaredAssignments},
+	{"method-without-this", "method that never uses 'this' or 'super'", checkMethodsWithoutThis},
+	{"init-returns-value", "'init' method that returns a value", checkInitReturns},
+}
+
+// Linter runs the enabled rules over a program. All rules start enabled.
+type Linter struct {
+	disabled map[string]bool
+}
+
+func New() *Linter {
+	return &Linter{disabled: make(map[string]bool)}
+}
+
+// Disable turns a rule off. It fails for an unknown rule ID.
+func (l *Linter) Disable(id string) error {
+	if !knownRule(id) {
+		return fmt.Errorf("unknown lint rule %q", id)
+	}
+	l.disabled[id] = true
+	return nil
+}
+
+// Enable turns a rule back on. It fails for an unknown rule ID.
+func (l *Linter) Enable(id string) error {
+	if !knownRule(id) {
+		return fmt.Errorf("unknown lint rule %q", id)
+	}
+	delete(l.disabled, id)
+	return nil
+}
+
+func (l *Linter) Enabled(id string) bool {
+	return knownRule(id) && !l.disabled[id]
+}
+
+func knownRule(id string) bool {
+	for _, rule := range Rules {
+		if rule.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Check lints src and returns the findings sorted by line. Syntax errors
+// stop linting and are returned as a shared.Errors.
+func (l *Linter) Check(src string) ([]Finding, error) {
+	var (
+		statements []ast.Stmt
+		p          *parser.Parser
+	)
+	syntax := shared.Collect(func() {
+		p = parser.NewParser(scanner.NewScanner(src).ScanTokens())
+		statements = p.Parse()
+	})
+	if len(syntax) > 0 {
+		return nil, shared.Errors(syntax)
+	}
+
+	res := resolver.NewResolver(interpreter.NewInterpreter())
+	static := shared.Collect(func() {
+		res.Resolve(statements)
+	})
+
+	pass := &pass{statements: statements, lines: p.Lines(), resolver: res}
+	for _, rule := range Rules {
+		if l.Enabled(rule.ID) {
+			pass.rule = rule.ID
+			rule.check(pass)
+		}
+	}
+
+	findings := pass.findings
+	for _, diag := range static {
+		if pass.covers(diag) {
+			continue
+		}
+		findings = append(findings, Finding{
+			Line:     diag.Line,
+			Column:   diag.Column,
+			Rule:     ResolveRule,
+			Severity: Error,
+			Message:  diag.Message,
+			Code:     diag.Code,
+		})
+	}
+
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings, nil
+}
+
+// pass is the state shared by the rules during one Check.
+type pass struct {
+	statements []ast.Stmt
+	lines      map[ast.Stmt]parser.LineRange
+	resolver   *resolver.Resolver
+
+	rule     string
+	findings []Finding
+}
+
+func (p *pass) report(line, column int, severity Severity, format string, args ...any) {
+	p.findings = append(p.findings, Finding{
+		Line:     line,
+		Column:   column,
+		Rule:     p.rule,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// explains maps rules to the resolver error they describe in more detail.
+var explains = map[string]codes.Code{
+	"init-returns-value": codes.ReturnFromInit,
+}
+
+// covers reports whether a rule already explained a resolver error at the
+// same position, so the same problem is not listed twice.
+func (p *pass) covers(diag shared.Diagnostic) bool {
+	for _, f := range p.findings {
+		if code, ok := explains[f.Rule]; ok && code == diag.Code &&
+			f.Line == diag.Line && f.Column == diag.Column {
+			return true
+		}
+	}
+	return false
+}
+
+// inspect calls f for every node of the program.
+func (p *pass) inspect(f func(node any) bool) {
+	for _, stmt := range p.statements {
+		ast.Inspect(stmt, f)
+	}
+}
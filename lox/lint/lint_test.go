@@ -0,0 +1,110 @@
+package lint
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func check(t *testing.T, l *Linter, src string) []Finding {
+	t.Helper()
+	findings, err := l.Check(src)
+	if err != nil {
+		t.Fatalf("Check returned error: %v", err)
+	}
+	return findings
+}
+
+// summary renders findings as "line:rule" for compact comparisons.
+func summary(findings []Finding) string {
+	parts := make([]string, len(findings))
+	for i, f := range findings {
+		parts[i] = strconv.Itoa(f.Line) + ":" + f.Rule
+	}
+	return strings.Join(parts, " ")
+}
+
+const sample = `var g = 1;
+fun f(a, _b) {
+  var unused = 1;
+  var g = 2;
+  return g;
+  print "never";
+}
+class C {
+  init() { return 1; }
+  m() { print "no this"; }
+  n() { return this; }
+  o() { fun inner() { return this; } return inner; }
+}
+undeclared = 3;
+for (var i = 0; i < 1; i = i + 1) return;
+`
+
+func TestAllRules(t *testing.T) {
+	got := summary(check(t, New(), sample))
+	want := "2:unused-parameter 3:unused-variable 4:shadowed 6:unreachable 9:init-returns-value 10:method-without-this 14:undeclared-assignment 15:resolve"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+}
+
+func TestDisabledRulesAreSkipped(t *testing.T) {
+	l := New()
+	for _, id := range []string{"unused-parameter", "shadowed", "unreachable", "init-returns-value"} {
+		if err := l.Disable(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got := summary(check(t, l, sample))
+
+	// With its lint rule off, the resolver's own error for init still shows.
+	want := "3:unused-variable 9:resolve 10:method-without-this 14:undeclared-assignment 15:resolve"
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	if err := l.Enable("shadowed"); err != nil || !l.Enabled("shadowed") {
+		t.Fatalf("expected shadowed to be re-enabled, err=%v", err)
+	}
+	if err := l.Disable("no-such-rule"); err == nil {
+		t.Fatalf("expected an error for an unknown rule")
+	}
+}
+
+func TestCleanProgramHasNoFindings(t *testing.T) {
+	src := `
+class Counter {
+  init() { this.n = 0; }
+  inc() { this.n = this.n + 1; return this.n; }
+}
+fun twice(f) { f(); return f(); }
+var c = Counter();
+print twice(c.inc);
+`
+	if findings := check(t, New(), src); len(findings) != 0 {
+		t.Fatalf("expected no findings, got %v", findings)
+	}
+}
+
+func TestSyntaxErrorsAreReturned(t *testing.T) {
+	if _, err := New().Check("print ;"); err == nil {
+		t.Fatalf("expected a syntax error")
+	}
+}
+
+func TestFindingString(t *testing.T) {
+	f := Finding{Line: 3, Rule: "shadowed", Severity: Warning, Message: "'a' shadows the variable declared on line 1."}
+	want := "[Line 3] Warning [shadowed]: 'a' shadows the variable declared on line 1."
+	if f.String() != want {
+		t.Fatalf("got %q, want %q", f.String(), want)
+	}
+}
+
+func TestUndeclaredAssignmentSuggestsAName(t *testing.T) {
+	findings := check(t, New(), "var count = 0;\ncoutn = 1;\nprint count;\n")
+	want := "Assignment to undeclared global 'coutn'. Did you mean 'count'?"
+	if len(findings) != 1 || findings[0].Message != want {
+		t.Fatalf("expected %q, got %v", want, findings)
+	}
+}
@@ -0,0 +1,149 @@
+package lint
+
+import (
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/suggest"
+)
+
+// Names starting with an underscore are deliberately unused.
+func ignored(symbol *resolver.Symbol) bool {
+	return strings.HasPrefix(symbol.Name.Lexeme, "_")
+}
+
+func isRead(symbol *resolver.Symbol) bool {
+	for _, ref := range symbol.References {
+		if !ref.Assign {
+			return true
+		}
+	}
+	return false
+}
+
+func checkUnusedVariables(p *pass) {
+	for _, symbol := range p.resolver.Symbols() {
+		if symbol.Kind == resolver.SymbolVariable && !symbol.Global && !ignored(symbol) && !isRead(symbol) {
+			p.report(symbol.Name.Line, symbol.Name.Column, Warning, "Local variable '%s' is never read.", symbol.Name.Lexeme)
+		}
+	}
+}
+
+func checkUnusedParameters(p *pass) {
+	for _, symbol := range p.resolver.Symbols() {
+		if symbol.Kind == resolver.SymbolParameter && !ignored(symbol) && !isRead(symbol) {
+			p.report(symbol.Name.Line, symbol.Name.Column, Warning, "Parameter '%s' is never read.", symbol.Name.Lexeme)
+		}
+	}
+}
+
+func checkShadowed(p *pass) {
+	for _, symbol := range p.resolver.Symbols() {
+		if symbol.Global || symbol.Shadows == nil {
+			continue
+		}
+		p.report(symbol.Name.Line, symbol.Name.Column, Warning, "'%s' shadows the %s declared on line %d.",
+			symbol.Name.Lexeme, symbol.Shadows.Kind, symbol.Shadows.Name.Line)
+	}
+}
+
+// checkUnreachable reports the first statement after a return in a block
+// or function body. Statements the parser synthesized, like the increment
+// of a desugared for loop, have no line range and are skipped.
+func checkUnreachable(p *pass) {
+	check := func(list []ast.Stmt) {
+		for i, stmt := range list[:max(len(list)-1, 0)] {
+			if _, ok := stmt.(*ast.Return); !ok {
+				continue
+			}
+			if r, ok := p.lines[list[i+1]]; ok {
+				p.report(r.Start, list[i+1].Extent().Start.Column, Warning, "Unreachable code after 'return'.")
+			}
+			return
+		}
+	}
+
+	p.inspect(func(node any) bool {
+		switch n := node.(type) {
+		case *ast.Block:
+			check(n.Statements)
+		case *ast.Function:
+			check(n.Body)
+		}
+		return true
+	})
+}
+
+func checkUndeclaredAssignments(p *pass) {
+	for _, ref := range p.resolver.Unresolved() {
+		if ref.Assign {
+			p.report(ref.Token.Line, ref.Token.Column, Warning, "Assignment to undeclared global '%s'.%s",
+				ref.Token.Lexeme, suggest.Hint(ref.Suggestion))
+		}
+	}
+}
+
+func checkMethodsWithoutThis(p *pass) {
+	p.inspect(func(node any) bool {
+		class, ok := node.(*ast.Class)
+		if !ok {
+			return true
+		}
+		for _, method := range class.Methods {
+			if method.Name.Lexeme == "init" || len(method.Body) == 0 || usesThis(method) {
+				continue
+			}
+			p.report(method.Name.Line, method.Name.Column, Warning, "Method '%s.%s' never uses 'this'.",
+				class.Name.Lexeme, method.Name.Lexeme)
+		}
+		return true
+	})
+}
+
+// usesThis looks for 'this' or 'super' in a method, including closures
+// declared in it but not nested classes, which bind their own 'this'.
+func usesThis(method *ast.Function) bool {
+	found := false
+	for _, stmt := range method.Body {
+		ast.Inspect(stmt, func(node any) bool {
+			switch node.(type) {
+			case *ast.This, *ast.Super:
+				found = true
+			case *ast.Class:
+				return false
+			}
+			return !found
+		})
+	}
+	return found
+}
+
+func checkInitReturns(p *pass) {
+	p.inspect(func(node any) bool {
+		class, ok := node.(*ast.Class)
+		if !ok {
+			return true
+		}
+		for _, method := range class.Methods {
+			if method.Name.Lexeme != "init" {
+				continue
+			}
+			for _, stmt := range method.Body {
+				ast.Inspect(stmt, func(node any) bool {
+					switch n := node.(type) {
+					case *ast.Return:
+						if n.Value != nil {
+							p.report(n.Keyword.Line, n.Keyword.Column, Error, "'%s.init' returns a value; initializers always return 'this'.",
+								class.Name.Lexeme)
+						}
+					case *ast.Function, *ast.Class:
+						return false // returns in here belong to another function
+					}
+					return true
+				})
+			}
+		}
+		return true
+	})
+}
@@ -0,0 +1,213 @@
+// Package loxtest runs tests written in Lox. Test files are named
+// *_test.lox, and every top-level function in them whose name starts
+// with "test_" is a test. Each test runs in a fresh interpreter: the
+// file's top-level code runs first, then the test function is called.
+// Tests check their results with the assert, assertEqual and assertThrows
+// natives.
+package loxtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// Suffix marks a Lox test file.
+const Suffix = "_test.lox"
+
+// Result is the outcome of one test.
+type Result struct {
+	File   string
+	Name   string
+	Passed bool
+	// Line, Message and Code describe the failure. Code is the zero Code
+	// when the test did not fail with a runtime error.
+	Line    int
+	Message string
+	Code    codes.Code
+	// Output is what the test printed, including the file's top-level code.
+	Output   string
+	Duration time.Duration
+}
+
+// Discover lists the test files under each path, sorted. A path naming a
+// file is taken as is, whatever its name.
+func Discover(paths ...string) ([]string, error) {
+	var files []string
+	for _, root := range paths {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, root)
+			continue
+		}
+		err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.HasSuffix(path, Suffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// RunFile runs every test in a file, in source order. The error reports a
+// file that cannot be read, has syntax or resolution errors, given as a
+// shared.Errors, or declares no tests.
+func RunFile(path string) ([]Result, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	src := string(data)
+
+	program, err := load(interpreter.NewInterpreter(), src)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, stmt := range program {
+		if fn, ok := stmt.(*ast.Function); ok && strings.HasPrefix(fn.Name.Lexeme, "test_") {
+			names = append(names, fn.Name.Lexeme)
+		}
+	}
+	if len(names) == 0 {
+		return nil, errors.New("no test_ functions")
+	}
+
+	results := make([]Result, len(names))
+	for i, name := range names {
+		results[i] = run(path, src, name)
+	}
+	return results, nil
+}
+
+// run runs one test in its own interpreter.
+func run(path, src, name string) Result {
+	result := Result{File: path, Name: name}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	var output bytes.Buffer
+	in := interpreter.NewInterpreter()
+	in.SetOutput(&output, &output)
+	DefineNatives(in)
+
+	program, err := load(in, src)
+	if err != nil {
+		result.Message = err.Error()
+		return result
+	}
+
+	shared.ResetErrors()
+	in.Interpret(program)
+	if shared.HadRuntimeError {
+		result.Message = "top-level code failed"
+		result.Output = output.String()
+		return result
+	}
+
+	_, err = in.Call(in.Globals().GetAt(0, name), nil)
+	result.Output = output.String()
+	var rt interpreter.RuntimeError
+	if errors.As(err, &rt) {
+		result.Line, result.Message, result.Code = rt.Token.Line, rt.Message, rt.Code
+		return result
+	}
+	result.Passed = true
+	return result
+}
+
+// load parses and resolves src for in.
+func load(in *interpreter.Interpreter, src string) ([]ast.Stmt, error) {
+	var program []ast.Stmt
+	diagnostics := shared.Collect(func() {
+		program = parser.NewParser(scanner.NewScanner(src).WithInterner(in.Strings()).ScanTokens()).Parse()
+	})
+	if len(diagnostics) == 0 {
+		diagnostics = shared.Collect(func() {
+			resolver.NewResolver(in).Resolve(program)
+		})
+	}
+	if len(diagnostics) > 0 {
+		return nil, shared.Errors(diagnostics)
+	}
+	return program, nil
+}
+
+// DefineNatives adds the assertion functions to in's globals:
+//
+//	assert(value)                  fails unless value is truthy
+//	assertEqual(actual, expected)  fails unless the two are equal
+//	assertThrows(fn)               calls fn, failing unless it raises a
+//	                               runtime error; returns the error message
+func DefineNatives(in *interpreter.Interpreter) {
+	in.DefineNative(&interpreter.NativeFunction{
+		Name:   "assert",
+		Params: 1,
+		Fn: func(in *interpreter.Interpreter, args []interpreter.Value) interpreter.Value {
+			if !args[0].Truthy() {
+				in.Fail(fmt.Sprintf("Assertion failed: got %s.", describe(args[0])))
+			}
+			return interpreter.Nil
+		},
+	})
+	in.DefineNative(&interpreter.NativeFunction{
+		Name:   "assertEqual",
+		Params: 2,
+		Fn: func(in *interpreter.Interpreter, args []interpreter.Value) interpreter.Value {
+			if !args[0].Equals(args[1]) {
+				in.Fail(fmt.Sprintf("Expected %s but got %s.", describe(args[1]), describe(args[0])))
+			}
+			return interpreter.Nil
+		},
+	})
+	in.DefineNative(&interpreter.NativeFunction{
+		Name:   "assertThrows",
+		Params: 1,
+		Fn: func(in *interpreter.Interpreter, args []interpreter.Value) interpreter.Value {
+			if fn, ok := args[0].AsObject().(interpreter.LoxCallable); !ok || fn.Arity() != 0 {
+				in.Fail("assertThrows expects a function with no parameters.")
+			}
+			_, err := in.Call(args[0], nil)
+			var rt interpreter.RuntimeError
+			if !errors.As(err, &rt) {
+				in.Fail("Expected a runtime error.")
+			}
+			return interpreter.String(rt.Message)
+		},
+	})
+}
+
+// describe shows a value in a failure message, quoting strings so that
+// "1" and 1 can be told apart.
+func describe(v interpreter.Value) string {
+	if str, ok := v.AsString(); ok {
+		return strconv.Quote(str)
+	}
+	return v.String()
+}
@@ -0,0 +1,138 @@
+package loxtest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func write(t *testing.T, dir, name, src string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(src), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiscoverFindsTestFilesRecursively(t *testing.T) {
+	dir := t.TempDir()
+	write(t, dir, "b_test.lox", "")
+	write(t, dir, "a.lox", "")
+	write(t, dir, "nested/a_test.lox", "")
+	single := write(t, dir, "explicit.lox", "")
+
+	files, err := Discover(dir, single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		rel, _ := filepath.Rel(dir, f)
+		names = append(names, filepath.ToSlash(rel))
+	}
+	if got := strings.Join(names, " "); got != "b_test.lox explicit.lox nested/a_test.lox" {
+		t.Fatalf("unexpected files: %s", got)
+	}
+}
+
+const suite = `var shared = 0;
+print "setup";
+
+fun test_pass() {
+  shared = shared + 1;
+  assertEqual(shared, 1);
+  assert("non-empty");
+}
+
+fun test_isolated() {
+  shared = shared + 1;
+  assertEqual(shared, 1);
+}
+
+fun test_equal_fails() {
+  print "before";
+  assertEqual("1", 1);
+}
+
+fun test_assert_fails() {
+  assert(nil);
+}
+
+fun test_throws() {
+  fun boom() { return -"x"; }
+  assertEqual(assertThrows(boom), "Operand must be a number.");
+}
+
+fun test_throws_fails() {
+  fun fine() {}
+  assertThrows(fine);
+}
+
+fun test_runtime_error() {
+  undefinedFunction();
+}
+
+fun helper() {}
+`
+
+func TestRunFileReportsEachTest(t *testing.T) {
+	path := write(t, t.TempDir(), "suite_test.lox", suite)
+	results, err := RunFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type outcome struct {
+		name    string
+		passed  bool
+		line    int
+		message string
+	}
+	want := []outcome{
+		{"test_pass", true, 0, ""},
+		{"test_isolated", true, 0, ""},
+		{"test_equal_fails", false, 17, "Expected 1 but got \"1\"."},
+		{"test_assert_fails", false, 21, "Assertion failed: got nil."},
+		{"test_throws", true, 0, ""},
+		{"test_throws_fails", false, 31, "Expected a runtime error."},
+		{"test_runtime_error", false, 35, "Undefined variable 'undefinedFunction'."},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("expected %d results, got %+v", len(want), results)
+	}
+	for i, w := range want {
+		r := results[i]
+		if r.Name != w.name || r.Passed != w.passed || r.Line != w.line || r.Message != w.message {
+			t.Errorf("result %d: got %+v, want %+v", i, r, w)
+		}
+	}
+	if results[2].Output != "setup\nbefore\n" {
+		t.Errorf("expected the test's output to be captured, got %q", results[2].Output)
+	}
+}
+
+func TestRunFileRejectsBrokenFiles(t *testing.T) {
+	dir := t.TempDir()
+	for name, src := range map[string]string{
+		"syntax_test.lox":  "fun test_a() { assert(true) }",
+		"empty_test.lox":   "fun helper() {}",
+		"resolve_test.lox": "fun test_a() { return; } return 1;",
+	} {
+		if _, err := RunFile(write(t, dir, name, src)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	results, err := RunFile(write(t, dir, "toplevel_test.lox", "fun test_a() {}\nprint 1 + nil;"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results[0].Passed || !strings.Contains(results[0].Output, "must be a number") {
+		t.Fatalf("expected a failing top-level to fail the test, got %+v", results[0])
+	}
+}
@@ -0,0 +1,248 @@
+package lsp
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// document is an open file and everything the server learned from
+// running the front end over it.
+type document struct {
+	uri         string
+	text        string
+	lines       []string
+	diagnostics []shared.Diagnostic
+	resolver    *resolver.Resolver
+	natives     []string
+}
+
+func analyze(uri, text string) *document {
+	doc := &document{uri: uri, text: text, lines: strings.Split(text, "\n")}
+
+	in := interpreter.NewInterpreter()
+	doc.natives = in.GlobalNames()
+
+	var tokens []scanner.Token
+	doc.diagnostics = shared.Collect(func() {
+		tokens = scanner.NewScanner(text).ScanTokens()
+	})
+	statements, syntax := parser.NewParser(tokens).ParsePartial()
+	doc.diagnostics = append(doc.diagnostics, syntax...)
+
+	// Resolve the partial tree, even after syntax errors, so navigation
+	// keeps working while the user is typing.
+	doc.resolver = resolver.NewResolver(in)
+	doc.diagnostics = append(doc.diagnostics, shared.Collect(func() {
+		doc.resolver.Resolve(statements)
+	})...)
+	return doc
+}
+
+func (d *document) publishable() PublishDiagnosticsParams {
+	params := PublishDiagnosticsParams{URI: d.uri, Diagnostics: []Diagnostic{}}
+	for _, diag := range d.diagnostics {
+		// Underline just the offending text when its column is known.
+		line := diag.Line - 1
+		r := d.lineRange(line)
+		if diag.Column > 0 {
+			r.Start.Character = d.character(line, diag.Column-1)
+			r.End.Character = d.character(line, diag.Column-1+max(diag.Length, 1))
+		}
+		params.Diagnostics = append(params.Diagnostics, Diagnostic{
+			Range:    r,
+			Severity: severityError,
+			Source:   "glox",
+			Code:     diag.Code.ID,
+			Message:  "Error" + diag.Where + ": " + diag.Message,
+		})
+	}
+	return params
+}
+
+func (d *document) lineRange(line int) Range {
+	end := d.character(line, len(d.line(line)))
+	return Range{Start: Position{Line: line}, End: Position{Line: line, Character: end}}
+}
+
+func (d *document) tokenRange(token scanner.Token) Range {
+	line := token.Line - 1
+	start := Position{Line: line, Character: d.character(line, token.Column-1)}
+	end := Position{Line: line, Character: d.character(line, token.Column-1+len(token.Lexeme))}
+	return Range{Start: start, End: end}
+}
+
+// line is a zero-based line of the document without its line ending, or
+// "" past either end.
+func (d *document) line(line int) string {
+	if line < 0 || line >= len(d.lines) {
+		return ""
+	}
+	return strings.TrimRight(d.lines[line], "\r")
+}
+
+// character converts a zero-based byte offset into a line to a Position
+// character, which LSP counts in UTF-16 code units. Offsets past the end
+// of the line are clamped to it.
+func (d *document) character(line, offset int) int {
+	text := d.line(line)
+	character := 0
+	for _, r := range text[:min(max(offset, 0), len(text))] {
+		character += utf16Len(r)
+	}
+	return character
+}
+
+// offset converts a Position back to a zero-based byte offset into its
+// line. A character in the middle of a surrogate pair, or past the end
+// of the line, moves to the end of that rune or of the line.
+func (d *document) offset(pos Position) int {
+	text := d.line(pos.Line)
+	character := 0
+	for i, r := range text {
+		if character >= pos.Character {
+			return i
+		}
+		character += utf16Len(r)
+	}
+	return len(text)
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+func (d *document) symbolAt(pos Position) *resolver.Symbol {
+	return d.resolver.SymbolAt(pos.Line+1, d.offset(pos)+1)
+}
+
+func (d *document) definition(pos Position) []Location {
+	symbol := d.symbolAt(pos)
+	if symbol == nil {
+		return []Location{}
+	}
+	return []Location{{URI: d.uri, Range: d.tokenRange(symbol.Name)}}
+}
+
+func (d *document) references(pos Position, includeDeclaration bool) []Location {
+	symbol := d.symbolAt(pos)
+	if symbol == nil {
+		return []Location{}
+	}
+
+	locations := []Location{}
+	if includeDeclaration {
+		locations = append(locations, Location{URI: d.uri, Range: d.tokenRange(symbol.Name)})
+	}
+	for _, ref := range symbol.References {
+		locations = append(locations, Location{URI: d.uri, Range: d.tokenRange(ref.Token)})
+	}
+	return locations
+}
+
+func (d *document) hover(pos Position) *Hover {
+	symbol := d.symbolAt(pos)
+	if symbol == nil {
+		return nil
+	}
+
+	return &Hover{
+		Contents: MarkupContent{Kind: "markdown", Value: "```lox\n" + describe(symbol) + "\n```"},
+	}
+}
+
+// describe renders the one-line signature shown on hover and completion.
+func describe(symbol *resolver.Symbol) string {
+	switch symbol.Kind {
+	case resolver.SymbolFunction:
+		return "fun " + signature(symbol.Function)
+	case resolver.SymbolMethod:
+		return symbol.Class.Name.Lexeme + "." + signature(symbol.Function)
+	case resolver.SymbolClass:
+		text := "class " + symbol.Name.Lexeme
+		if super, ok := symbol.Class.Superclass.(*ast.Variable); ok {
+			text += " < " + super.Name.Lexeme
+		}
+		for _, method := range symbol.Class.Methods {
+			text += "\n  " + signature(method)
+		}
+		return text
+	case resolver.SymbolParameter:
+		return "(parameter) " + symbol.Name.Lexeme
+	default:
+		return "var " + symbol.Name.Lexeme
+	}
+}
+
+func signature(fn *ast.Function) string {
+	params := make([]string, 0, len(fn.Params))
+	for _, param := range fn.Params {
+		params = append(params, param.Lexeme)
+	}
+	return fmt.Sprintf("%s(%s)", fn.Name.Lexeme, strings.Join(params, ", "))
+}
+
+// completion offers class methods after a '.', and globals elsewhere.
+func (d *document) completion(pos Position) []CompletionItem {
+	items := []CompletionItem{}
+	seen := map[string]bool{}
+	add := func(item CompletionItem) {
+		if !seen[item.Label] {
+			seen[item.Label] = true
+			items = append(items, item)
+		}
+	}
+
+	if d.afterDot(pos) {
+		for _, symbol := range d.resolver.Symbols() {
+			if symbol.Kind == resolver.SymbolMethod && symbol.Name.Lexeme != "init" {
+				add(CompletionItem{Label: symbol.Name.Lexeme, Kind: completionMethod, Detail: describe(symbol)})
+			}
+		}
+	} else {
+		for _, symbol := range d.resolver.Symbols() {
+			if !symbol.Global {
+				continue
+			}
+			kind := completionVariable
+			switch symbol.Kind {
+			case resolver.SymbolFunction:
+				kind = completionFunction
+			case resolver.SymbolClass:
+				kind = completionClass
+			}
+			add(CompletionItem{Label: symbol.Name.Lexeme, Kind: kind, Detail: strings.SplitN(describe(symbol), "\n", 2)[0]})
+		}
+		for _, name := range d.natives {
+			add(CompletionItem{Label: name, Kind: completionFunction, Detail: "native fn"})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items
+}
+
+// afterDot reports whether the identifier being typed at pos follows a
+// '.', as in "point.|" or "point.x|".
+func (d *document) afterDot(pos Position) bool {
+	line := d.line(pos.Line)
+	i := d.offset(pos)
+	for i > 0 && isIdentifierByte(line[i-1]) {
+		i--
+	}
+	return i > 0 && line[i-1] == '.'
+}
+
+func isIdentifierByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
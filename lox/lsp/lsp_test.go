@@ -0,0 +1,318 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// client drives a Server in-process over a pair of pipes.
+type client struct {
+	t      *testing.T
+	writer io.WriteCloser
+	reader *bufio.Reader
+	nextID int
+	done   chan error
+}
+
+func startServer(t *testing.T) *client {
+	t.Helper()
+
+	clientToServer, serverIn := io.Pipe()
+	serverOut, serverToClient := io.Pipe()
+
+	c := &client{
+		t:      t,
+		writer: serverIn,
+		reader: bufio.NewReader(serverOut),
+		done:   make(chan error, 1),
+	}
+	go func() {
+		c.done <- NewServer(clientToServer, serverToClient).Serve()
+		serverToClient.Close()
+	}()
+	return c
+}
+
+func (c *client) send(v any) {
+	c.t.Helper()
+	if err := writeMessage(c.writer, v); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+func (c *client) receive() map[string]json.RawMessage {
+	c.t.Helper()
+	body, err := readMessage(c.reader)
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	var msg map[string]json.RawMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		c.t.Fatalf("decode %s: %v", body, err)
+	}
+	return msg
+}
+
+func (c *client) request(method string, params any, result any) {
+	c.t.Helper()
+	c.nextID++
+	c.send(map[string]any{"jsonrpc": "2.0", "id": c.nextID, "method": method, "params": params})
+
+	msg := c.receive()
+	if errBody, ok := msg["error"]; ok {
+		c.t.Fatalf("%s failed: %s", method, errBody)
+	}
+	if result != nil {
+		if err := json.Unmarshal(msg["result"], result); err != nil {
+			c.t.Fatalf("decode %s result %s: %v", method, msg["result"], err)
+		}
+	}
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	c.send(map[string]any{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	c.t.Helper()
+	msg := c.receive()
+	var method string
+	_ = json.Unmarshal(msg["method"], &method)
+	if method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("expected publishDiagnostics, got %v", msg)
+	}
+	var params PublishDiagnosticsParams
+	if err := json.Unmarshal(msg["params"], &params); err != nil {
+		c.t.Fatalf("decode diagnostics: %v", err)
+	}
+	return params
+}
+
+func (c *client) close() {
+	c.t.Helper()
+	c.request("shutdown", nil, nil)
+	c.notify("exit", nil)
+
+	select {
+	case err := <-c.done:
+		if err != nil {
+			c.t.Fatalf("Serve returned %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		c.t.Fatalf("server did not exit")
+	}
+}
+
+const uri = "file:///test.lox"
+
+const source = `class Shape {
+  area() { return 0; }
+}
+class Square < Shape {
+  init(side) { this.side = side; }
+  area() { return this.side * this.side; }
+}
+fun total(a, b) {
+  var sum = a.area() + b.area();
+  return sum;
+}
+var sq = Square(2);
+print total(sq, sq);
+`
+
+func at(line, character int) map[string]any {
+	return map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+		"position":     map[string]any{"line": line, "character": character},
+	}
+}
+
+func openDocument(c *client, text string) PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{"uri": uri, "languageId": "lox", "version": 1, "text": text},
+	})
+	return c.diagnostics()
+}
+
+func TestInitializeAdvertisesCapabilities(t *testing.T) {
+	c := startServer(t)
+
+	var result InitializeResult
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &result)
+	c.notify("initialized", map[string]any{})
+
+	caps := result.Capabilities
+	if !caps.DefinitionProvider || !caps.ReferencesProvider || !caps.HoverProvider || caps.TextDocumentSync != textDocumentSyncFull {
+		t.Fatalf("unexpected capabilities %+v", caps)
+	}
+	c.close()
+}
+
+func TestDiagnosticsArePublishedAndCleared(t *testing.T) {
+	c := startServer(t)
+
+	diags := openDocument(c, "var a = 1;\nprint a +;\n")
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diags.Diagnostics)
+	}
+	d := diags.Diagnostics[0]
+	if d.Range.Start.Line != 1 || !strings.Contains(d.Message, "Expect expression.") {
+		t.Errorf("unexpected diagnostic %+v", d)
+	}
+
+	c.notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": 2},
+		"contentChanges": []any{map[string]any{"text": "var a = 1;\nprint a + 1;\n"}},
+	})
+	if diags := c.diagnostics(); len(diags.Diagnostics) != 0 {
+		t.Errorf("expected diagnostics to clear, got %+v", diags.Diagnostics)
+	}
+
+	resolverDiags := func() PublishDiagnosticsParams {
+		c.notify("textDocument/didChange", map[string]any{
+			"textDocument":   map[string]any{"uri": uri, "version": 3},
+			"contentChanges": []any{map[string]any{"text": "return 1;"}},
+		})
+		return c.diagnostics()
+	}()
+	if len(resolverDiags.Diagnostics) != 1 || !strings.Contains(resolverDiags.Diagnostics[0].Message, "top-level") {
+		t.Errorf("expected resolver diagnostic, got %+v", resolverDiags.Diagnostics)
+	}
+	c.close()
+}
+
+func TestDefinitionAndReferences(t *testing.T) {
+	c := startServer(t)
+	openDocument(c, source)
+
+	// "sum" in "return sum;" on line 10 (zero-based 9).
+	var defs []Location
+	c.request("textDocument/definition", at(9, 10), &defs)
+	if len(defs) != 1 || defs[0].Range.Start != (Position{Line: 8, Character: 6}) {
+		t.Fatalf("unexpected definition %+v", defs)
+	}
+
+	// "sq" in the var declaration on line 12.
+	params := at(11, 4)
+	params["context"] = map[string]any{"includeDeclaration": true}
+	var refs []Location
+	c.request("textDocument/references", params, &refs)
+	if len(refs) != 3 {
+		t.Fatalf("expected declaration plus 2 references, got %+v", refs)
+	}
+	for _, ref := range refs[1:] {
+		if ref.Range.Start.Line != 12 {
+			t.Errorf("expected reference on line 12, got %+v", ref)
+		}
+	}
+	c.close()
+}
+
+func TestHoverShowsSignatures(t *testing.T) {
+	c := startServer(t)
+	openDocument(c, source)
+
+	cases := []struct {
+		line, character int
+		want            string
+	}{
+		{12, 7, "fun total(a, b)"},
+		{11, 10, "class Square < Shape\n  init(side)\n  area()"},
+		{5, 3, "Square.area()"},
+		{7, 10, "(parameter) a"},
+	}
+	for _, tc := range cases {
+		var hover Hover
+		c.request("textDocument/hover", at(tc.line, tc.character), &hover)
+		if !strings.Contains(hover.Contents.Value, tc.want) {
+			t.Errorf("hover at %d:%d: expected %q in %q", tc.line, tc.character, tc.want, hover.Contents.Value)
+		}
+	}
+	c.close()
+}
+
+func TestCompletionOffersGlobalsAndMethods(t *testing.T) {
+	c := startServer(t)
+	openDocument(c, source)
+
+	labels := func(items []CompletionItem) string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.Label)
+		}
+		return strings.Join(names, ",")
+	}
+
+	var globals []CompletionItem
+	c.request("textDocument/completion", at(13, 0), &globals)
+	if got := labels(globals); got != "Shape,Square,clock,sq,total" {
+		t.Errorf("unexpected global completions %q", got)
+	}
+
+	// After "a." on line 9.
+	var methods []CompletionItem
+	c.request("textDocument/completion", at(8, 14), &methods)
+	if got := labels(methods); got != "area" {
+		t.Errorf("unexpected method completions %q", got)
+	}
+	c.close()
+}
+
+func TestNavigationSurvivesSyntaxErrors(t *testing.T) {
+	c := startServer(t)
+	diags := openDocument(c, "class Point {\n  broken(a b) {}\n  norm(v) { return v; }\n}\nfun f(x) {\n  print x\n  return x;\n}\n")
+	if len(diags.Diagnostics) != 2 {
+		t.Fatalf("expected 2 syntax errors, got %+v", diags.Diagnostics)
+	}
+
+	// "x" in "return x;" still resolves to the parameter, after the
+	// broken statement before it.
+	var defs []Location
+	c.request("textDocument/definition", at(6, 9), &defs)
+	if len(defs) != 1 || defs[0].Range.Start != (Position{Line: 4, Character: 6}) {
+		t.Fatalf("unexpected definition %+v", defs)
+	}
+
+	// The method after the broken one is kept.
+	var hover Hover
+	c.request("textDocument/hover", at(0, 7), &hover)
+	if !strings.Contains(hover.Contents.Value, "norm(v)") {
+		t.Errorf("expected norm(v) in %q", hover.Contents.Value)
+	}
+	c.close()
+}
+
+func TestPositionsCountUTF16CodeUnits(t *testing.T) {
+	c := startServer(t)
+
+	// "é" is one UTF-16 code unit but two bytes, and "😀" is two code
+	// units but four bytes, so the last "s" is at character 21, byte 24.
+	diags := openDocument(c, `var s = "é😀"; print s; print s +;`)
+	if len(diags.Diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %+v", diags.Diagnostics)
+	}
+	if r := diags.Diagnostics[0].Range; r.Start.Character != 33 || r.End.Character != 34 {
+		t.Errorf("expected the diagnostic to underline ';' at 33-34, got %+v", r)
+	}
+
+	params := at(0, 21)
+	params["context"] = map[string]any{"includeDeclaration": true}
+	var refs []Location
+	c.request("textDocument/references", params, &refs)
+	var starts []int
+	for _, ref := range refs {
+		starts = append(starts, ref.Range.Start.Character)
+		if width := ref.Range.End.Character - ref.Range.Start.Character; width != 1 {
+			t.Errorf("expected a one-character range, got %+v", ref.Range)
+		}
+	}
+	if len(starts) != 2 || starts[0] != 4 || starts[1] != 21 {
+		t.Errorf("expected the declaration at character 4 and a reference at 21, got %v", starts)
+	}
+	c.close()
+}
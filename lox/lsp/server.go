@@ -0,0 +1,210 @@
+// Package lsp implements a Language Server Protocol server for Lox. It
+// reuses the scanner, parser and resolver to publish diagnostics and to
+// answer definition, references, hover and completion requests.
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+type Server struct {
+	reader *bufio.Reader
+
+	writeMu sync.Mutex
+	writer  io.Writer
+
+	documents map[string]*document
+	shutdown  bool
+}
+
+func NewServer(r io.Reader, w io.Writer) *Server {
+	return &Server{
+		reader:    bufio.NewReader(r),
+		writer:    w,
+		documents: make(map[string]*document),
+	}
+}
+
+// Serve handles messages until the client sends "exit" or closes the
+// stream.
+func (s *Server) Serve() error {
+	for {
+		body, err := readMessage(s.reader)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var msg message
+		if err := json.Unmarshal(body, &msg); err != nil {
+			s.replyError(json.RawMessage("null"), codeParseError, err.Error())
+			continue
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+		s.handle(msg)
+	}
+}
+
+func (s *Server) handle(msg message) {
+	isRequest := len(msg.ID) > 0
+
+	result, err := s.dispatch(msg)
+	if !isRequest {
+		return
+	}
+	if err != nil {
+		s.replyError(msg.ID, err.code, err.message)
+		return
+	}
+	s.reply(msg.ID, result)
+}
+
+type handlerError struct {
+	code    int
+	message string
+}
+
+func (s *Server) dispatch(msg message) (any, *handlerError) {
+	if s.shutdown {
+		return nil, &handlerError{code: codeInvalidRequest, message: "server is shutting down"}
+	}
+
+	switch msg.Method {
+	case "initialize":
+		var result InitializeResult
+		result.ServerInfo.Name = "glox"
+		result.Capabilities = ServerCapabilities{
+			TextDocumentSync:   textDocumentSyncFull,
+			DefinitionProvider: true,
+			ReferencesProvider: true,
+			HoverProvider:      true,
+			CompletionProvider: CompletionOptions{TriggerCharacters: []string{"."}},
+		}
+		return result, nil
+
+	case "initialized":
+		return nil, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		s.update(params.TextDocument.URI, params.TextDocument.Text)
+		return nil, nil
+
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		if n := len(params.ContentChanges); n > 0 {
+			s.update(params.TextDocument.URI, params.ContentChanges[n-1].Text)
+		}
+		return nil, nil
+
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		delete(s.documents, params.TextDocument.URI)
+		s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+			URI:         params.TextDocument.URI,
+			Diagnostics: []Diagnostic{},
+		})
+		return nil, nil
+
+	case "textDocument/definition":
+		doc, pos, err := s.position(msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return doc.definition(pos), nil
+
+	case "textDocument/references":
+		var params ReferenceParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return nil, invalidParams(err)
+		}
+		doc, pos, err := s.position(msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return doc.references(pos, params.Context.IncludeDeclaration), nil
+
+	case "textDocument/hover":
+		doc, pos, err := s.position(msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return doc.hover(pos), nil
+
+	case "textDocument/completion":
+		doc, pos, err := s.position(msg.Params)
+		if err != nil {
+			return nil, err
+		}
+		return doc.completion(pos), nil
+	}
+
+	return nil, &handlerError{code: codeMethodNotFound, message: "method not found: " + msg.Method}
+}
+
+func invalidParams(err error) *handlerError {
+	return &handlerError{code: codeInvalidParams, message: err.Error()}
+}
+
+func (s *Server) position(raw json.RawMessage) (*document, Position, *handlerError) {
+	var params TextDocumentPositionParams
+	if err := json.Unmarshal(raw, &params); err != nil {
+		return nil, Position{}, invalidParams(err)
+	}
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, Position{}, &handlerError{code: codeInvalidParams, message: "unknown document " + params.TextDocument.URI}
+	}
+	return doc, params.Position, nil
+}
+
+func (s *Server) update(uri, text string) {
+	doc := analyze(uri, text)
+	s.documents[uri] = doc
+	s.notify("textDocument/publishDiagnostics", doc.publishable())
+}
+
+func (s *Server) reply(id json.RawMessage, result any) {
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.replyError(id, codeInvalidRequest, err.Error())
+		return
+	}
+	s.write(response{JSONRPC: "2.0", ID: id, Result: body})
+}
+
+func (s *Server) replyError(id json.RawMessage, code int, text string) {
+	s.write(response{JSONRPC: "2.0", ID: id, Error: &responseError{Code: code, Message: text}})
+}
+
+func (s *Server) notify(method string, params any) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) write(v any) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	_ = writeMessage(s.writer, v)
+}
@@ -0,0 +1,178 @@
+package optimizer
+
+import "example.com/golox/lox/ast"
+
+// HoistLiterals moves literals out of the loops that bind them to a
+// variable on every iteration. A variable declared in a loop body with a
+// literal initializer, and never assigned, holds that literal in every
+// iteration and in every closure that captures it. Each read of it is
+// replaced by the literal and the declaration is dropped, so the loop no
+// longer defines the variable each time round.
+//
+// Nothing is declared in its place and no statement moves to another
+// scope, so the distances the resolver recorded for the other variables
+// stay valid.
+type HoistLiterals struct{}
+
+func (HoistLiterals) Name() string { return "hoist" }
+
+func (HoistLiterals) Run(statements []ast.Stmt) []ast.Stmt {
+	hoistIn(statements, false)
+	return statements
+}
+
+// hoistIn hoists literals in statements. inLoop is set when they run on
+// every iteration of a loop in the same function.
+func hoistIn(statements []ast.Stmt, inLoop bool) {
+	for _, stmt := range statements {
+		hoistStmt(stmt, inLoop)
+	}
+}
+
+func hoistStmt(stmt ast.Stmt, inLoop bool) {
+	switch s := stmt.(type) {
+	case *ast.Block:
+		hoistIn(s.Statements, inLoop)
+		if inLoop {
+			s.Statements = hoistBlock(s.Statements)
+		}
+	case *ast.Class:
+		for _, method := range s.Methods {
+			hoistIn(method.Body, false)
+		}
+	case *ast.Function:
+		hoistIn(s.Body, false)
+	case *ast.If:
+		hoistStmt(s.ThenBranch, inLoop)
+		hoistStmt(s.ElseBranch, inLoop)
+	case *ast.While:
+		hoistStmt(s.Body, true)
+	}
+}
+
+// hoistBlock drops the declarations of a block in a loop that always bind
+// the same literal, and replaces the reads of each with the literal.
+func hoistBlock(statements []ast.Stmt) []ast.Stmt {
+	out := statements[:0]
+	for i, stmt := range statements {
+		if decl, ok := stmt.(*ast.Var); ok {
+			if literal, ok := decl.Initializer.(*ast.Literal); ok {
+				rest := statements[i+1:]
+				if !assigned(decl.Name.Lexeme, rest) {
+					replaceReads(decl.Name.Lexeme, literal, rest)
+					continue
+				}
+			}
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// assigned reports whether the variable name, declared just before
+// statements, is assigned in them.
+func assigned(name string, statements []ast.Stmt) bool {
+	found := false
+	b := &binding{name: name, use: func(expr ast.Expr) ast.Expr {
+		if _, ok := expr.(*ast.Assign); ok {
+			found = true
+		}
+		return expr
+	}}
+	b.stmts(statements)
+	return found
+}
+
+// replaceReads replaces each read of the variable name, declared just
+// before statements, with literal.
+func replaceReads(name string, literal *ast.Literal, statements []ast.Stmt) {
+	b := &binding{name: name, use: func(expr ast.Expr) ast.Expr {
+		if v, ok := expr.(*ast.Variable); ok {
+			return &ast.Literal{Node: v.Node, Value: literal.Value}
+		}
+		return expr
+	}}
+	b.stmts(statements)
+}
+
+// binding walks the statements that follow a variable's declaration in
+// its block and passes each Variable or Assign naming it to use, which
+// may replace it. A later declaration of the same name shadows the
+// variable, and what it covers is skipped.
+type binding struct {
+	name string
+	use  func(ast.Expr) ast.Expr
+}
+
+func (b *binding) stmts(statements []ast.Stmt) {
+	for _, stmt := range statements {
+		if b.stmt(stmt) {
+			return
+		}
+	}
+}
+
+// stmt walks stmt and reports whether it declares the name again, so that
+// the statements after it in the same block see that declaration instead.
+func (b *binding) stmt(stmt ast.Stmt) bool {
+	switch s := stmt.(type) {
+	case *ast.Block:
+		b.stmts(s.Statements)
+	case *ast.Class:
+		if s.Name.Lexeme == b.name {
+			return true
+		}
+		s.Superclass = b.expr(s.Superclass)
+		for _, method := range s.Methods {
+			b.function(method)
+		}
+	case *ast.Expression:
+		s.Expression = b.expr(s.Expression)
+	case *ast.Function:
+		if s.Name.Lexeme == b.name {
+			return true
+		}
+		b.function(s)
+	case *ast.If:
+		s.Condition = b.expr(s.Condition)
+		b.stmt(s.ThenBranch)
+		b.stmt(s.ElseBranch)
+	case *ast.Print:
+		s.Expression = b.expr(s.Expression)
+	case *ast.Return:
+		s.Value = b.expr(s.Value)
+	case *ast.Var:
+		s.Initializer = b.expr(s.Initializer)
+		return s.Name.Lexeme == b.name
+	case *ast.While:
+		s.Condition = b.expr(s.Condition)
+		b.stmt(s.Body)
+	}
+	return false
+}
+
+func (b *binding) function(fn *ast.Function) {
+	for _, param := range fn.Params {
+		if param.Lexeme == b.name {
+			return
+		}
+	}
+	b.stmts(fn.Body)
+}
+
+func (b *binding) expr(expr ast.Expr) ast.Expr {
+	r := &rewriter{expr: func(expr ast.Expr) ast.Expr {
+		switch e := expr.(type) {
+		case *ast.Variable:
+			if e.Name.Lexeme == b.name {
+				return b.use(e)
+			}
+		case *ast.Assign:
+			if e.Name.Lexeme == b.name {
+				return b.use(e)
+			}
+		}
+		return expr
+	}}
+	return r.expression(expr)
+}
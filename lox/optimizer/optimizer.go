@@ -0,0 +1,129 @@
+// Package optimizer rewrites a resolved program into an equivalent but
+// cheaper one. Passes only ever replace subtrees made of literals, replace
+// reads of variables that always hold a literal, or drop statements that
+// can never run or declarations nothing reads. No scope is added or
+// removed, so the expression nodes the resolver recorded in the
+// interpreter's locals map keep their identity and their distances.
+package optimizer
+
+import "example.com/golox/lox/ast"
+
+// Pass is one rewrite over the whole program.
+type Pass interface {
+	Name() string
+	Run(statements []ast.Stmt) []ast.Stmt
+}
+
+// Pipeline runs a fixed sequence of passes.
+type Pipeline struct {
+	passes []Pass
+}
+
+func NewPipeline(passes ...Pass) *Pipeline {
+	return &Pipeline{passes: passes}
+}
+
+// Default returns the standard pipeline: constants are folded, literals
+// are hoisted out of loops, the constants that leaves are folded, then
+// unreachable code is removed.
+func Default() *Pipeline {
+	return NewPipeline(ConstantFolding{}, HoistLiterals{}, ConstantFolding{}, DeadCode{})
+}
+
+func (p *Pipeline) Passes() []Pass {
+	return p.passes
+}
+
+func (p *Pipeline) Optimize(statements []ast.Stmt) []ast.Stmt {
+	for _, pass := range p.passes {
+		statements = pass.Run(statements)
+	}
+	return statements
+}
+
+// rewriter walks every statement and expression, giving rewriteExpr and
+// rewriteStmt a chance to replace each node after its children have been
+// rewritten.
+type rewriter struct {
+	expr func(ast.Expr) ast.Expr
+	stmt func(ast.Stmt) ast.Stmt
+}
+
+func (r *rewriter) stmts(statements []ast.Stmt) []ast.Stmt {
+	out := statements[:0]
+	for _, s := range statements {
+		if s = r.statement(s); s != nil {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func (r *rewriter) statement(stmt ast.Stmt) ast.Stmt {
+	switch s := stmt.(type) {
+	case nil:
+		return nil
+	case *ast.Block:
+		s.Statements = r.stmts(s.Statements)
+	case *ast.Class:
+		for _, method := range s.Methods {
+			method.Body = r.stmts(method.Body)
+		}
+	case *ast.Expression:
+		s.Expression = r.expression(s.Expression)
+	case *ast.Function:
+		s.Body = r.stmts(s.Body)
+	case *ast.Print:
+		s.Expression = r.expression(s.Expression)
+	case *ast.If:
+		s.Condition = r.expression(s.Condition)
+		s.ThenBranch = r.statement(s.ThenBranch)
+		s.ElseBranch = r.statement(s.ElseBranch)
+	case *ast.Return:
+		s.Value = r.expression(s.Value)
+	case *ast.Var:
+		s.Initializer = r.expression(s.Initializer)
+	case *ast.While:
+		s.Condition = r.expression(s.Condition)
+		s.Body = r.statement(s.Body)
+	}
+
+	if r.stmt == nil {
+		return stmt
+	}
+	return r.stmt(stmt)
+}
+
+func (r *rewriter) expression(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case nil:
+		return nil
+	case *ast.Assign:
+		e.Value = r.expression(e.Value)
+	case *ast.Binary:
+		e.Left = r.expression(e.Left)
+		e.Right = r.expression(e.Right)
+	case *ast.Call:
+		e.Callee = r.expression(e.Callee)
+		for i, arg := range e.Arguments {
+			e.Arguments[i] = r.expression(arg)
+		}
+	case *ast.Get:
+		e.Object = r.expression(e.Object)
+	case *ast.Grouping:
+		e.Expression = r.expression(e.Expression)
+	case *ast.Logical:
+		e.Left = r.expression(e.Left)
+		e.Right = r.expression(e.Right)
+	case *ast.Set:
+		e.Object = r.expression(e.Object)
+		e.Value = r.expression(e.Value)
+	case *ast.Unary:
+		e.Right = r.expression(e.Right)
+	}
+
+	if r.expr == nil {
+		return expr
+	}
+	return r.expr(expr)
+}
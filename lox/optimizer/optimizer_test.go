@@ -0,0 +1,245 @@
+package optimizer
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+func parse(t *testing.T, src string) []ast.Stmt {
+	t.Helper()
+	shared.ResetErrors()
+
+	stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+	if shared.HadError {
+		t.Fatalf("parse reported HadError for source: %q", src)
+	}
+	return stmts
+}
+
+func optimized(t *testing.T, src string) string {
+	t.Helper()
+	stmts := Default().Optimize(parse(t, src))
+	return strings.TrimSpace((&ast.AstPrinter{}).PrintProgram(stmts))
+}
+
+func TestFoldsArithmeticAndConcatenation(t *testing.T) {
+	cases := map[string]string{
+		`print 1 + 2 * 3;`:         "(print 7)",
+		`print (1 + 2) * 3;`:       "(print 9)",
+		`print -(4 / 2);`:          "(print -2)",
+		`print "a" + "b" + "c";`:   "(print abc)",
+		`print 1 < 2 == true;`:     "(print true)",
+		`print !true;`:             "(print false)",
+		`print !nil;`:              "(print true)",
+		`print false or "x";`:      "(print x)",
+		`print nil and undefined;`: "(print nil)",
+	}
+
+	for src, want := range cases {
+		if got := optimized(t, src); got != want {
+			t.Errorf("%s: expected %q, got %q", src, want, got)
+		}
+	}
+}
+
+func TestLeavesRuntimeErrorsAlone(t *testing.T) {
+	for _, src := range []string{`print 1 + "a";`, `print -"a";`, `print true < 1;`} {
+		if got := optimized(t, src); !strings.Contains(got, "(print (") {
+			t.Errorf("%s: expected expression to stay unfolded, got %q", src, got)
+		}
+	}
+}
+
+func TestDropsConstantBranchesAndDeadLoops(t *testing.T) {
+	got := optimized(t, `
+		if (false) print "no"; else print "yes";
+		if (1 > 2) print "gone";
+		while (false) print "never";
+		if (true) { print "kept"; }
+	`)
+	want := "(print yes)\n(block (print kept))"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestDropsCodeAfterReturn(t *testing.T) {
+	got := optimized(t, `
+		fun f() {
+			return 1;
+			print "unreachable";
+		}
+	`)
+	want := "(fun f () (return 1))"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestNestedDeadIfKeepsOuterStatementValid(t *testing.T) {
+	got := optimized(t, `var x = 1; if (x) if (false) print "no";`)
+	want := "(var x 1)\n(if x (block))"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestOptimizingAfterResolveKeepsLocals(t *testing.T) {
+	src := `
+		fun outer() {
+			var a = 2 * 3;
+			fun inner() {
+				if (false) print "dead";
+				return a + (1 + 1);
+			}
+			return inner;
+		}
+		print outer()();
+	`
+	stmts := parse(t, src)
+
+	in := interpreter.NewInterpreter()
+	resolver.NewResolver(in).Resolve(stmts)
+	if shared.HadError {
+		t.Fatalf("unexpected resolver error")
+	}
+	stmts = Default().Optimize(stmts)
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	in.Interpret(stmts)
+	w.Close()
+	os.Stdout = oldStdout
+
+	var buf bytes.Buffer
+	_, _ = io.Copy(&buf, r)
+
+	if shared.HadRuntimeError {
+		t.Fatalf("unexpected runtime error")
+	}
+	if got := strings.TrimSpace(buf.String()); got != "8" {
+		t.Fatalf("expected 8, got %q", got)
+	}
+}
+
+func TestHoistsLiteralsOutOfLoops(t *testing.T) {
+	got := optimized(t, `
+		var i = 0;
+		while (i < 3) {
+			var step = 1;
+			var debug = false;
+			if (debug) print "debugging";
+			{ var step = 10; print step; }
+			i = i + step;
+		}
+	`)
+	want := "(var i 0)\n(while (< i 3) (block (block (print 10)) (; (assign i (+ i 1)))))"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+func TestKeepsVariablesThatAreNotLoopInvariantLiterals(t *testing.T) {
+	got := optimized(t, `
+		var outside = 1;
+		while (outside) {
+			var assigned = 1;
+			fun reset() { assigned = 2; }
+			var computed = outside;
+			print assigned + computed;
+		}
+	`)
+	want := "(var outside 1)\n" +
+		"(while outside (block (var assigned 1) (fun reset () (; (assign assigned 2))) (var computed outside) (print (+ assigned computed))))"
+	if got != want {
+		t.Fatalf("expected %q, got %q", want, got)
+	}
+}
+
+// run resolves and runs src, optimized or not, and returns what it printed.
+func run(t *testing.T, src string, optimize bool) string {
+	t.Helper()
+	stmts := parse(t, src)
+	in := interpreter.NewInterpreter()
+	resolver.NewResolver(in).Resolve(stmts)
+	if shared.HadError {
+		t.Fatalf("unexpected resolver error")
+	}
+	if optimize {
+		stmts = Default().Optimize(stmts)
+	}
+
+	var out bytes.Buffer
+	in.SetOutput(&out, &out)
+	in.Interpret(stmts)
+	if _, failed := in.LastError(); failed {
+		t.Fatalf("unexpected runtime error:\n%s", out.String())
+	}
+	return out.String()
+}
+
+func TestHoistedProgramsRunAsBefore(t *testing.T) {
+	for _, src := range []string{
+		// Closures made in the loop still see the value, and a variable
+		// declared after the hoisted one is still found.
+		`
+		var keep = nil;
+		for (var i = 0; i < 3; i = i + 1) {
+			var label = "round";
+			var count = i;
+			fun show() { print label + " " + label; print count; }
+			keep = show;
+			show();
+		}
+		keep();
+		`,
+		// Parameters, classes and inner declarations of the same name
+		// shadow the hoisted variable.
+		`
+		fun run(n) {
+			var total = 0;
+			while (n > 0) {
+				var step = 2;
+				fun twice(step) { return step * 2; }
+				class Box { get() { return step; } }
+				{
+					print step;
+					var step = "inner";
+					print step;
+				}
+				total = total + twice(n) + Box().get() + step;
+				n = n - 1;
+			}
+			return total;
+		}
+		print run(3);
+		`,
+		// A variable read before the loop's own declaration is the outer one.
+		`
+		var x = "outer";
+		var i = 0;
+		while (i < 2) {
+			print x;
+			var x = 1;
+			print x + i;
+			i = i + x;
+		}
+		`,
+	} {
+		want := run(t, src, false)
+		if got := run(t, src, true); got != want {
+			t.Errorf("optimized program printed\n%s\nwant\n%s\nfor\n%s", got, want, src)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package optimizer
+
+import (
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/scanner"
+)
+
+// ConstantFolding evaluates operators whose operands are all literals.
+// Expressions that would raise a runtime error, such as 1 + "a", are left
+// alone so the error still happens when the program runs.
+type ConstantFolding struct{}
+
+func (ConstantFolding) Name() string { return "fold" }
+
+func (ConstantFolding) Run(statements []ast.Stmt) []ast.Stmt {
+	r := &rewriter{expr: fold}
+	return r.stmts(statements)
+}
+
+func fold(expr ast.Expr) ast.Expr {
+	switch e := expr.(type) {
+	case *ast.Grouping:
+		if lit, ok := e.Expression.(*ast.Literal); ok {
+			return lit
+		}
+
+	case *ast.Unary:
+		right, ok := e.Right.(*ast.Literal)
+		if !ok {
+			return expr
+		}
+		switch e.Operator.Type {
+		case scanner.BANG:
+			return &ast.Literal{Node: e.Node, Value: !isTruthy(right.Value)}
+		case scanner.MINUS:
+			if n, ok := right.Value.(float64); ok {
+				return &ast.Literal{Node: e.Node, Value: -n}
+			}
+		}
+
+	case *ast.Logical:
+		left, ok := e.Left.(*ast.Literal)
+		if !ok {
+			return expr
+		}
+		if e.Operator.Type == scanner.OR {
+			if isTruthy(left.Value) {
+				return left
+			}
+		} else if !isTruthy(left.Value) {
+			return left
+		}
+		return e.Right
+
+	case *ast.Binary:
+		left, lok := e.Left.(*ast.Literal)
+		right, rok := e.Right.(*ast.Literal)
+		if !lok || !rok {
+			return expr
+		}
+		if value, ok := foldBinary(e.Operator.Type, left.Value, right.Value); ok {
+			return &ast.Literal{Node: e.Node, Value: value}
+		}
+	}
+
+	return expr
+}
+
+func foldBinary(op scanner.TokenType, left, right any) (any, bool) {
+	switch op {
+	case scanner.EQUAL_EQUAL:
+		return left == right, true
+	case scanner.BANG_EQUAL:
+		return left != right, true
+	}
+
+	if ls, ok := left.(string); ok {
+		if rs, ok := right.(string); ok && op == scanner.PLUS {
+			return ls + rs, true
+		}
+		return nil, false
+	}
+
+	l, lok := left.(float64)
+	r, rok := right.(float64)
+	if !lok || !rok {
+		return nil, false
+	}
+
+	switch op {
+	case scanner.PLUS:
+		return l + r, true
+	case scanner.MINUS:
+		return l - r, true
+	case scanner.STAR:
+		return l * r, true
+	case scanner.SLASH:
+		return l / r, true
+	case scanner.GREATER:
+		return l > r, true
+	case scanner.GREATER_EQUAL:
+		return l >= r, true
+	case scanner.LESS:
+		return l < r, true
+	case scanner.LESS_EQUAL:
+		return l <= r, true
+	}
+	return nil, false
+}
+
+// DeadCode removes statements that can never execute: branches of an if
+// whose condition is a constant, while loops whose condition is falsey,
+// and anything that follows a return in the same block or body.
+type DeadCode struct{}
+
+func (DeadCode) Name() string { return "dce" }
+
+func (DeadCode) Run(statements []ast.Stmt) []ast.Stmt {
+	r := &rewriter{stmt: eliminate}
+	return r.stmts(statements)
+}
+
+func eliminate(stmt ast.Stmt) ast.Stmt {
+	switch s := stmt.(type) {
+	case *ast.Block:
+		s.Statements = truncateAfterReturn(s.Statements)
+
+	case *ast.Function:
+		s.Body = truncateAfterReturn(s.Body)
+
+	case *ast.Class:
+		for _, method := range s.Methods {
+			method.Body = truncateAfterReturn(method.Body)
+		}
+
+	case *ast.If:
+		if cond, ok := s.Condition.(*ast.Literal); ok {
+			if isTruthy(cond.Value) {
+				return s.ThenBranch
+			}
+			return s.ElseBranch
+		}
+		if s.ThenBranch == nil {
+			s.ThenBranch = &ast.Block{}
+		}
+
+	case *ast.While:
+		if cond, ok := s.Condition.(*ast.Literal); ok && !isTruthy(cond.Value) {
+			return nil
+		}
+		if s.Body == nil {
+			s.Body = &ast.Block{}
+		}
+	}
+
+	return stmt
+}
+
+func truncateAfterReturn(statements []ast.Stmt) []ast.Stmt {
+	for i, stmt := range statements {
+		if _, ok := stmt.(*ast.Return); ok {
+			return statements[:i+1]
+		}
+	}
+	return statements
+}
+
+// isTruthy mirrors the interpreter's rule: nil and false are falsey,
+// everything else is truthy.
+func isTruthy(value any) bool {
+	if value == nil {
+		return false
+	}
+	if b, ok := value.(bool); ok {
+		return b
+	}
+	return true
+}
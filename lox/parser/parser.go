@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"strings"
+
 	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
 	"example.com/golox/lox/scanner"
 	"example.com/golox/lox/shared"
 )
@@ -13,8 +16,18 @@ func (parseError) Error() string { return "parse error" }
 type Parser struct {
 	tokens []scanner.Token
 	current int
+	// reportedAtEnd is set once an error is reported at the end of the
+	// file, where every unclosed block would otherwise report another.
+	reportedAtEnd bool
+
+	lines    map[ast.Stmt]LineRange
+	forLoops map[ast.Stmt]*ForLoop
 }
 
+// Parse parses the whole program, reporting each syntax error. It does
+// not stop at the first: a statement, method or call argument that does
+// not parse is skipped and, where the tree has room for it, replaced by a
+// Bad statement or an Error expression.
 func (p *Parser) Parse() []ast.Stmt {
 	var statements []ast.Stmt
 
@@ -28,10 +41,41 @@ func (p *Parser) Parse() []ast.Stmt {
 	return statements
 }
 
+// ParsePartial parses the program like Parse but returns its syntax
+// errors instead of reporting them, along with the best-effort tree, for
+// tools that work on code while it is being written.
+func (p *Parser) ParsePartial() (statements []ast.Stmt, diagnostics []shared.Diagnostic) {
+	diagnostics = shared.Collect(func() {
+		statements = p.Parse()
+	})
+	return statements, diagnostics
+}
+
+// ParseExpression parses tokens holding a single expression, as typed at
+// a debugger prompt. It returns nil after reporting a syntax error.
+func (p *Parser) ParseExpression() (expr ast.Expr) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(parseError); !ok {
+				panic(r)
+			}
+			expr = nil
+		}
+	}()
+
+	expr = p.expression()
+	if !p.isAtEnd() {
+		panic(p.error(p.peek(), codes.ExpectEndOfExpression))
+	}
+	return expr
+}
+
 func NewParser(tokens []scanner.Token) *Parser {
 	return &Parser{
 		tokens: tokens,
 		current: 0,
+		lines: make(map[ast.Stmt]LineRange),
+		forLoops: make(map[ast.Stmt]*ForLoop),
 	}
 }
 
@@ -39,7 +83,10 @@ func (p *Parser) expression() ast.Expr {
 	return p.assignment()
 }
 
-func (p *Parser) statement() ast.Stmt {
+func (p *Parser) statement() (stmt ast.Stmt) {
+	start := p.peek().Line
+	defer func() { p.recordLines(stmt, start) }()
+
 	if p.match(scanner.FOR) {
 		return p.forStatement()
 	}
@@ -61,43 +108,55 @@ func (p *Parser) statement() ast.Stmt {
 	}
 
 	if p.match(scanner.LEFT_BRACE) {
+		start := p.previous()
+		statements := p.block()
 		return &ast.Block{
-			Statements: p.block(),
-		}	
+			Node:       ast.Node{Span: p.spanFrom(start)},
+			Statements: statements,
+		}
 	}
 
 	return p.expressionStatement()
 }
 
 func (p *Parser) printStatment() ast.Stmt {
+	start := p.previous()
 	value := p.expression()
-	p.consume(scanner.SEMICOLON, "Expect ';' after value.")
+	p.consume(scanner.SEMICOLON, codes.ExpectSemicolonAfterValue)
 	return &ast.Print{
+		Node:       ast.Node{Span: p.spanFrom(start)},
 		Expression: value,
 	}
 }
 
 func (p *Parser) expressionStatement() ast.Stmt {
+	start := p.peek()
 	expr := p.expression()
-	p.consume(scanner.SEMICOLON, "Expect ';' after expression.")
+	p.consume(scanner.SEMICOLON, codes.ExpectSemicolonAfterExpr)
 	return &ast.Expression{
+		Node:       ast.Node{Span: p.spanFrom(start)},
 		Expression: expr,
 	}
 }
 
 func (p *Parser) function(kind string) *ast.Function {
-	name := p.consume(scanner.IDENTIFIER, "Expect " + kind + " name.")
+	// A function's span starts at "fun"; a method has only its name.
+	start := p.peek()
+	if kind == "function" {
+		start = p.previous()
+	}
+	name := p.consume(scanner.IDENTIFIER, codes.ExpectFunctionName, kind)
 
-	p.consume(scanner.LEFT_PAREN, "Expect '(' after " + kind + " name.")
+	p.consume(scanner.LEFT_PAREN, codes.ExpectParenAfterName, kind)
 
 	var parameters []scanner.Token
 	if !p.check(scanner.RIGHT_PAREN) {
 		for {
 			if len(parameters) >= 255 {
-				p.error(p.peek(), "Can't have more than 255 parameters.")
+				p.error(p.peek(), codes.TooManyParameters)
 			}
 
-			param := p.consume(scanner.IDENTIFIER, "Expect parameter name.")
+			param := p.consume(scanner.IDENTIFIER, codes.ExpectParameterName)
 			parameters = append(parameters, param)
 
 			if !p.match(scanner.COMMA) {
@@ -105,12 +164,13 @@ func (p *Parser) function(kind string) *ast.Function {
 			}
 		}
 	}
-	p.consume(scanner.RIGHT_PAREN, "Expect ')' after parameters.")
+	p.consume(scanner.RIGHT_PAREN, codes.ExpectParenAfterParameters)
 
-	p.consume(scanner.LEFT_BRACE, "Expect '{' before " + kind + " body.")
+	p.consume(scanner.LEFT_BRACE, codes.ExpectBraceBeforeBody, kind)
 	body := p.block()
 
 	return &ast.Function{
+		Node: ast.Node{Span: p.spanFrom(start)},
 		Name: name,
 		Params: parameters,
 		Body: body,
@@ -118,9 +178,10 @@ func (p *Parser) function(kind string) *ast.Function {
 }
 
 func (p *Parser) ifStatement() ast.Stmt {
-	p.consume(scanner.LEFT_PAREN, "Expect '(' after 'if'.")
+	start := p.previous()
+	p.consume(scanner.LEFT_PAREN, codes.ExpectParenAfterIf)
 	condition := p.expression()
-	p.consume(scanner.RIGHT_PAREN, "Expect ')' after if condition.")
+	p.consume(scanner.RIGHT_PAREN, codes.ExpectParenAfterIfCondition)
 
 	thenBranch := p.statement()
 
@@ -130,6 +191,7 @@ func (p *Parser) ifStatement() ast.Stmt {
 	}
 
 	return &ast.If{
+		Node:      ast.Node{Span: p.spanFrom(start)},
 		Condition: condition,
 		ThenBranch: thenBranch,
 		ElseBranch: elseBranch,
@@ -148,19 +210,21 @@ func (p *Parser) assignment() ast.Expr {
         case *ast.Variable:
             name := e.Name
             return &ast.Assign{
+                Node:  ast.Node{Span: ast.Join(expr.Extent(), value.Extent())},
                 Name:  name,
                 Value: value,
             }
 
         case *ast.Get:
             return &ast.Set{
+                Node:   ast.Node{Span: ast.Join(expr.Extent(), value.Extent())},
                 Object: e.Object,
                 Name:   e.Name,
                 Value:  value,
             }
 
         default:
-            p.error(equals, "Invalid assignment target.")
+            p.error(equals, codes.InvalidAssignmentTarget)
         }
 	}
 
@@ -174,6 +238,7 @@ func (p *Parser) or() ast.Expr {
 		operator := p.previous()
 		right := p.and()
 		expr = &ast.Logical{
+			Node: ast.Node{Span: ast.Join(expr.Extent(), right.Extent())},
 			Left: expr,
 			Operator: operator,
 			Right: right,
@@ -190,6 +255,7 @@ func (p *Parser) and() ast.Expr {
 		operator := p.previous()
 		right := p.equality()
 		expr = &ast.Logical{
+			Node: ast.Node{Span: ast.Join(expr.Extent(), right.Extent())},
 			Left: expr,
 			Operator: operator,
 			Right: right,
@@ -200,19 +266,22 @@ func (p *Parser) and() ast.Expr {
 }
 
 func (p *Parser) whileStatement() ast.Stmt {
-	p.consume(scanner.LEFT_PAREN, "Expect '(' after 'while'.")
+	start := p.previous()
+	p.consume(scanner.LEFT_PAREN, codes.ExpectParenAfterWhile)
 	condition := p.expression()
-	p.consume(scanner.RIGHT_PAREN, "Expect ')' after condition.")
+	p.consume(scanner.RIGHT_PAREN, codes.ExpectParenAfterCondition)
 	body := p.statement()
 
 	return &ast.While{
+		Node:      ast.Node{Span: p.spanFrom(start)},
 		Condition: condition,
 		Body: body,
 	}
 }
 
 func (p *Parser) forStatement() ast.Stmt {
-	p.consume(scanner.LEFT_PAREN, "Expect '(' after 'for'.")
+	start := p.previous()
+	p.consume(scanner.LEFT_PAREN, codes.ExpectParenAfterFor)
 
 	var initializer ast.Stmt 
 	if p.match(scanner.SEMICOLON) {
@@ -227,21 +296,31 @@ func (p *Parser) forStatement() ast.Stmt {
 	if !p.check(scanner.SEMICOLON) {
 		condition = p.expression()
 	}
-	p.consume(scanner.SEMICOLON, "Expect ';' after loop condition.")
+	p.consume(scanner.SEMICOLON, codes.ExpectSemicolonAfterLoop)
 
 	var increment ast.Expr 
 	if !p.check(scanner.RIGHT_PAREN) {
 		increment = p.expression()
 	}
-	p.consume(scanner.RIGHT_PAREN, "Expect ')' after for clauses.")
+	p.consume(scanner.RIGHT_PAREN, codes.ExpectParenAfterForClauses)
 
 	body := p.statement()
+	loop := &ForLoop{
+		Initializer: initializer,
+		Condition: condition,
+		Increment: increment,
+		Body: body,
+	}
 
+	// The statements the loop desugars into all cover the whole loop,
+	// except for the increment, which covers its own expression.
+	span := p.spanFrom(start)
 	if increment != nil {
 		body = &ast.Block{
+			Node: ast.Node{Span: span},
 			Statements: []ast.Stmt{
 				body,
-				&ast.Expression{Expression: increment},
+				&ast.Expression{Node: ast.Node{Span: increment.Extent()}, Expression: increment},
 			},
 		}
 	}
@@ -250,12 +329,14 @@ func (p *Parser) forStatement() ast.Stmt {
 		condition = &ast.Literal{Value: true}
 	}
 	body = &ast.While{
+		Node:      ast.Node{Span: span},
 		Condition: condition,
 		Body: body,
 	}
 
 	if initializer != nil {
 		body = &ast.Block{
+			Node: ast.Node{Span: span},
 			Statements: []ast.Stmt{
 				initializer,
 				body,
@@ -263,6 +344,7 @@ func (p *Parser) forStatement() ast.Stmt {
 		}
 	}
 
+	p.forLoops[body] = loop
 	return body
 }
 
@@ -274,28 +356,26 @@ func (p *Parser) returnStatement() ast.Stmt {
 		value = p.expression()
 	}
 
-	p.consume(scanner.SEMICOLON, "Expect ';' after return value.")
+	p.consume(scanner.SEMICOLON, codes.ExpectSemicolonAfterReturn)
 	
 	return &ast.Return{
+		Node:    ast.Node{Span: p.spanFrom(keyword)},
 		Keyword: keyword,
 		Value: value,
 	}
 }
 
 func (p *Parser) declaration() (stmt ast.Stmt) {
+	start, first := p.peek().Line, p.current
 	defer func() {
-		if r := recover(); r != nil {
-			// If it's a parseError, synchronize and return nil statement.
-			if _, ok := r.(parseError); ok {
-				p.synchronize()
-				stmt = nil
-				return
-			}
-			// Not a parseError → re-panic (real bug)
-			panic(r)
+		if recovered(recover()) {
+			// Leave the tokens skipped over in place of the statement.
+			p.synchronize(first)
+			stmt = p.badNode(first)
 		}
+		p.recordLines(stmt, start)
 	}()
-	
+
 	if p.match(scanner.CLASS) {
 		return p.classDeclaration()
 	}
@@ -310,47 +390,69 @@ func (p *Parser) declaration() (stmt ast.Stmt) {
 }
 
 func (p *Parser) varDeclaration() ast.Stmt {
-	name := p.consume(scanner.IDENTIFIER, "Expect variable name.")
+	start := p.previous()
+	name := p.consume(scanner.IDENTIFIER, codes.ExpectVariableName)
 
 	var initializer ast.Expr = nil
 	if p.match(scanner.EQUAL) {
 		initializer = p.expression()
 	}
 
-	p.consume(scanner.SEMICOLON, "Expect ';' after variable declaration.")
+	p.consume(scanner.SEMICOLON, codes.ExpectSemicolonAfterVar)
 	return &ast.Var{
+		Node		: ast.Node{Span: p.spanFrom(start)},
 		Name		: name,
 		Initializer : initializer,
 	}
 }
 
 func (p *Parser) classDeclaration() ast.Stmt {
-    name := p.consume(scanner.IDENTIFIER, "Expect class name.")
+    start := p.previous()
+    name := p.consume(scanner.IDENTIFIER, codes.ExpectClassName)
 
 	var superclass ast.Expr 
 	if p.match(scanner.LESS) {
-		p.consume(scanner.IDENTIFIER, "Expect superclass name.")
+		p.consume(scanner.IDENTIFIER, codes.ExpectSuperclassName)
 		superclass = &ast.Variable{
+			Node: ast.Node{Span: ast.TokenSpan(p.previous())},
 			Name: p.previous(),
 		}
 	}
 
-    p.consume(scanner.LEFT_BRACE, "Expect '{' before class body.")
+    p.consume(scanner.LEFT_BRACE, codes.ExpectBraceBeforeClassBody)
 
     var methods []*ast.Function
     for !p.check(scanner.RIGHT_BRACE) && !p.isAtEnd() {
-        methods = append(methods, p.function("method"))
+		start := p.peek().Line
+		if method := p.method(); method != nil {
+			p.recordLines(method, start)
+			methods = append(methods, method)
+		}
     }
 
-    p.consume(scanner.RIGHT_BRACE, "Expect '}' after class body.")
+    p.closeBody(codes.ExpectBraceAfterClassBody)
 
     return &ast.Class{
+        Node:    ast.Node{Span: p.spanFrom(start)},
         Name:    name,
 		Superclass: superclass,
         Methods: methods,
     }
 }
 
+// method parses one method of a class body. A method that does not parse
+// is skipped and nil returned, so that the rest of the class is kept.
+func (p *Parser) method() (method *ast.Function) {
+	first := p.current
+	defer func() {
+		if recovered(recover()) {
+			p.synchronize(first)
+			method = nil
+		}
+	}()
+	return p.function("method")
+}
+
 func (p *Parser) block() []ast.Stmt {
 	var statements []ast.Stmt 
 
@@ -361,10 +463,21 @@ func (p *Parser) block() []ast.Stmt {
 		}
 	}
 
-	p.consume(scanner.RIGHT_BRACE, "Expect '}' after block.")
+	p.closeBody(codes.ExpectBraceAfterBlock)
 	return statements
 }
 
+// closeBody consumes the '}' that ends a block or class body. When the
+// file ends first, the error is reported but the body is kept, since all
+// of the rest of the file was inside it.
+func (p *Parser) closeBody(code codes.Code) {
+	if p.isAtEnd() {
+		p.error(p.peek(), code)
+		return
+	}
+	p.consume(scanner.RIGHT_BRACE, code)
+}
+
 func (p *Parser) equality() ast.Expr {
 	expr := p.comparison()
 
@@ -372,6 +485,7 @@ func (p *Parser) equality() ast.Expr {
 		operator := p.previous()
 		right := p.comparison()
 		expr = &ast.Binary{
+			Node:     ast.Node{Span: ast.Join(expr.Extent(), right.Extent())},
 			Left:     expr,
 			Operator: operator,
 			Right:    right,
@@ -424,6 +538,7 @@ func (p *Parser) comparison() ast.Expr {
 		operator := p.previous()
 		right := p.term()
 		expr = &ast.Binary{
+			Node: ast.Node{Span: ast.Join(expr.Extent(), right.Extent())},
 			Left: expr,
 			Operator: operator,
 			Right: right,
@@ -440,6 +555,7 @@ func (p *Parser) term() ast.Expr {
 		operator := p.previous()
 		right := p.factor()
 		expr = &ast.Binary{
+			Node: ast.Node{Span: ast.Join(expr.Extent(), right.Extent())},
 			Left: expr,
 			Operator: operator,
 			Right: right,
@@ -456,6 +572,7 @@ func (p *Parser) factor() ast.Expr {
 		operator := p.previous()
 		right := p.unary()
 		expr = &ast.Binary{
+			Node: ast.Node{Span: ast.Join(expr.Extent(), right.Extent())},
 			Left: expr,
 			Operator: operator,
 			Right: right,
@@ -470,6 +587,7 @@ func (p *Parser) unary() ast.Expr {
 		operator := p.previous()
 		right := p.unary()
 		return &ast.Unary{
+			Node:     ast.Node{Span: p.spanFrom(operator)},
 			Operator: operator,
 			Right: right,
 		}
@@ -485,8 +603,9 @@ func (p *Parser) call() ast.Expr {
 		if p.match(scanner.LEFT_PAREN) {
 			expr = p.finishCall(expr)
 		} else if p.match(scanner.DOT) {
-			name := p.consume(scanner.IDENTIFIER, "Expect property name after '.'.")
+			name := p.consume(scanner.IDENTIFIER, codes.ExpectPropertyName)
 			expr = &ast.Get{
+				Node:   ast.Node{Span: ast.Join(expr.Extent(), ast.TokenSpan(name))},
 				Object: expr,
 				Name: name,
 			}
@@ -503,10 +622,10 @@ func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
 	if !p.check(scanner.RIGHT_PAREN) {
 		for {
 			if len(arguments) >= 255 {
-				p.error(p.peek(), "Can't have more than 255 arguments.")
+				p.error(p.peek(), codes.TooManyArguments)
 			}
 
-			arguments = append(arguments, p.expression())
+			arguments = append(arguments, p.argument())
 
 			if !p.match(scanner.COMMA) {
 				break
@@ -514,35 +633,77 @@ func (p *Parser) finishCall(callee ast.Expr) ast.Expr {
 		}
 	}
 
-	paren := p.consume(scanner.RIGHT_PAREN, "Expect ')' after arguments.")
+	paren := p.consume(scanner.RIGHT_PAREN, codes.ExpectParenAfterArguments)
 
 	return &ast.Call{
+		Node:   ast.Node{Span: ast.Join(callee.Extent(), ast.TokenSpan(paren))},
 		Callee: callee,
 		Paren: paren,
 		Arguments: arguments,
 	}
 }
 
+// argument parses one argument of a call. After a syntax error it skips to
+// the ',' or ')' that ends the argument and returns an Error in its place,
+// so the rest of the call is still parsed. If the call ends before either,
+// the error is left for the statement to recover from.
+func (p *Parser) argument() (arg ast.Expr) {
+	first := p.current
+	defer func() {
+		if r := recover(); recovered(r) {
+			if !p.skipArgument() {
+				panic(r)
+			}
+			arg = p.errorNode(first)
+		}
+	}()
+	return p.expression()
+}
+
+func (p *Parser) skipArgument() bool {
+	depth := 0
+	for !p.isAtEnd() {
+		switch p.peek().Type {
+		case scanner.LEFT_PAREN:
+			depth++
+		case scanner.RIGHT_PAREN:
+			if depth == 0 {
+				return true
+			}
+			depth--
+		case scanner.COMMA:
+			if depth == 0 {
+				return true
+			}
+		case scanner.SEMICOLON, scanner.LEFT_BRACE, scanner.RIGHT_BRACE:
+			return false
+		}
+		p.advance()
+	}
+	return false
+}
+
 func (p *Parser) primary() ast.Expr {
 	if p.match(scanner.FALSE) {
-		return &ast.Literal{Value: false}
+		return &ast.Literal{Node: p.tokenNode(), Value: false}
 	}
 	if p.match(scanner.TRUE) {
-		return &ast.Literal{Value: true}
+		return &ast.Literal{Node: p.tokenNode(), Value: true}
 	}
 	if p.match(scanner.NIL) {
-		return &ast.Literal{Value: nil}
+		return &ast.Literal{Node: p.tokenNode(), Value: nil}
 	}
 
 	if p.match(scanner.NUMBER, scanner.STRING) {
-		return &ast.Literal{Value: p.previous().Literal}
+		return &ast.Literal{Node: p.tokenNode(), Value: p.previous().Literal}
 	}
 
 	if p.match(scanner.SUPER) {
 		keyword := p.previous()
-		p.consume(scanner.DOT, "Expect '.' after 'super'.")
-		method := p.consume(scanner.IDENTIFIER, "Expect superclass method name.")
+		p.consume(scanner.DOT, codes.ExpectDotAfterSuper)
+		method := p.consume(scanner.IDENTIFIER, codes.ExpectSuperclassMethod)
 		return &ast.Super{
+			Node:    ast.Node{Span: p.spanFrom(keyword)},
 			Keyword: keyword,
 			Method: method,
 		}
@@ -550,61 +711,95 @@ func (p *Parser) primary() ast.Expr {
 
 	if p.match(scanner.THIS) {
 		return &ast.This{
+			Node:    p.tokenNode(),
 			Keyword: p.previous(),
 		}
 	}
 
 	if p.match(scanner.IDENTIFIER) {
-		return &ast.Variable{Name: p.previous()}
+		return &ast.Variable{Node: p.tokenNode(), Name: p.previous()}
 	}
 
 	if p.match(scanner.LEFT_PAREN) {
+		start := p.previous()
 		expr := p.expression()
-		p.consume(scanner.RIGHT_PAREN, "Expect ')' after expression.")
-		return &ast.Grouping{Expression: expr}
+		p.consume(scanner.RIGHT_PAREN, codes.ExpectParenAfterExpression)
+		return &ast.Grouping{Node: ast.Node{Span: p.spanFrom(start)}, Expression: expr}
 	}
 
-	panic(p.error(p.peek(), "Expect expression."))
+	panic(p.error(p.peek(), codes.ExpectExpression))
 }
 
-func (p *Parser) consume(t scanner.TokenType, message string) scanner.Token {
+func (p *Parser) consume(t scanner.TokenType, code codes.Code, args ...any) scanner.Token {
 	if p.check(t) {
 		return p.advance()
 	}
-	panic(p.error(p.peek(), message))
+	panic(p.error(p.peek(), code, args...))
 }
 
-func (p *Parser) error(token scanner.Token, message string) error {
+func (p *Parser) error(token scanner.Token, code codes.Code, args ...any) error {
+	// A string that spans lines has no single line to underline.
+	column := token.Column
+	if strings.Contains(token.Lexeme, "\n") {
+		column = 0
+	}
 	if token.Type == scanner.EOF {
-		shared.Report(token.Line, " at end", message)
+		if p.reportedAtEnd {
+			return parseError{}
+		}
+		p.reportedAtEnd = true
+		shared.ReportAt(token.Line, column, token.Length, " at end", code, args...)
 	} else {
-		shared.Report(token.Line, " at '" + token.Lexeme + "'", message)
+		shared.ReportAt(token.Line, column, token.Length, " at '" + token.Lexeme + "'", code, args...)
 	}
 	
 	return parseError{}
 }
 
-func (p *Parser) synchronize() {
-	p.advance()
-
+// synchronize skips the rest of a statement after a syntax error: up to
+// and including a ';', or up to the next statement keyword or the '}' of
+// an enclosing block. A {...} is skipped whole. At least one token is
+// skipped if none have been consumed since first, so parsing always moves
+// on.
+func (p *Parser) synchronize(first int) {
+	depth := 0
 	for !p.isAtEnd() {
-		if p.previous().Type == scanner.SEMICOLON {
-			return
+		if depth == 0 && p.current > first {
+			switch p.previous().Type {
+			case scanner.SEMICOLON, scanner.RIGHT_BRACE:
+				return
+			}
+			switch p.peek().Type {
+			case scanner.RIGHT_BRACE,
+				scanner.CLASS,
+				scanner.FUN,
+				scanner.VAR,
+				scanner.FOR,
+				scanner.IF,
+				scanner.WHILE,
+				scanner.PRINT,
+				scanner.RETURN:
+				return
+			}
 		}
 
-		switch p.peek().Type {
-		case scanner.CLASS,
-			scanner.FUN,
-			scanner.VAR,
-			scanner.FOR,
-			scanner.IF,
-			scanner.WHILE,
-			scanner.PRINT,
-			scanner.RETURN:
-			return
+		switch p.advance().Type {
+		case scanner.LEFT_BRACE:
+			depth++
+		case scanner.RIGHT_BRACE:
+			depth = max(depth-1, 0)
 		}
-
-		p.advance()
 	}
 }
 
+// recovered reports whether r, a value from recover, is a syntax error.
+// Any other panic is a bug and is raised again.
+func recovered(r any) bool {
+	if r == nil {
+		return false
+	}
+	if _, ok := r.(parseError); !ok {
+		panic(r)
+	}
+	return true
+}
@@ -1,6 +1,8 @@
 package parser
 
 import (
+    "fmt"
+    "strings"
     "testing"
 
     "example.com/golox/lox/ast"
@@ -364,3 +366,182 @@ func TestBadVarDeclarationThenRecovery(t *testing.T) {
     }
 }
 
+func TestRecoveryKeepsAPartialTree(t *testing.T) {
+    tests := []struct {
+        src    string
+        tree   string
+        errors []string
+    }{
+        {
+            src:    "fun f() {\n  print 1\n  print 2;\n}\nprint 3;",
+            tree:   "(fun f () (error) (print 2))\n(print 3)\n",
+            errors: []string{"[Line 3] Error[L0107] at 'print': Expect ';' after value."},
+        },
+        {
+            src:    "print f(1, +, 3);\nprint g(;\nprint 4;",
+            tree:   "(print (call f 1 (error) 3))\n(error)\n(print 4)\n",
+            errors: []string{"[Line 1] Error[L0101] at '+': Expect expression.", "[Line 2] Error[L0101] at ';': Expect expression."},
+        },
+        {
+            src:    "class A {\n  ok() { return 1; }\n  bad(a b) { return 2; }\n  fine() {}\n}",
+            tree:   "(class A (fun ok () (return 1)) (fun fine ()))\n",
+            errors: []string{"[Line 3] Error[L0115] at 'b': Expect ')' after parameters."},
+        },
+        {
+            // Only the innermost unclosed block reports the missing brace.
+            src:    "class A {\n  m() {\n    if (true) {\n      print 1;\n",
+            tree:   "(class A (fun m () (if true (block (print 1)))))\n",
+            errors: []string{"[Line 5] Error[L0117] at end: Expect '}' after block."},
+        },
+        {
+            // A bad statement is skipped along with any block it opens.
+            src:    "if (x { print 1; }\n} print 2;",
+            tree:   "(error)\n(error)\n(print 2)\n",
+            errors: []string{"[Line 1] Error[L0119] at '{': Expect ')' after if condition.", "[Line 2] Error[L0101] at '}': Expect expression."},
+        },
+    }
+    for _, tt := range tests {
+        stmts, diagnostics := NewParser(scanner.NewScanner(tt.src).ScanTokens()).ParsePartial()
+        if got := (&ast.AstPrinter{}).PrintProgram(stmts); got != tt.tree {
+            t.Errorf("%q: got tree\n%s\nwant\n%s", tt.src, got, tt.tree)
+        }
+        var errors []string
+        for _, diag := range diagnostics {
+            errors = append(errors, diag.String())
+        }
+        if strings.Join(errors, "\n") != strings.Join(tt.errors, "\n") {
+            t.Errorf("%q: got errors\n%s\nwant\n%s", tt.src, strings.Join(errors, "\n"), strings.Join(tt.errors, "\n"))
+        }
+    }
+}
+
+func TestErrorNodesHoldTheSkippedTokens(t *testing.T) {
+    src := "var a = 1 2;\nprint f(1, );"
+    stmts, _ := NewParser(scanner.NewScanner(src).ScanTokens()).ParsePartial()
+    if len(stmts) != 2 {
+        t.Fatalf("expected 2 statements, got %d", len(stmts))
+    }
+
+    bad := stmts[0].(*ast.Bad)
+    var lexemes []string
+    for _, token := range bad.Tokens {
+        lexemes = append(lexemes, token.Lexeme)
+    }
+    if got := strings.Join(lexemes, " "); got != "var a = 1 2 ;" {
+        t.Errorf("expected the whole declaration to be skipped, got %q", got)
+    }
+    if span := bad.Extent(); span.Start.Offset != 0 || span.End.Offset != len("var a = 1 2;") {
+        t.Errorf("unexpected span %+v", span)
+    }
+
+    // A missing argument is an empty Error where it should have been.
+    call := stmts[1].(*ast.Print).Expression.(*ast.Call)
+    arg, ok := call.Arguments[1].(*ast.Error)
+    if !ok || len(arg.Tokens) != 0 || arg.Extent().Len() != 0 || arg.Extent().Start.Column != 12 {
+        t.Errorf("expected an empty Error before ')', got %#v", call.Arguments[1])
+    }
+}
+
+func TestParserRecordsLinesAndForClauses(t *testing.T) {
+    src := "var a = 1;\nfor (var i = 0;\n     i < 3;\n     i = i + 1) {\n  print i;\n}\n"
+    shared.ResetErrors()
+    p := NewParser(scanner.NewScanner(src).ScanTokens())
+    stmts := p.Parse()
+    if shared.HadError || len(stmts) != 2 {
+        t.Fatalf("expected 2 statements without errors, got %d", len(stmts))
+    }
+
+    if got := p.Lines()[stmts[0]]; got != (LineRange{Start: 1, End: 1}) {
+        t.Errorf("var lines: got %+v", got)
+    }
+    if got := p.Lines()[stmts[1]]; got != (LineRange{Start: 2, End: 6}) {
+        t.Errorf("for lines: got %+v", got)
+    }
+
+    loop, ok := p.ForLoops()[stmts[1]]
+    if !ok {
+        t.Fatalf("expected the desugared for loop to be recorded")
+    }
+    if _, ok := loop.Initializer.(*ast.Var); !ok {
+        t.Errorf("expected *ast.Var initializer, got %T", loop.Initializer)
+    }
+    if loop.Condition == nil || loop.Increment == nil {
+        t.Errorf("expected condition and increment to be kept")
+    }
+    if _, ok := loop.Body.(*ast.Block); !ok {
+        t.Errorf("expected the original block body, got %T", loop.Body)
+    }
+}
+
+func TestNodesCoverTheirSource(t *testing.T) {
+    src := "print (1 + 2) * -x;\nclass A < B {\n  m(a) { return a.b(c); }\n}\nfor (;;) x = !nil;\n"
+    stmts := scanAndParse(t, src)
+
+    checked := 0
+    for _, stmt := range stmts {
+        ast.Inspect(stmt, func(node any) bool {
+            var span ast.Span
+            switch n := node.(type) {
+            case ast.Expr:
+                span = n.Extent()
+            case ast.Stmt:
+                span = n.Extent()
+            }
+            if lit, ok := node.(*ast.Literal); ok && lit.Value == true {
+                // The condition the parser supplies for "for (;;)".
+                if span.IsValid() {
+                    t.Errorf("synthesized literal should have no span, got %+v", span)
+                }
+                return true
+            }
+            if !span.IsValid() {
+                t.Errorf("%T has no span", node)
+                return true
+            }
+            checked++
+            text := src[span.Start.Offset:span.End.Offset]
+            offset := span.Start.Column - 1
+            for _, line := range strings.SplitAfter(src, "\n")[:span.Start.Line-1] {
+                offset += len(line)
+            }
+            if offset != span.Start.Offset {
+                t.Errorf("%T: line %d column %d does not match offset %d", node, span.Start.Line, span.Start.Column, span.Start.Offset)
+            }
+            if want, ok := spanText[fmt.Sprintf("%T", node)]; ok && !contains(want, text) {
+                t.Errorf("%T covers %q, want one of %q", node, text, want)
+            }
+            return true
+        })
+    }
+    if checked < 20 {
+        t.Errorf("expected to check every node, checked %d", checked)
+    }
+}
+
+// spanText lists what nodes of each type in TestNodesCoverTheirSource may
+// cover.
+var spanText = map[string][]string{
+    "*ast.Print":      {"print (1 + 2) * -x;"},
+    "*ast.Binary":     {"(1 + 2) * -x", "1 + 2"},
+    "*ast.Grouping":   {"(1 + 2)"},
+    "*ast.Unary":      {"-x", "!nil"},
+    "*ast.Class":      {"class A < B {\n  m(a) { return a.b(c); }\n}"},
+    "*ast.Function":   {"m(a) { return a.b(c); }"},
+    "*ast.Return":     {"return a.b(c);"},
+    "*ast.Call":       {"a.b(c)"},
+    "*ast.Get":        {"a.b"},
+    "*ast.While":      {"for (;;) x = !nil;"},
+    "*ast.Assign":     {"x = !nil"},
+    "*ast.Expression": {"x = !nil;"},
+    "*ast.Literal":    {"1", "2", "nil"},
+    "*ast.Variable":   {"x", "B", "a", "c"},
+}
+
+func contains(list []string, s string) bool {
+    for _, item := range list {
+        if item == s {
+            return true
+        }
+    }
+    return false
+}
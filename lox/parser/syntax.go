@@ -0,0 +1,75 @@
+package parser
+
+import (
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/scanner"
+)
+
+// The parser keeps a little more than the interpreter needs, for tools
+// such as the formatter that have to print a program back out.
+
+// LineRange is the first and last source line of a statement.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// ForLoop holds the clauses of a for statement as written. The parser
+// desugars for loops into a While, optionally wrapped in a Block with the
+// initializer; ForLoops maps that outermost statement back to its clauses.
+type ForLoop struct {
+	Initializer ast.Stmt
+	Condition   ast.Expr
+	Increment   ast.Expr
+	Body        ast.Stmt
+}
+
+// Lines returns the line range of every statement, function and method
+// parsed so far. Statements the parser synthesizes are not included.
+func (p *Parser) Lines() map[ast.Stmt]LineRange {
+	return p.lines
+}
+
+// ForLoops returns the original clauses of every desugared for loop.
+func (p *Parser) ForLoops() map[ast.Stmt]*ForLoop {
+	return p.forLoops
+}
+
+func (p *Parser) recordLines(stmt ast.Stmt, start int) {
+	if stmt == nil || p.current == 0 {
+		return
+	}
+	p.lines[stmt] = LineRange{Start: start, End: p.previous().Line}
+}
+
+// spanFrom is the span from the start of token to the end of the last
+// token consumed.
+func (p *Parser) spanFrom(token scanner.Token) ast.Span {
+	return ast.Join(ast.TokenSpan(token), ast.TokenSpan(p.previous()))
+}
+
+// errorNode is an Error holding the tokens from index first to the last
+// one consumed. If there are none, it is an empty span where the next
+// token starts.
+func (p *Parser) errorNode(first int) *ast.Error {
+	tokens := append([]scanner.Token(nil), p.tokens[first:p.current]...)
+	if len(tokens) == 0 {
+		start := ast.TokenSpan(p.peek()).Start
+		return &ast.Error{Node: ast.Node{Span: ast.Span{Start: start, End: start}}}
+	}
+	span := ast.Join(ast.TokenSpan(tokens[0]), ast.TokenSpan(tokens[len(tokens)-1]))
+	return &ast.Error{Node: ast.Node{Span: span}, Tokens: tokens}
+}
+
+// badNode is a Bad statement holding the tokens from index first to the
+// last one consumed.
+func (p *Parser) badNode(first int) *ast.Bad {
+	bad := p.errorNode(first)
+	return &ast.Bad{Node: bad.Node, Tokens: bad.Tokens}
+}
+
+// tokenNode is the Node of an expression made of just the last token
+// consumed.
+func (p *Parser) tokenNode() ast.Node {
+	return ast.Node{Span: ast.TokenSpan(p.previous())}
+}
@@ -0,0 +1,124 @@
+package refactor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// context is the number of unchanged lines shown around each change.
+const context = 3
+
+// Diff is a unified diff that turns before into after, or "" if they are
+// the same. Both sides are named after path, in the a/ and b/ style that
+// git and "patch -p1" expect.
+func Diff(path, before, after string) string {
+	if before == after {
+		return ""
+	}
+	a, b := lines(before), lines(after)
+
+	// lcs[i][j] is the length of the longest common subsequence of a[i:]
+	// and b[j:].
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	// ops is the diff one line at a time: ' ', '-' or '+' and the line.
+	type op struct {
+		kind byte
+		text string
+	}
+	var ops []op
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, op{' ', a[i]})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, op{'-', a[i]})
+			i++
+		default:
+			ops = append(ops, op{'+', b[j]})
+			j++
+		}
+	}
+
+	var out strings.Builder
+	path = strings.TrimPrefix(path, "/")
+	fmt.Fprintf(&out, "--- a/%s\n+++ b/%s\n", path, path)
+	line := [2]int{1, 1} // next line of before and of after
+	for start := 0; start < len(ops); {
+		if ops[start].kind == ' ' {
+			line[0]++
+			line[1]++
+			start++
+			continue
+		}
+
+		// A hunk runs from context lines before the first change to
+		// context lines after the last change that is no further than
+		// 2*context lines from the one before it.
+		from := max(start-context, 0)
+		end := start
+		for k := start; k < len(ops) && k-end <= 2*context; k++ {
+			if ops[k].kind != ' ' {
+				end = k
+			}
+		}
+		to := min(end+context+1, len(ops))
+
+		first := [2]int{line[0] - (start - from), line[1] - (start - from)}
+		var body strings.Builder
+		count := [2]int{}
+		for _, o := range ops[from:to] {
+			body.WriteString(string(o.kind) + o.text + "\n")
+			if o.kind != '+' {
+				count[0]++
+			}
+			if o.kind != '-' {
+				count[1]++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n%s", hunkRange(first[0], count[0]), hunkRange(first[1], count[1]), body.String())
+
+		for _, o := range ops[start:to] {
+			if o.kind != '+' {
+				line[0]++
+			}
+			if o.kind != '-' {
+				line[1]++
+			}
+		}
+		start = to
+	}
+	return out.String()
+}
+
+func hunkRange(first, count int) string {
+	if count == 0 {
+		first-- // an empty range names the line before it
+	}
+	if count == 1 {
+		return fmt.Sprint(first)
+	}
+	return fmt.Sprintf("%d,%d", first, count)
+}
+
+func lines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,166 @@
+package refactor
+
+import (
+	"fmt"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+)
+
+// class is a class of the program and the properties it declares: its
+// methods, and the fields its methods assign to on this.
+type class struct {
+	decl       *ast.Class
+	super      *ast.Class
+	properties map[string]bool
+}
+
+// classes finds every class in the program, nested ones included, in
+// source order.
+func (p *program) classes() []*class {
+	var classes []*class
+	for _, stmt := range p.tree.Statements {
+		ast.Inspect(stmt, func(n any) bool {
+			if decl, ok := n.(*ast.Class); ok {
+				classes = append(classes, p.class(decl))
+			}
+			return true
+		})
+	}
+	return classes
+}
+
+func (p *program) class(decl *ast.Class) *class {
+	c := &class{decl: decl, properties: map[string]bool{}}
+	if super, ok := decl.Superclass.(*ast.Variable); ok {
+		symbol := p.resolver.SymbolAt(super.Name.Line, super.Name.Column)
+		if symbol != nil && symbol.Kind == resolver.SymbolClass {
+			c.super = symbol.Class
+		}
+	}
+	for _, method := range decl.Methods {
+		c.properties[method.Name.Lexeme] = true
+		ast.Inspect(method, func(n any) bool {
+			switch n := n.(type) {
+			case *ast.Class:
+				return false // a nested class's this is its own
+			case *ast.Set:
+				if _, ok := n.Object.(*ast.This); ok {
+					c.properties[n.Name.Lexeme] = true
+				}
+			}
+			return true
+		})
+	}
+	return c
+}
+
+// root is the class at the top of c's hierarchy.
+func root(classes []*class, c *class) *class {
+	seen := map[*class]bool{}
+	for !seen[c] {
+		seen[c] = true
+		super := find(classes, c.super)
+		if super == nil {
+			break
+		}
+		c = super
+	}
+	return c
+}
+
+func find(classes []*class, decl *ast.Class) *class {
+	for _, c := range classes {
+		if c.decl == decl {
+			return c
+		}
+	}
+	return nil
+}
+
+// uses are the property names in gets, sets and super calls.
+func (p *program) uses() []scanner.Token {
+	var uses []scanner.Token
+	for _, stmt := range p.tree.Statements {
+		ast.Inspect(stmt, func(n any) bool {
+			switch n := n.(type) {
+			case *ast.Get:
+				uses = append(uses, n.Name)
+			case *ast.Set:
+				uses = append(uses, n.Name)
+			case *ast.Super:
+				uses = append(uses, n.Method)
+			}
+			return true
+		})
+	}
+	return uses
+}
+
+func (p *program) isProperty(token scanner.Token) bool {
+	for _, use := range p.uses() {
+		if use.Offset == token.Offset {
+			return true
+		}
+	}
+	return false
+}
+
+// renameProperty renames a property everywhere it is declared or used.
+// Lox cannot tell which class an object in "a.b" belongs to, so every
+// class that declares the property must be in the same hierarchy.
+func renameProperty(p *program, target scanner.Token, newName string) ([]Edit, error) {
+	name := target.Lexeme
+	if name == "init" || newName == "init" {
+		return nil, fmt.Errorf("initializers can't be renamed")
+	}
+
+	classes := p.classes()
+	var home *class
+	for _, c := range classes {
+		if !c.properties[name] {
+			continue
+		}
+		top := root(classes, c)
+		if home != nil && top != home {
+			return nil, fmt.Errorf("'%s' is declared by classes %s and %s, which are not related",
+				name, home.decl.Name.Lexeme, top.decl.Name.Lexeme)
+		}
+		home = top
+	}
+	if home == nil {
+		return nil, fmt.Errorf("no class declares a property named '%s'", name)
+	}
+
+	var edits []Edit
+	declared := false
+	for _, c := range classes {
+		if root(classes, c) != home {
+			declared = declared || c.properties[newName]
+			continue
+		}
+		if c.properties[newName] {
+			return nil, fmt.Errorf("class %s already has a property named '%s'", c.decl.Name.Lexeme, newName)
+		}
+		for _, method := range c.decl.Methods {
+			if method.Name.Lexeme == name {
+				edits = append(edits, rename(method.Name, newName))
+			}
+		}
+	}
+	for _, use := range p.uses() {
+		switch use.Lexeme {
+		case name:
+			edits = append(edits, rename(use, newName))
+		case newName:
+			// Nothing declares it, so it may be set on one of the
+			// hierarchy's instances from outside.
+			if !declared {
+				return nil, fmt.Errorf("'%s' is already used as a property on line %d", newName, use.Line)
+			}
+		}
+	}
+	sortEdits(edits)
+	return edits, nil
+}
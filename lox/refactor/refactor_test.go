@@ -0,0 +1,231 @@
+package refactor
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// renamed renames the identifier marked by the first "$" in src, which is
+// removed first, and returns the new source or the error.
+func renamed(t *testing.T, src, newName string) (string, error) {
+	t.Helper()
+	at := strings.Index(src, "$")
+	if at < 0 {
+		t.Fatalf("no $ marker in %q", src)
+	}
+	src = src[:at] + src[at+1:]
+	line := strings.Count(src[:at], "\n") + 1
+	column := at - strings.LastIndex(src[:at], "\n")
+
+	edits, err := Rename(src, line, column, newName)
+	if err != nil {
+		return "", err
+	}
+	return Apply(src, edits), nil
+}
+
+func TestRenameFollowsScopes(t *testing.T) {
+	tests := []struct {
+		name, src, newName, want string
+	}{
+		{
+			"global function",
+			"fun $add(a, b) { return a + b; } // add\nprint add(1, 2);\nfun g() { return add; }\n",
+			"sum",
+			"fun sum(a, b) { return a + b; } // add\nprint sum(1, 2);\nfun g() { return sum; }\n",
+		},
+		{
+			"shadowed local is left alone",
+			"var x = 1;\n{ var x = 2; print x; }\nprint $x;\n",
+			"y",
+			"var y = 1;\n{ var x = 2; print x; }\nprint y;\n",
+		},
+		{
+			"from a reference",
+			"{ var count = 0;\n  count = count + 1;\n  print $count; }\n",
+			"total",
+			"{ var total = 0;\n  total = total + 1;\n  print total; }\n",
+		},
+		{
+			"parameter",
+			"fun f($n) { return n * n; }\nvar n = 3;\nprint f(n);\n",
+			"side",
+			"fun f(side) { return side * side; }\nvar n = 3;\nprint f(n);\n",
+		},
+		{
+			"class and superclass",
+			"class $A {}\nclass B < A {}\nprint A();\n",
+			"Base",
+			"class Base {}\nclass B < Base {}\nprint Base();\n",
+		},
+	}
+	for _, tc := range tests {
+		got, err := renamed(t, tc.src, tc.newName)
+		if err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		} else if got != tc.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestRenameRefusesConflicts(t *testing.T) {
+	tests := []struct {
+		name, src, newName, want string
+	}{
+		{
+			"capture by an inner declaration",
+			"var $a = 1;\nfun f() { var b = 2; return a + b; }\n",
+			"b",
+			"'b' on line 2 would refer to the declaration on line 2 instead",
+		},
+		{
+			"shadowing an outer declaration",
+			"var a = 1;\nfun f() { var $b = 2; return a + b; }\n",
+			"a",
+			"'a' on line 2 would be shadowed by the renamed variable",
+		},
+		{
+			"shadowing a native",
+			"fun f() { var $t = 0; return clock() - t; }\n",
+			"clock",
+			"'clock' on line 1 would be shadowed by the renamed variable",
+		},
+		{
+			"redeclaring a local",
+			"{ var $a = 1; var b = 2; print a + b; }\n",
+			"b",
+			"renaming to 'b' would cause an error",
+		},
+		{
+			"redeclaring a global",
+			"var $a = 1;\nvar b = 2;\n",
+			"b",
+			"a global named 'b' is already declared on line 2",
+		},
+		{"keyword", "var $a = 1;\n", "class", "'class' is not a valid identifier"},
+		{"not an identifier", "var $a = 1;\n", "a b", "'a b' is not a valid identifier"},
+		{"native", "print $clock();\n", "now", "'clock' is not declared in this file"},
+	}
+	for _, tc := range tests {
+		_, err := renamed(t, tc.src, tc.newName)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+const shapes = `class Shape {
+  init(size) { this.size = size; }
+  area() { return 0; }
+}
+class Square < Shape {
+  area() { return this.size * this.size; }
+  twice() { return 2 * super.area(); }
+}
+class Clock {
+  tick() { return 1; }
+}
+print Square(2).area();
+`
+
+func TestRenamePropertiesAcrossAHierarchy(t *testing.T) {
+	got, err := renamed(t, strings.Replace(shapes, "area() { return 0", "$area() { return 0", 1), "surface")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(got, "area") || strings.Count(got, "surface") != 4 {
+		t.Errorf("expected every area to become surface, got\n%s", got)
+	}
+
+	got, err = renamed(t, strings.Replace(shapes, "this.size = size", "this.$size = size", 1), "side")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "this.side = size") || !strings.Contains(got, "this.side * this.side") {
+		t.Errorf("expected the field to be renamed, got\n%s", got)
+	}
+}
+
+func TestRenamePropertiesRefusesAmbiguity(t *testing.T) {
+	tests := []struct {
+		name, src, newName, want string
+	}{
+		{
+			"unrelated classes",
+			strings.Replace(shapes, "tick()", "area()", 1) + "print Clock().$area();\n",
+			"size2",
+			"'area' is declared by classes Shape and Clock, which are not related",
+		},
+		{
+			"existing property",
+			strings.Replace(shapes, "twice()", "$twice()", 1),
+			"size",
+			"class Shape already has a property named 'size'",
+		},
+		{"initializer", strings.Replace(shapes, "init(", "$init(", 1), "make", "initializers can't be renamed"},
+		{
+			"undeclared property",
+			"var o = nil;\nprint o.$x;\n",
+			"y",
+			"no class declares a property named 'x'",
+		},
+	}
+	for _, tc := range tests {
+		_, err := renamed(t, tc.src, tc.newName)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tc.name, tc.want, err)
+		}
+	}
+}
+
+func TestDiffIsUnified(t *testing.T) {
+	before := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm\n"
+	after := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nL\nm\n"
+	want := `--- a/x.lox
++++ b/x.lox
+@@ -1,5 +1,5 @@
+ a
+-b
++B
+ c
+ d
+ e
+@@ -9,5 +9,5 @@
+ i
+ j
+ k
+-l
++L
+ m
+`
+	if got := Diff("x.lox", before, after); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+	if got := Diff("x.lox", before, before); got != "" {
+		t.Errorf("expected no diff for equal sources, got %q", got)
+	}
+
+	// The diff applies with patch, when it is installed.
+	patch, err := exec.LookPath("patch")
+	if err != nil {
+		return
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "x.lox")
+	if err := os.WriteFile(path, []byte(before), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(patch, "-p1", "-s")
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(want)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("patch failed: %v\n%s", err, out)
+	}
+	if data, _ := os.ReadFile(path); string(data) != after {
+		t.Errorf("patched file is %q", data)
+	}
+}
@@ -0,0 +1,192 @@
+// Package refactor rewrites Lox programs by what their names mean rather
+// than by their text. Edits only ever replace tokens, so the comments and
+// layout of the source are kept as written.
+package refactor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"example.com/golox/lox/cst"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+// Edit replaces Length bytes of the source, starting at Offset, with Text.
+type Edit struct {
+	Offset int
+	Length int
+	Text   string
+}
+
+// Apply makes edits to src. The edits must not overlap.
+func Apply(src string, edits []Edit) string {
+	edits = append([]Edit(nil), edits...)
+	sortEdits(edits)
+
+	var b strings.Builder
+	last := 0
+	for _, edit := range edits {
+		b.WriteString(src[last:edit.Offset])
+		b.WriteString(edit.Text)
+		last = edit.Offset + edit.Length
+	}
+	b.WriteString(src[last:])
+	return b.String()
+}
+
+// Rename renames what the identifier at line and column, both from 1,
+// declares or refers to: a variable, parameter, function or class, or a
+// property of a class hierarchy. It returns the edits in source order, or
+// an error saying why the rename is refused.
+//
+// Variables are renamed by scope, so a shadowed local of the same name is
+// left alone. A rename that would change what any name in the program
+// refers to, by capturing a reference or shadowing another declaration,
+// is refused. A property is renamed wherever it is used, which is only
+// allowed when the classes that declare it all share one hierarchy.
+func Rename(src string, line, column int, newName string) ([]Edit, error) {
+	prog, err := load(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkName(newName); err != nil {
+		return nil, err
+	}
+
+	target := prog.tokenAt(line, column)
+	if target == nil {
+		return nil, fmt.Errorf("no identifier at %d:%d", line, column)
+	}
+	if target.Lexeme == newName {
+		return nil, fmt.Errorf("'%s' already has that name", newName)
+	}
+
+	symbol := prog.resolver.SymbolAt(line, column)
+	if symbol != nil && symbol.Kind != resolver.SymbolMethod {
+		return renameSymbol(prog, symbol, newName)
+	}
+	if symbol != nil || prog.isProperty(*target) {
+		return renameProperty(prog, *target, newName)
+	}
+	return nil, fmt.Errorf("'%s' is not declared in this file", target.Lexeme)
+}
+
+// program is a parsed and resolved source file.
+type program struct {
+	src      string
+	tree     *cst.Tree
+	tokens   []*cst.Token
+	resolver *resolver.Resolver
+}
+
+func load(src string) (*program, error) {
+	tree := cst.Parse(src)
+	if len(tree.Diagnostics) > 0 {
+		return nil, shared.Errors(tree.Diagnostics)
+	}
+	p := &program{src: src, tree: tree, tokens: tree.Root.Tokens()}
+	p.resolver = resolver.NewResolver(interpreter.NewInterpreter())
+	if diagnostics := shared.Collect(func() { p.resolver.Resolve(tree.Statements) }); len(diagnostics) > 0 {
+		return nil, shared.Errors(diagnostics)
+	}
+	return p, nil
+}
+
+// checkName makes sure name scans as a single identifier.
+func checkName(name string) error {
+	var tokens []scanner.Token
+	diagnostics := shared.Collect(func() {
+		tokens = scanner.NewScanner(name).ScanTokens()
+	})
+	if len(diagnostics) > 0 || len(tokens) != 2 || tokens[0].Type != scanner.IDENTIFIER {
+		return fmt.Errorf("'%s' is not a valid identifier", name)
+	}
+	return nil
+}
+
+func (p *program) tokenAt(line, column int) *scanner.Token {
+	for _, token := range p.tokens {
+		if token.Type == scanner.IDENTIFIER && token.Line == line &&
+			column >= token.Column && column < token.Column+token.Length {
+			return &token.Token
+		}
+	}
+	return nil
+}
+
+// index is the position of the token at offset among the tokens the
+// parser sees. A rename keeps it the same, unlike the offset.
+func (p *program) index(offset int) int {
+	return sort.Search(len(p.tokens), func(i int) bool {
+		return p.tokens[i].Offset >= offset
+	})
+}
+
+// bindings maps the index of every name token that resolves to a
+// declaration to the index of that declaration's name.
+func (p *program) bindings() map[int]int {
+	bound := map[int]int{}
+	for _, symbol := range p.resolver.Symbols() {
+		decl := p.index(symbol.Name.Offset)
+		bound[decl] = decl
+		for _, ref := range symbol.References {
+			bound[p.index(ref.Token.Offset)] = decl
+		}
+	}
+	return bound
+}
+
+func renameSymbol(p *program, symbol *resolver.Symbol, newName string) ([]Edit, error) {
+	if symbol.Global {
+		for _, other := range p.resolver.Symbols() {
+			if other.Global && other.Name.Lexeme == newName {
+				return nil, fmt.Errorf("a global named '%s' is already declared on line %d", newName, other.Name.Line)
+			}
+		}
+	}
+
+	edits := []Edit{rename(symbol.Name, newName)}
+	for _, ref := range symbol.References {
+		edits = append(edits, rename(ref.Token, newName))
+	}
+	sortEdits(edits)
+
+	// Resolve the renamed program again. Every name in it has to refer to
+	// the same declaration as before.
+	renamed, err := load(Apply(p.src, edits))
+	if err != nil {
+		return nil, fmt.Errorf("renaming to '%s' would cause an error: %v", newName, err)
+	}
+	before, after := p.bindings(), renamed.bindings()
+	target := p.index(symbol.Name.Offset)
+	for i, token := range renamed.tokens {
+		was, wasBound := before[i]
+		is, isBound := after[i]
+		if was == is && wasBound == isBound {
+			continue
+		}
+		switch {
+		case wasBound && was == target && isBound:
+			return nil, fmt.Errorf("'%s' on line %d would refer to the declaration on line %d instead",
+				newName, token.Line, renamed.tokens[is].Line)
+		case isBound:
+			return nil, fmt.Errorf("'%s' on line %d would be shadowed by the renamed %s",
+				newName, token.Line, symbol.Kind)
+		default:
+			return nil, fmt.Errorf("'%s' on line %d would no longer refer to its declaration", newName, token.Line)
+		}
+	}
+	return edits, nil
+}
+
+func rename(token scanner.Token, newName string) Edit {
+	return Edit{Offset: token.Offset, Length: token.Length, Text: newName}
+}
+
+func sortEdits(edits []Edit) {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].Offset < edits[j].Offset })
+}
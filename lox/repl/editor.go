@@ -0,0 +1,423 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// ErrNotTerminal is returned by Terminal when its input is not a terminal
+// that can be put into raw mode.
+var ErrNotTerminal = errors.New("not a terminal")
+
+// Control keys, and the keys decoded from escape sequences, which are
+// given negative codes so they never collide with typed characters.
+const (
+	ctrlA     = 1
+	ctrlB     = 2
+	ctrlC     = 3
+	ctrlD     = 4
+	ctrlE     = 5
+	ctrlF     = 6
+	ctrlG     = 7
+	ctrlH     = 8
+	tab       = 9
+	ctrlK     = 11
+	ctrlL     = 12
+	enter     = 13
+	ctrlN     = 14
+	ctrlP     = 16
+	ctrlR     = 18
+	ctrlU     = 21
+	ctrlW     = 23
+	esc       = 27
+	backspace = 127
+)
+
+const (
+	keyUp rune = -1 - iota
+	keyDown
+	keyLeft
+	keyRight
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+// Editor is a LineReader that edits lines on a terminal in raw mode.
+// It understands the usual emacs-style keys: arrows, Home and End,
+// Ctrl-A/E/B/F to move, Ctrl-K/U/W to delete, Up and Down or Ctrl-P/N to
+// walk the history, Ctrl-R to search it backwards and Tab to complete.
+type Editor struct {
+	in      *bufio.Reader
+	out     io.Writer
+	history *History
+
+	// Complete returns the words that could complete the identifier at
+	// the end of before, the text left of the cursor. Words that do not
+	// start with that identifier are ignored.
+	Complete func(before string) []string
+
+	// raw switches the terminal to raw mode for one ReadLine and returns
+	// a function that restores it. It is nil when reading from a script.
+	raw func() (restore func(), err error)
+}
+
+// NewEditor returns an Editor reading keys from r and drawing on w. The
+// caller is responsible for putting the terminal into raw mode; Terminal
+// does that for a real one.
+func NewEditor(r io.Reader, w io.Writer, history *History) *Editor {
+	return &Editor{in: bufio.NewReader(r), out: w, history: history}
+}
+
+// Terminal returns an Editor on the terminal f, with tab completion from
+// the REPL's session and history kept in historyPath, or in memory only
+// when historyPath is empty. It returns ErrNotTerminal when f is not a
+// terminal.
+func (r *REPL) Terminal(f *os.File, historyPath string) (*Editor, error) {
+	fd := int(f.Fd())
+	if !isTerminal(fd) {
+		return nil, ErrNotTerminal
+	}
+	history, err := LoadHistory(historyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	e := NewEditor(f, r.stdout, history)
+	e.Complete = r.Completions
+	e.raw = func() (func(), error) { return makeRaw(fd) }
+	return e, nil
+}
+
+// lineState is the state of the line being edited.
+type lineState struct {
+	prompt string
+	buf    []rune
+	pos    int
+	// history is the index of the history entry shown, or len(entries)
+	// for the line being typed, which is kept in typed meanwhile.
+	history int
+	typed   []rune
+}
+
+func (e *Editor) ReadLine(prompt string) (string, error) {
+	if e.raw != nil {
+		restore, err := e.raw()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	l := &lineState{prompt: prompt, history: len(e.history.entries)}
+	e.refresh(l)
+	for {
+		key, err := e.readKey()
+		if err != nil {
+			if err == io.EOF && len(l.buf) > 0 {
+				break
+			}
+			return "", err
+		}
+		if key == ctrlR {
+			if key, err = e.search(l); err != nil {
+				return "", err
+			}
+		}
+
+		switch key {
+		case enter, '\n':
+			fmt.Fprint(e.out, "\r\n")
+			e.history.Add(string(l.buf))
+			return string(l.buf), nil
+		case ctrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", ErrInterrupted
+		case ctrlD:
+			if len(l.buf) == 0 {
+				return "", io.EOF
+			}
+			l.delete(l.pos, l.pos+1)
+		case 0, keyUnknown, esc:
+		case ctrlA, keyHome:
+			l.pos = 0
+		case ctrlE, keyEnd:
+			l.pos = len(l.buf)
+		case ctrlB, keyLeft:
+			l.pos = max(l.pos-1, 0)
+		case ctrlF, keyRight:
+			l.pos = min(l.pos+1, len(l.buf))
+		case backspace, ctrlH:
+			if l.pos > 0 {
+				l.delete(l.pos-1, l.pos)
+			}
+		case keyDelete:
+			l.delete(l.pos, l.pos+1)
+		case ctrlK:
+			l.delete(l.pos, len(l.buf))
+		case ctrlU:
+			l.delete(0, l.pos)
+		case ctrlW:
+			start := l.pos
+			for start > 0 && l.buf[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && l.buf[start-1] != ' ' {
+				start--
+			}
+			l.delete(start, l.pos)
+		case ctrlL:
+			fmt.Fprint(e.out, "\x1b[H\x1b[2J")
+		case ctrlP, keyUp:
+			e.recall(l, l.history-1)
+		case ctrlN, keyDown:
+			e.recall(l, l.history+1)
+		case tab:
+			e.complete(l)
+		default:
+			if unicode.IsPrint(key) {
+				l.insert(string(key))
+			}
+		}
+		e.refresh(l)
+	}
+
+	fmt.Fprint(e.out, "\r\n")
+	e.history.Add(string(l.buf))
+	return string(l.buf), nil
+}
+
+func (l *lineState) insert(s string) {
+	runes := []rune(s)
+	l.buf = append(l.buf[:l.pos], append(runes, l.buf[l.pos:]...)...)
+	l.pos += len(runes)
+}
+
+// delete removes the runes from start up to end.
+func (l *lineState) delete(start, end int) {
+	end = min(end, len(l.buf))
+	if start >= end {
+		return
+	}
+	l.buf = append(l.buf[:start], l.buf[end:]...)
+	if l.pos > end {
+		l.pos -= end - start
+	} else if l.pos > start {
+		l.pos = start
+	}
+}
+
+// set replaces the whole line and puts the cursor at its end.
+func (l *lineState) set(s string) {
+	l.buf = []rune(s)
+	l.pos = len(l.buf)
+}
+
+// refresh redraws the prompt and line and places the cursor.
+func (e *Editor) refresh(l *lineState) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", l.prompt, string(l.buf))
+	if back := len(l.buf) - l.pos; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
+
+// recall shows history entry i, where len(entries) is the line that was
+// being typed before walking the history.
+func (e *Editor) recall(l *lineState, i int) {
+	entries := e.history.entries
+	if i < 0 || i > len(entries) {
+		return
+	}
+	if l.history == len(entries) {
+		l.typed = append([]rune(nil), l.buf...)
+	}
+	l.history = i
+	if i == len(entries) {
+		l.set(string(l.typed))
+	} else {
+		l.set(entries[i])
+	}
+}
+
+// search runs a reverse incremental search of the history, as Ctrl-R does
+// in bash. Typing narrows the search, Ctrl-R finds an older match, and
+// Ctrl-G or Ctrl-C gives up. Any other key puts the match on the line and
+// is returned to be handled as usual, so Enter runs the match.
+func (e *Editor) search(l *lineState) (rune, error) {
+	entries := e.history.entries
+	var query []rune
+	match := -1
+	find := func(from int) int {
+		for i := min(from, len(entries)-1); i >= 0; i-- {
+			if strings.Contains(entries[i], string(query)) {
+				return i
+			}
+		}
+		return -1
+	}
+
+	for {
+		label, text := "reverse-i-search", ""
+		if match >= 0 {
+			text = entries[match]
+		} else if len(query) > 0 {
+			label = "failed reverse-i-search"
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", label, string(query), text)
+
+		key, err := e.readKey()
+		if err != nil {
+			return 0, err
+		}
+		switch {
+		case key == ctrlR:
+			if match > 0 {
+				if older := find(match - 1); older >= 0 {
+					match = older
+				}
+			}
+		case key == backspace || key == ctrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				match = find(len(entries) - 1)
+			}
+		case key == ctrlG || key == ctrlC:
+			return 0, nil
+		case key > 0 && unicode.IsPrint(key):
+			query = append(query, key)
+			if match < 0 {
+				match = find(len(entries) - 1)
+			} else {
+				match = find(match)
+			}
+		default:
+			if match >= 0 {
+				l.set(entries[match])
+				l.history = len(entries)
+			}
+			return key, nil
+		}
+	}
+}
+
+// complete extends the identifier before the cursor. A single candidate
+// is inserted; several are narrowed to their common prefix or, when that
+// adds nothing, listed below the line.
+func (e *Editor) complete(l *lineState) {
+	if e.Complete == nil {
+		return
+	}
+	before := string(l.buf[:l.pos])
+	start := len(before)
+	for start > 0 && isIdentifier(rune(before[start-1])) {
+		start--
+	}
+	word := before[start:]
+
+	seen := map[string]bool{}
+	var matches []string
+	for _, candidate := range e.Complete(before) {
+		if strings.HasPrefix(candidate, word) && !seen[candidate] {
+			seen[candidate] = true
+			matches = append(matches, candidate)
+		}
+	}
+	sort.Strings(matches)
+
+	switch {
+	case len(matches) == 0:
+		fmt.Fprint(e.out, "\a")
+	case len(matches) == 1:
+		l.insert(matches[0][len(word):])
+	default:
+		prefix := matches[0]
+		for _, m := range matches[1:] {
+			for !strings.HasPrefix(m, prefix) {
+				prefix = prefix[:len(prefix)-1]
+			}
+		}
+		if len(prefix) > len(word) {
+			l.insert(prefix[len(word):])
+			return
+		}
+		fmt.Fprintf(e.out, "\r\n%s\r\n", strings.Join(matches, "  "))
+	}
+}
+
+func isIdentifier(r rune) bool {
+	return r == '_' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+}
+
+// readKey reads one key, decoding the escape sequences terminals send for
+// arrows, Home, End and Delete.
+func (e *Editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != esc {
+		return r, err
+	}
+	if e.in.Buffered() == 0 {
+		return esc, nil
+	}
+
+	introducer, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if introducer != '[' && introducer != 'O' {
+		return keyUnknown, nil
+	}
+	final, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	switch final {
+	case 'A':
+		return keyUp, nil
+	case 'B':
+		return keyDown, nil
+	case 'C':
+		return keyRight, nil
+	case 'D':
+		return keyLeft, nil
+	case 'H':
+		return keyHome, nil
+	case 'F':
+		return keyEnd, nil
+	}
+	if final < '0' || final > '9' {
+		return keyUnknown, nil
+	}
+
+	// A numbered key such as "\x1b[3~", possibly with modifiers.
+	params := string(final)
+	for {
+		c, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		if c == '~' {
+			break
+		}
+		if c < '0' || c > '9' && c != ';' {
+			return keyUnknown, nil
+		}
+		params += string(c)
+	}
+	number, _, _ := strings.Cut(params, ";")
+	switch number {
+	case "1", "7":
+		return keyHome, nil
+	case "4", "8":
+		return keyEnd, nil
+	case "3":
+		return keyDelete, nil
+	}
+	return keyUnknown, nil
+}
@@ -0,0 +1,86 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+// maxHistory is how many entries are kept, in memory and in the file.
+const maxHistory = 1000
+
+// History is the list of lines entered, oldest first. Entries are appended
+// to a file as they are added, so several sessions can share it.
+type History struct {
+	entries []string
+	path    string
+}
+
+// LoadHistory reads the history kept in path. A missing file is an empty
+// history, and an empty path keeps the history in memory only.
+func LoadHistory(path string) (*History, error) {
+	h := &History{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("history: %w", err)
+	}
+
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[len(h.entries)-maxHistory:]
+		if err := os.WriteFile(path, []byte(strings.Join(h.entries, "\n")+"\n"), 0o600); err != nil {
+			return nil, fmt.Errorf("history: %w", err)
+		}
+	}
+	return h, nil
+}
+
+// Entries returns the history, oldest first.
+func (h *History) Entries() []string {
+	return append([]string(nil), h.entries...)
+}
+
+// Add records a line unless it is blank or repeats the previous entry.
+// The line stays in memory even if it cannot be written to the file.
+func (h *History) Add(line string) error {
+	if strings.TrimSpace(line) == "" || len(h.entries) > 0 && h.entries[len(h.entries)-1] == line {
+		return nil
+	}
+	h.entries = append(h.entries, line)
+	if len(h.entries) > maxHistory {
+		h.entries = h.entries[1:]
+	}
+	if h.path == "" {
+		return nil
+	}
+
+	f, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("history: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,391 @@
+// Package repl is the interactive prompt. It keeps reading while brackets
+// or a string are still open, echoes the value of a bare expression and
+// understands a few meta-commands, such as ":help", that start with a
+// colon.
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/optimizer"
+	"example.com/golox/lox/parser"
+	"example.com/golox/lox/resolver"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/shared"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+)
+
+const help = `Enter Lox statements or expressions. Input continues on the next line
+while a bracket or string is open; the value of a bare expression is
+printed unless it is nil. Ctrl-C cancels the current input or stops a
+running program, and Ctrl-D exits.
+
+Commands:
+  :help        show this message
+  :env         list global variables and their values
+  :ast SOURCE  print the syntax tree of a statement or expression
+  :load FILE   run a file in this session
+  :reset       forget every global defined so far
+  :quit        exit`
+
+// ErrInterrupted is returned by a LineReader when the user presses Ctrl-C
+// at the prompt.
+var ErrInterrupted = errors.New("interrupted")
+
+// LineReader supplies lines of input without their line ending. ReadLine
+// returns io.EOF at the end of input.
+type LineReader interface {
+	ReadLine(prompt string) (string, error)
+}
+
+type REPL struct {
+	in             *interpreter.Interpreter
+	stdout, stderr io.Writer
+
+	// Optimizer, when set, rewrites each input before it runs.
+	Optimizer *optimizer.Pipeline
+
+	// interrupts carries Ctrl-C to whichever of the reader or the running
+	// program notices first.
+	interrupts chan struct{}
+	// lines remembers where every statement entered so far starts, so an
+	// interrupted program can say where it stopped.
+	lines map[ast.Stmt]parser.LineRange
+}
+
+// New returns a REPL that runs code with in. Program output, echoed values
+// and prompts go to stdout; errors go to stderr.
+func New(in *interpreter.Interpreter, stdout, stderr io.Writer) *REPL {
+	in.SetOutput(stdout, stderr)
+	return &REPL{
+		in:         in,
+		stdout:     stdout,
+		stderr:     stderr,
+		interrupts: make(chan struct{}, 1),
+		lines:      make(map[ast.Stmt]parser.LineRange),
+	}
+}
+
+// Interrupt cancels the input typed so far or, while code runs, stops it
+// with a runtime error. It may be called from any goroutine, typically on
+// SIGINT.
+func (r *REPL) Interrupt() {
+	select {
+	case r.interrupts <- struct{}{}:
+	default:
+	}
+}
+
+// Run reads and evaluates input until it ends or the user quits.
+func (r *REPL) Run(input LineReader) {
+	var pending strings.Builder
+	for {
+		p := prompt
+		if pending.Len() > 0 {
+			p = continuationPrompt
+		}
+		line, err := input.ReadLine(p)
+		if errors.Is(err, ErrInterrupted) {
+			pending.Reset()
+			continue
+		}
+		if err == io.EOF {
+			fmt.Fprintln(r.stdout)
+			return
+		}
+		if err != nil {
+			fmt.Fprintln(r.stderr, "Read error:", err)
+			return
+		}
+
+		if pending.Len() == 0 && strings.HasPrefix(strings.TrimSpace(line), ":") {
+			if !r.command(strings.TrimSpace(line)) {
+				return
+			}
+			continue
+		}
+		if pending.Len() == 0 && strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		pending.WriteString(line)
+		pending.WriteString("\n")
+		if incomplete(pending.String()) {
+			continue
+		}
+		r.eval(pending.String(), true)
+		pending.Reset()
+	}
+}
+
+// incomplete reports whether src ends inside a string or with brackets
+// left open, so the REPL should read another line.
+func incomplete(src string) bool {
+	var tokens []scanner.Token
+	diagnostics := shared.Collect(func() {
+		tokens = scanner.NewScanner(src).ScanTokens()
+	})
+	for _, diag := range diagnostics {
+		if diag.Code == codes.UnterminatedString {
+			return true
+		}
+	}
+
+	depth := 0
+	for _, token := range tokens {
+		switch token.Type {
+		case scanner.LEFT_PAREN, scanner.LEFT_BRACE:
+			depth++
+		case scanner.RIGHT_PAREN, scanner.RIGHT_BRACE:
+			depth--
+		}
+	}
+	return depth > 0
+}
+
+// command runs a meta-command. It returns false when the REPL should exit.
+func (r *REPL) command(line string) bool {
+	name, arg, _ := strings.Cut(line, " ")
+	arg = strings.TrimSpace(arg)
+
+	switch name {
+	case ":help", ":h":
+		fmt.Fprintln(r.stdout, help)
+	case ":quit", ":q":
+		return false
+	case ":reset":
+		r.in = interpreter.NewInterpreter()
+		r.in.SetOutput(r.stdout, r.stderr)
+		r.lines = make(map[ast.Stmt]parser.LineRange)
+	case ":env":
+		globals := r.in.Globals()
+		for _, name := range globals.Names() {
+			fmt.Fprintf(r.stdout, "%s = %s\n", name, globals.GetAt(0, name))
+		}
+	case ":load":
+		if arg == "" {
+			fmt.Fprintln(r.stderr, "Usage: :load FILE")
+			break
+		}
+		data, err := os.ReadFile(arg)
+		if err != nil {
+			fmt.Fprintln(r.stderr, "Error:", err)
+			break
+		}
+		r.eval(string(data), false)
+	case ":ast":
+		if arg == "" {
+			fmt.Fprintln(r.stderr, "Usage: :ast SOURCE")
+			break
+		}
+		statements, _, ok := r.parse(arg + "\n")
+		if ok {
+			fmt.Fprint(r.stdout, (&ast.AstPrinter{}).PrintProgram(statements))
+		}
+	default:
+		fmt.Fprintf(r.stderr, "Unknown command %q; try :help.\n", name)
+	}
+	return true
+}
+
+// parse parses src as statements or, failing that, as a lone expression
+// without its semicolon. Errors are printed and ok is false.
+func (r *REPL) parse(src string) (statements []ast.Stmt, lines map[ast.Stmt]parser.LineRange, ok bool) {
+	var tokens []scanner.Token
+	if r.report(src, shared.Collect(func() {
+		tokens = scanner.NewScanner(src).WithInterner(r.in.Strings()).ScanTokens()
+	})) {
+		return nil, nil, false
+	}
+
+	var p *parser.Parser
+	diagnostics := shared.Collect(func() {
+		p = parser.NewParser(tokens)
+		statements = p.Parse()
+	})
+	if len(diagnostics) == 0 {
+		return statements, p.Lines(), true
+	}
+
+	var expr ast.Expr
+	if len(shared.Collect(func() {
+		expr = parser.NewParser(tokens).ParseExpression()
+	})) == 0 {
+		stmt := &ast.Expression{Expression: expr}
+		lines := map[ast.Stmt]parser.LineRange{stmt: {Start: 1, End: 1}}
+		return []ast.Stmt{stmt}, lines, true
+	}
+
+	r.report(src, diagnostics)
+	return nil, nil, false
+}
+
+// report prints diagnostics against the src they came from and returns
+// whether there were any.
+func (r *REPL) report(src string, diagnostics []shared.Diagnostic) bool {
+	for _, diag := range diagnostics {
+		fmt.Fprintln(r.stderr, diag.Caret(src))
+	}
+	return len(diagnostics) > 0
+}
+
+// eval runs src. With echo set, a final expression statement prints its
+// value unless that is nil.
+func (r *REPL) eval(src string, echo bool) {
+	shared.ResetErrors()
+
+	statements, lines, ok := r.parse(src)
+	if !ok {
+		return
+	}
+	if r.report(src, shared.Collect(func() {
+		resolver.NewResolver(r.in).Resolve(statements)
+	})) {
+		return
+	}
+	if r.Optimizer != nil {
+		statements = r.Optimizer.Optimize(statements)
+	}
+	for stmt, lineRange := range lines {
+		r.lines[stmt] = lineRange
+	}
+
+	var last ast.Expr
+	if n := len(statements); echo && n > 0 {
+		if stmt, ok := statements[n-1].(*ast.Expression); ok {
+			statements, last = statements[:n-1], stmt.Expression
+		}
+	}
+
+	r.in.SetHooks(r)
+	defer r.in.SetHooks(nil)
+	defer r.drainInterrupts()
+
+	r.in.Interpret(statements)
+	if last == nil || shared.HadRuntimeError {
+		return
+	}
+	if value, ok := r.in.InterpretExpression(last); ok && !value.IsNil() {
+		fmt.Fprintln(r.stdout, value)
+	}
+}
+
+// BeforeStatement implements interpreter.Hooks, stopping the program when
+// the user interrupts it.
+func (r *REPL) BeforeStatement(in *interpreter.Interpreter, stmt ast.Stmt) {
+	select {
+	case <-r.interrupts:
+	default:
+		return
+	}
+
+	lineRange, ok := r.lines[stmt]
+	if !ok {
+		// A statement the parser synthesized; stop at the next real one.
+		r.Interrupt()
+		return
+	}
+	panic(interpreter.NewRuntimeError(scanner.Token{Line: lineRange.Start}, codes.Interrupted))
+}
+
+// drainInterrupts drops a Ctrl-C that arrived as the program finished, so
+// it does not cancel the next input.
+func (r *REPL) drainInterrupts() {
+	select {
+	case <-r.interrupts:
+	default:
+	}
+}
+
+// Completions returns the words that could complete the identifier at the
+// end of before: keywords and globals, or after "name." the fields and
+// methods of the instance that name, or a chain of fields from it, holds.
+func (r *REPL) Completions(before string) []string {
+	start := len(before)
+	for start > 0 && isIdentifier(rune(before[start-1])) {
+		start--
+	}
+	if start == 0 || before[start-1] != '.' {
+		return append(scanner.Keywords(), r.in.Globals().Names()...)
+	}
+
+	end := start - 1
+	start = end
+	for start > 0 && (isIdentifier(rune(before[start-1])) || before[start-1] == '.') {
+		start--
+	}
+	path := strings.Split(before[start:end], ".")
+	if path[0] == "" || !r.in.Globals().Has(path[0]) {
+		return nil
+	}
+	value := r.in.Globals().GetAt(0, path[0])
+	for _, name := range path[1:] {
+		instance, ok := value.AsObject().(*interpreter.LoxInstance)
+		if !ok {
+			return nil
+		}
+		if value, ok = instance.Field(name); !ok {
+			return nil
+		}
+	}
+
+	instance, ok := value.AsObject().(*interpreter.LoxInstance)
+	if !ok {
+		return nil
+	}
+	return append(instance.FieldNames(), instance.Class.MethodNames()...)
+}
+
+// Reader returns a LineReader for plain input, such as a pipe or a
+// terminal in its normal mode. Prompts are written to the REPL's stdout,
+// and Interrupt cancels a pending read.
+func (r *REPL) Reader(rd io.Reader) LineReader {
+	lines := make(chan lineResult)
+	go func() {
+		scanner := bufio.NewScanner(rd)
+		for scanner.Scan() {
+			lines <- lineResult{line: scanner.Text()}
+		}
+		err := scanner.Err()
+		if err == nil {
+			err = io.EOF
+		}
+		for {
+			lines <- lineResult{err: err}
+		}
+	}()
+	return &plainReader{repl: r, lines: lines}
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+type plainReader struct {
+	repl  *REPL
+	lines <-chan lineResult
+}
+
+func (p *plainReader) ReadLine(prompt string) (string, error) {
+	fmt.Fprint(p.repl.stdout, prompt)
+	select {
+	case result := <-p.lines:
+		return strings.TrimRight(result.line, "\r"), result.err
+	case <-p.repl.interrupts:
+		fmt.Fprintln(p.repl.stdout)
+		return "", ErrInterrupted
+	}
+}
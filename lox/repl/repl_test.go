@@ -0,0 +1,312 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"example.com/golox/lox/interpreter"
+)
+
+// scripted is a LineReader that replays lines and records prompts. A nil
+// entry stands for Ctrl-C.
+type scripted struct {
+	lines   []*string
+	prompts []string
+}
+
+func line(s string) *string { return &s }
+
+func (s *scripted) ReadLine(prompt string) (string, error) {
+	s.prompts = append(s.prompts, prompt)
+	if len(s.lines) == 0 {
+		return "", io.EOF
+	}
+	next := s.lines[0]
+	s.lines = s.lines[1:]
+	if next == nil {
+		return "", ErrInterrupted
+	}
+	return *next, nil
+}
+
+func run(t *testing.T, lines ...*string) (stdout, stderr string, input *scripted) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	input = &scripted{lines: lines}
+	New(interpreter.NewInterpreter(), &out, &errOut).Run(input)
+	return out.String(), errOut.String(), input
+}
+
+func TestMultiLineInputAndEcho(t *testing.T) {
+	stdout, stderr, input := run(t,
+		line("fun add(a, b) {"),
+		line("  return a + b;"),
+		line("}"),
+		line("add(1,"),
+		line("  2)"),
+		line("var s = \"two"),
+		line("lines\";"),
+		line("s"),
+		line("print nil;"),
+		line("nil"),
+	)
+	if stderr != "" {
+		t.Fatalf("unexpected errors: %q", stderr)
+	}
+	if stdout != "3\ntwo\nlines\nnil\n\n" {
+		t.Fatalf("unexpected output: %q", stdout)
+	}
+
+	want := []string{"> ", "... ", "... ", "> ", "... ", "> ", "... ", "> ", "> ", "> ", "> "}
+	if strings.Join(input.prompts, "|") != strings.Join(want, "|") {
+		t.Fatalf("unexpected prompts: %q", input.prompts)
+	}
+}
+
+func TestErrorsDoNotEndTheSession(t *testing.T) {
+	stdout, stderr, _ := run(t,
+		line("var x = ;"),
+		line("1 +"),
+		line("missing"),
+		line("return 1;"),
+		line("40 + 2"),
+	)
+	for _, want := range []string{
+		"Error[L0101] at ';': Expect expression.",
+		"Error[L0101] at end: Expect expression.",
+		"Error[L0306]: Undefined variable 'missing'.",
+		"Can't return from top-level code.",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("expected %q in errors:\n%s", want, stderr)
+		}
+	}
+	if stdout != "42\n\n" {
+		t.Fatalf("unexpected output: %q", stdout)
+	}
+}
+
+func TestCtrlCDiscardsPendingInput(t *testing.T) {
+	stdout, stderr, input := run(t,
+		line("fun broken() {"),
+		nil,
+		line("1 + 1"),
+	)
+	if stderr != "" || stdout != "2\n\n" {
+		t.Fatalf("unexpected output %q, errors %q", stdout, stderr)
+	}
+	if input.prompts[2] != "> " {
+		t.Fatalf("expected a fresh prompt after Ctrl-C, got %q", input.prompts)
+	}
+}
+
+func TestMetaCommands(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lib.lox")
+	if err := os.WriteFile(path, []byte("var loaded = 7;\nloaded;\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout, stderr, _ := run(t,
+		line(":load "+path),
+		line(":env"),
+		line(":ast -a * (b + 1)"),
+		line(":ast print 1;"),
+		line(":reset"),
+		line(":env"),
+		line(":nope"),
+		line(":quit"),
+		line("print \"unreachable\";"),
+	)
+	want := "clock = <native fn>\nloaded = 7\n" +
+		"(; (* (- a) (group (+ b 1))))\n" +
+		"(print 1)\n" +
+		"clock = <native fn>\n"
+	if stdout != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", stdout, want)
+	}
+	if !strings.Contains(stderr, `Unknown command ":nope"`) {
+		t.Fatalf("expected an unknown command error, got %q", stderr)
+	}
+}
+
+func TestInterruptStopsARunningProgram(t *testing.T) {
+	var out, errOut bytes.Buffer
+	r := New(interpreter.NewInterpreter(), &out, &errOut)
+
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		r.Interrupt()
+	}()
+	r.Run(&scripted{lines: []*string{
+		line("var n = 0;"),
+		line("while (true) n = n + 1;"),
+		line("n > 0"),
+	}})
+
+	if !strings.Contains(errOut.String(), "Interrupted.\n[line 1]") {
+		t.Fatalf("expected the loop to be interrupted, got %q", errOut.String())
+	}
+	if out.String() != "true\n\n" {
+		t.Fatalf("expected the session to continue, got %q", out.String())
+	}
+}
+
+func TestPlainReaderPromptsAndInterrupts(t *testing.T) {
+	var out bytes.Buffer
+	r := New(interpreter.NewInterpreter(), &out, io.Discard)
+
+	pr, pw := io.Pipe()
+	input := r.Reader(pr)
+	go func() {
+		io.WriteString(pw, "1 + 1\r\n")
+	}()
+	if got, err := input.ReadLine("> "); err != nil || got != "1 + 1" {
+		t.Fatalf("ReadLine = %q, %v", got, err)
+	}
+
+	r.Interrupt()
+	if _, err := input.ReadLine("> "); err != ErrInterrupted {
+		t.Fatalf("expected ErrInterrupted, got %v", err)
+	}
+	pw.Close()
+	if _, err := input.ReadLine("> "); err != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", err)
+	}
+	if out.String() != "> > \n> " {
+		t.Fatalf("unexpected prompts: %q", out.String())
+	}
+}
+
+// edit types keys into an Editor and returns the lines it produced.
+func edit(t *testing.T, history *History, complete func(string) []string, keys string) []string {
+	t.Helper()
+	e := NewEditor(strings.NewReader(keys), io.Discard, history)
+	e.Complete = complete
+
+	var lines []string
+	for {
+		got, err := e.ReadLine("> ")
+		if err == io.EOF {
+			return lines
+		}
+		if err == ErrInterrupted {
+			got = "<interrupted>"
+		} else if err != nil {
+			t.Fatalf("ReadLine: %v", err)
+		}
+		lines = append(lines, got)
+	}
+}
+
+func TestEditorKeys(t *testing.T) {
+	history, _ := LoadHistory("")
+	got := edit(t, history, nil, strings.Join([]string{
+		"print 1;\r",
+		"ac\x1b[Db\r",                 // left arrow, insert
+		"world\x01hello \x05!\r",      // Ctrl-A, Ctrl-E
+		"one two three\x17\x17four\r", // Ctrl-W twice
+		"abcdef\x02\x02\x0b\x7fX\r",   // Ctrl-B, Ctrl-K, backspace
+		"abc\x1b[H\x1b[3~\x1b[F.\r",   // Home, Delete, End
+		"drop me\x15kept\r",           // Ctrl-U
+		"half\x03",                    // Ctrl-C
+		"\x1b[A\x1b[A\x1b[B\r",        // Up, Up, Down
+		"new\x10\x0e\r",               // Ctrl-P, Ctrl-N restore the typed line
+		"last",                        // ends with the input
+	}, ""))
+
+	want := []string{"print 1;", "abc", "hello world!", "one four", "abcX", "bc.", "kept", "<interrupted>", "kept", "new", "last"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+	if entries := history.Entries(); len(entries) != 9 || entries[8] != "last" {
+		t.Fatalf("unexpected history: %q", entries)
+	}
+}
+
+func TestEditorReverseSearch(t *testing.T) {
+	history, _ := LoadHistory("")
+	for _, entry := range []string{"var total = 0;", "print total;", "fun f() {}", "print total + 1;"} {
+		history.Add(entry)
+	}
+
+	got := edit(t, history, nil, strings.Join([]string{
+		"\x12tot\r",               // newest match
+		"\x12tot\x12\x12\r",       // older matches, stopping at the oldest
+		"\x12fun\x1b[D\x7f\x7f\r", // an arrow key accepts the match for editing
+		"\x12zzz\x07x\r",          // Ctrl-G abandons a failed search
+	}, ""))
+
+	want := []string{"print total + 1;", "var total = 0;", "fun f()}", "x"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+}
+
+func TestHistoryPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history")
+	history, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range []string{"a", "b", "b", "  ", "c"} {
+		if err := history.Add(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reloaded, err := LoadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(reloaded.Entries(), ","); got != "a,b,c" {
+		t.Fatalf("expected a,b,c, got %q", got)
+	}
+
+	long := strings.Repeat("x\n", maxHistory+5)
+	if err := os.WriteFile(path, []byte(long), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if trimmed, err := LoadHistory(path); err != nil || len(trimmed.Entries()) != maxHistory {
+		t.Fatalf("expected the history to be trimmed, got %d entries, %v", len(trimmed.Entries()), err)
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	var out bytes.Buffer
+	r := New(interpreter.NewInterpreter(), &out, &out)
+	r.Run(&scripted{lines: []*string{
+		line("class Shape { area() { return 0; } }"),
+		line("class Box < Shape { init() { this.width = 1; this.inner = Shape(); } volume() {} }"),
+		line("var box = Box();"),
+		line("var boxes = 2;"),
+	}})
+	if out.String() != "\n" {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+
+	history, _ := LoadHistory("")
+	got := edit(t, history, r.Completions, strings.Join([]string{
+		"pri\t 1;\r",       // keyword
+		"bo\t\tes\r",       // common prefix "box", then a list, then typed
+		"box.v\t\r",        // method
+		"box.w\t\r",        // field
+		"box.inner.ar\t\r", // through a field to an inherited class's method
+		"nope.x\t\r",       // unknown names complete nothing
+	}, ""))
+	want := []string{"print 1;", "boxes", "box.volume", "box.width", "box.inner.area", "nope.x"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("got %q\nwant %q", got, want)
+	}
+
+	words := strings.Join(r.Completions("box."), " ")
+	for _, want := range []string{"width", "inner", "init", "volume", "area"} {
+		if !strings.Contains(words, want) {
+			t.Errorf("expected %q among %q", want, words)
+		}
+	}
+}
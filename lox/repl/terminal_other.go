@@ -0,0 +1,10 @@
+//go:build !linux
+
+package repl
+
+// Line editing needs raw terminal mode, which is only implemented for
+// Linux; elsewhere the REPL reads plain lines.
+
+func isTerminal(fd int) bool { return false }
+
+func makeRaw(fd int) (restore func(), err error) { return nil, ErrNotTerminal }
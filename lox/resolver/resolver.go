@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"example.com/golox/lox/ast"
+	"example.com/golox/lox/codes"
 	"example.com/golox/lox/interpreter"
 	"example.com/golox/lox/scanner"
 	"example.com/golox/lox/shared"
@@ -31,11 +32,18 @@ type Resolver struct {
 	scopes []map[string]bool
     currentFunction FunctionType
     currentClass ClassType
+
+    // Symbol index for tooling; scopeSymbols runs parallel to scopes.
+    scopeSymbols []map[string]*Symbol
+    globals      map[string]*Symbol
+    symbols      []*Symbol
+    pending      []pendingReference
+    unresolved   []Reference
 }
 
-func (r *Resolver) errorToken(token scanner.Token, message string) {
+func (r *Resolver) errorToken(token scanner.Token, code codes.Code) {
     where := fmt.Sprintf(" at '%s'", token.Lexeme)
-    shared.Report(token.Line, where, message)
+    shared.ReportAt(token.Line, token.Column, token.Length, where, code)
 }
 
 func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
@@ -44,6 +52,7 @@ func NewResolver(interpreter *interpreter.Interpreter) *Resolver {
         scopes: nil,
         currentFunction: FunctionNone,
         currentClass:    ClassNone,
+        globals:         make(map[string]*Symbol),
 	}
 }
 
@@ -56,6 +65,7 @@ func (r *Resolver) VisitBlockStmt(stmt *ast.Block) any {
 
 func (r *Resolver) VisitVarStmt(stmt *ast.Var) any {
 	r.declare(stmt.Name)
+	r.declareSymbol(stmt.Name, SymbolVariable)
 	if stmt.Initializer != nil {
 		r.resolveExpr(stmt.Initializer)
 	}
@@ -68,7 +78,7 @@ func (r *Resolver) VisitVariableExpr(expr *ast.Variable) any {
         top := r.scopes[len(r.scopes)-1]
 
         if defined, ok := top[expr.Name.Lexeme]; ok && !defined {
-            r.errorToken(expr.Name, "Can't read local variable in its own initializer.")
+            r.errorToken(expr.Name, codes.ReadInOwnInitializer)
         }
     }
 
@@ -84,6 +94,7 @@ func (r *Resolver) VisitAssignExpr(expr *ast.Assign) any {
 
 func (r *Resolver) VisitFunctionStmt(stmt *ast.Function) any {
 	r.declare(stmt.Name)
+	r.declareSymbol(stmt.Name, SymbolFunction).Function = stmt
 	r.define(stmt.Name)
 
 	r.resolveFunction(stmt, FunctionFunction)
@@ -111,14 +122,21 @@ func (r *Resolver) VisitPrintStmt(stmt *ast.Print) any {
 
 func (r *Resolver) VisitReturnStmt(stmt *ast.Return) any {
     if r.currentFunction == FunctionNone {
-        r.errorToken(stmt.Keyword, "Can't return from top-level code.")
+        r.errorToken(stmt.Keyword, codes.TopLevelReturn)
     }
 
     if stmt.Value != nil {
         if r.currentFunction == FunctionInitializer {
-            r.errorToken(stmt.Keyword, "Can't return a value from an initializer.")
+            r.errorToken(stmt.Keyword, codes.ReturnFromInit)
         }
         r.resolveExpr(stmt.Value)
+
+        // A returned call is always in tail position; initializers are
+        // excluded because they must return 'this'.
+        _, isCall := stmt.Value.(*ast.Call)
+        if isCall && r.currentFunction != FunctionInitializer && r.currentFunction != FunctionNone {
+            r.interpreter.MarkTailCall(stmt)
+        }
     }
 
     return nil
@@ -147,6 +165,18 @@ func (r *Resolver) VisitCallExpr(expr *ast.Call) any {
     return nil
 }
 
+// VisitBadStmt has nothing to resolve: the source it stands for did not
+// parse.
+func (r *Resolver) VisitBadStmt(stmt *ast.Bad) any {
+    return nil
+}
+
+// VisitErrorExpr has nothing to resolve: the source it stands for did not
+// parse.
+func (r *Resolver) VisitErrorExpr(expr *ast.Error) any {
+    return nil
+}
+
 func (r *Resolver) VisitGroupingExpr(expr *ast.Grouping) any {
     r.resolveExpr(expr.Expression)
     return nil
@@ -172,12 +202,13 @@ func (r *Resolver) VisitClassStmt(stmt *ast.Class) any {
     r.currentClass = ClassClass
 
     r.declare(stmt.Name)
+    r.declareSymbol(stmt.Name, SymbolClass).Class = stmt
     r.define(stmt.Name)
 
     if stmt.Superclass != nil {
         if superVar, ok := stmt.Superclass.(*ast.Variable); ok {
             if stmt.Name.Lexeme == superVar.Name.Lexeme {
-                r.errorToken(superVar.Name, "A class can't inherit from itself.")
+                r.errorToken(superVar.Name, codes.InheritFromSelf)
             }
         }
     }
@@ -201,6 +232,12 @@ func (r *Resolver) VisitClassStmt(stmt *ast.Class) any {
         if method.Name.Lexeme == "init" {
             fnType = FunctionInitializer
         }
+        r.symbols = append(r.symbols, &Symbol{
+            Name:     method.Name,
+            Kind:     SymbolMethod,
+            Function: method,
+            Class:    stmt,
+        })
         r.resolveFunction(method, fnType)
     }
 
@@ -228,7 +265,7 @@ func (r *Resolver) VisitSetExpr(expr *ast.Set) any {
 
 func (r *Resolver) VisitThisExpr(expr *ast.This) any {
     if r.currentClass == ClassNone {
-        r.errorToken(expr.Keyword, "Can't use 'this' outside of a class.")
+        r.errorToken(expr.Keyword, codes.ThisOutsideClass)
         return nil
     }
 
@@ -238,9 +275,9 @@ func (r *Resolver) VisitThisExpr(expr *ast.This) any {
 
 func (r *Resolver) VisitSuperExpr(expr *ast.Super) any {
     if r.currentClass == ClassNone {
-        r.errorToken(expr.Keyword, "Can't use 'super' outside of a class.")
+        r.errorToken(expr.Keyword, codes.SuperOutsideClass)
     } else if r.currentClass != ClassSubClass {
-        r.errorToken(expr.Keyword, "Can't use 'super' in a class with no superclass.")
+        r.errorToken(expr.Keyword, codes.SuperWithoutSuper)
     }
 
     r.resolveLocal(expr, expr.Keyword)
@@ -249,6 +286,9 @@ func (r *Resolver) VisitSuperExpr(expr *ast.Super) any {
 
 func (r *Resolver) Resolve(statements []ast.Stmt) {
 	r.resolveStmts(statements)
+	if len(r.scopes) == 0 {
+		r.bindGlobals()
+	}
 }
 
 func (r *Resolver) resolveStmts(stmts []ast.Stmt) {
@@ -273,10 +313,12 @@ func (r *Resolver) resolveExpr(expr ast.Expr) {
 
 func (r *Resolver) beginScope() {
 	r.scopes = append(r.scopes, make(map[string]bool))
+	r.scopeSymbols = append(r.scopeSymbols, make(map[string]*Symbol))
 }
 
 func (r *Resolver) endScope() {
 	r.scopes = r.scopes[:len(r.scopes)-1]
+	r.scopeSymbols = r.scopeSymbols[:len(r.scopeSymbols)-1]
 }
 
 func (r *Resolver) declare(name scanner.Token) {
@@ -287,7 +329,7 @@ func (r *Resolver) declare(name scanner.Token) {
     scope := r.scopes[len(r.scopes)-1]
 
     if _, exists := scope[name.Lexeme]; exists {
-        r.errorToken(name, "Already a variable with this name in this scope.")
+        r.errorToken(name, codes.AlreadyDeclared)
     }
 
     scope[name.Lexeme] = false
@@ -308,9 +350,19 @@ func (r *Resolver) resolveLocal(expr ast.Expr, name scanner.Token) {
         if _, ok := scope[name.Lexeme]; ok {
             distance := len(r.scopes) - 1 - i
             r.interpreter.Resolve(expr, distance)
+            r.recordReference(i, expr, name)
             return
         }
     }
+    r.recordReference(-1, expr, name)
+}
+
+func (r *Resolver) recordReference(scope int, expr ast.Expr, name scanner.Token) {
+    if name.Type != scanner.IDENTIFIER {
+        return // 'this' and 'super' are not symbols
+    }
+    _, assign := expr.(*ast.Assign)
+    r.reference(scope, name, assign)
 }
 
 func (r *Resolver) resolveFunction(function *ast.Function, fnType FunctionType) {
@@ -320,6 +372,7 @@ func (r *Resolver) resolveFunction(function *ast.Function, fnType FunctionType)
     r.beginScope()
     for _, param := range function.Params {
         r.declare(param)
+        r.declareSymbol(param, SymbolParameter)
         r.define(param)
     }
     r.resolveStmts(function.Body)
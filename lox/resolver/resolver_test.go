@@ -1,6 +1,7 @@
 package resolver
 
 import (
+    "strings"
     "testing"
 
     "example.com/golox/lox/interpreter"
@@ -185,3 +186,103 @@ func TestLogicalExpressionIsResolved(t *testing.T) {
         t.Fatalf("did not expect resolver error for logical expression")
     }
 }
+
+func resolveSymbols(t *testing.T, src string) *Resolver {
+    t.Helper()
+    shared.ResetErrors()
+
+    stmts := parser.NewParser(scanner.NewScanner(src).ScanTokens()).Parse()
+    r := NewResolver(interpreter.NewInterpreter())
+    r.Resolve(stmts)
+
+    if shared.HadError {
+        t.Fatalf("unexpected error resolving %q", src)
+    }
+    return r
+}
+
+func TestSymbolsTrackDeclarationsAndReferences(t *testing.T) {
+    src := `var count = 0;
+fun bump(by) {
+  var count = by;
+  return count;
+}
+count = bump(count);
+print later();
+fun later() { return clock(); }
+print missing;`
+    r := resolveSymbols(t, src)
+
+    global := r.SymbolAt(1, 5)
+    if global == nil || global.Name.Lexeme != "count" || !global.Global {
+        t.Fatalf("expected global 'count' at 1:5, got %+v", global)
+    }
+    if len(global.References) != 2 || global.References[0].Assign || !global.References[1].Assign {
+        t.Fatalf("expected a read then an assignment of global count, got %+v", global.References)
+    }
+
+    local := r.SymbolAt(4, 10)
+    if local == nil || local.Global || local.Kind != SymbolVariable || local.Name.Line != 3 {
+        t.Fatalf("expected the reference at 4:10 to resolve to the local on line 3, got %+v", local)
+    }
+    if local.Shadows != global {
+        t.Errorf("expected local 'count' to shadow the global")
+    }
+
+    param := r.SymbolAt(3, 15)
+    if param == nil || param.Kind != SymbolParameter || len(param.References) != 1 {
+        t.Errorf("expected parameter 'by' with one reference, got %+v", param)
+    }
+
+    later := r.SymbolAt(7, 7)
+    if later == nil || later.Kind != SymbolFunction || later.Function == nil || later.Name.Line != 8 {
+        t.Errorf("expected forward reference to resolve to function 'later', got %+v", later)
+    }
+
+    unresolved := r.Unresolved()
+    if len(unresolved) != 1 || unresolved[0].Token.Lexeme != "missing" {
+        t.Errorf("expected only 'missing' to be unresolved (clock is native), got %+v", unresolved)
+    }
+}
+
+func TestUnresolvedReferencesSuggestVisibleNames(t *testing.T) {
+    r := resolveSymbols(t, `
+        var count = 0;
+        fun f(total) {
+            totl = 1;
+            coutn = 2;
+            clok = 3;
+            zzz = 4;
+        }
+    `)
+
+    var got []string
+    for _, ref := range r.Unresolved() {
+        got = append(got, ref.Token.Lexeme+"->"+ref.Suggestion)
+    }
+    want := "totl->total,coutn->count,clok->clock,zzz->"
+    if strings.Join(got, ",") != want {
+        t.Fatalf("expected %q, got %q", want, strings.Join(got, ","))
+    }
+}
+
+func TestSymbolsRecordClassesAndMethods(t *testing.T) {
+    r := resolveSymbols(t, `
+        class A { greet(name) { return name; } }
+        class B < A {}
+    `)
+
+    var kinds []string
+    for _, symbol := range r.Symbols() {
+        kinds = append(kinds, symbol.Kind.String()+" "+symbol.Name.Lexeme)
+    }
+    want := "class A,method greet,parameter name,class B"
+    if got := strings.Join(kinds, ","); got != want {
+        t.Fatalf("expected symbols %q, got %q", want, got)
+    }
+
+    a := r.Symbols()[0]
+    if len(a.References) != 1 || a.References[0].Token.Line != 3 {
+        t.Errorf("expected superclass reference to class A, got %+v", a.References)
+    }
+}
@@ -0,0 +1,173 @@
+package resolver
+
+import (
+	"sort"
+
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/scanner"
+	"example.com/golox/lox/suggest"
+)
+
+type SymbolKind int
+
+const (
+	SymbolVariable SymbolKind = iota
+	SymbolParameter
+	SymbolFunction
+	SymbolClass
+	SymbolMethod
+)
+
+func (k SymbolKind) String() string {
+	switch k {
+	case SymbolVariable:
+		return "variable"
+	case SymbolParameter:
+		return "parameter"
+	case SymbolFunction:
+		return "function"
+	case SymbolClass:
+		return "class"
+	case SymbolMethod:
+		return "method"
+	default:
+		return "unknown"
+	}
+}
+
+// Reference is one use of a symbol's name.
+type Reference struct {
+	Token  scanner.Token
+	Assign bool
+	// Suggestion is, for an unresolved reference, the name visible there
+	// that it was probably meant to be, if there is one.
+	Suggestion string
+}
+
+// pendingReference is a reference to a global, waiting for the whole
+// program to be seen, with the local scopes that were visible from it.
+type pendingReference struct {
+	Reference
+	scopes []map[string]*Symbol
+}
+
+// Symbol is a declaration seen by the resolver together with every
+// reference that resolved to it.
+type Symbol struct {
+	Name       scanner.Token
+	Kind       SymbolKind
+	Global     bool
+	References []Reference
+
+	// Function is set for functions and methods, Class for classes and
+	// for the methods declared in them.
+	Function *ast.Function
+	Class    *ast.Class
+
+	// Shadows is the symbol from an enclosing scope, if any, that this
+	// declaration hides.
+	Shadows *Symbol
+}
+
+// Symbols returns every declaration in the order it was resolved.
+func (r *Resolver) Symbols() []*Symbol {
+	return r.symbols
+}
+
+// Unresolved returns references to globals that are never declared in the
+// program and are not defined by the interpreter.
+func (r *Resolver) Unresolved() []Reference {
+	return r.unresolved
+}
+
+// SymbolAt returns the symbol declared or referenced by the token that
+// covers line and column, both counted from 1.
+func (r *Resolver) SymbolAt(line, column int) *Symbol {
+	for _, symbol := range r.symbols {
+		if covers(symbol.Name, line, column) {
+			return symbol
+		}
+		for _, ref := range symbol.References {
+			if covers(ref.Token, line, column) {
+				return symbol
+			}
+		}
+	}
+	return nil
+}
+
+func covers(token scanner.Token, line, column int) bool {
+	return token.Line == line && column >= token.Column && column < token.Column+len(token.Lexeme)
+}
+
+// declareSymbol records a declaration in the current scope. It is called
+// alongside declare, which keeps doing the resolver's own bookkeeping.
+func (r *Resolver) declareSymbol(name scanner.Token, kind SymbolKind) *Symbol {
+	symbol := &Symbol{Name: name, Kind: kind, Global: len(r.scopes) == 0}
+	symbol.Shadows = r.lookupSymbol(name.Lexeme)
+	r.symbols = append(r.symbols, symbol)
+
+	if symbol.Global {
+		r.globals[name.Lexeme] = symbol
+	} else {
+		r.scopeSymbols[len(r.scopeSymbols)-1][name.Lexeme] = symbol
+	}
+	return symbol
+}
+
+// lookupSymbol finds the innermost visible declaration of name.
+func (r *Resolver) lookupSymbol(name string) *Symbol {
+	for i := len(r.scopeSymbols) - 1; i >= 0; i-- {
+		if symbol, ok := r.scopeSymbols[i][name]; ok {
+			return symbol
+		}
+	}
+	return r.globals[name]
+}
+
+func (r *Resolver) reference(scope int, name scanner.Token, assign bool) {
+	ref := Reference{Token: name, Assign: assign}
+	if scope >= 0 {
+		if symbol, ok := r.scopeSymbols[scope][name.Lexeme]; ok {
+			symbol.References = append(symbol.References, ref)
+		}
+		return
+	}
+
+	// Globals are late bound, so a function body may use one declared
+	// further down. Those are matched up once the whole program is seen.
+	scopes := append([]map[string]*Symbol(nil), r.scopeSymbols...)
+	r.pending = append(r.pending, pendingReference{Reference: ref, scopes: scopes})
+}
+
+func (r *Resolver) bindGlobals() {
+	for _, p := range r.pending {
+		ref := p.Reference
+		if symbol, ok := r.globals[ref.Token.Lexeme]; ok {
+			symbol.References = append(symbol.References, ref)
+		} else if !r.interpreter.IsGlobal(ref.Token.Lexeme) {
+			ref.Suggestion, _ = suggest.Closest(ref.Token.Lexeme, r.visibleNames(p.scopes))
+			r.unresolved = append(r.unresolved, ref)
+		}
+	}
+	r.pending = nil
+}
+
+// visibleNames lists the names declared in scopes, innermost first, then
+// the program's globals and those the interpreter defines. The names of
+// each scope are sorted, so that ties go the same way every run.
+func (r *Resolver) visibleNames(scopes []map[string]*Symbol) []string {
+	var names []string
+	add := func(scope map[string]*Symbol) {
+		start := len(names)
+		for name := range scope {
+			names = append(names, name)
+		}
+		sort.Strings(names[start:])
+	}
+	for i := len(scopes) - 1; i >= 0; i-- {
+		add(scopes[i])
+	}
+	add(r.globals)
+	return append(names, r.interpreter.Globals().Names()...)
+}
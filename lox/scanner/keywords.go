@@ -1,5 +1,7 @@
 package scanner
 
+import "sort"
+
 var keywords = map[string]TokenType{
 	"and":    AND,
 	"class":  CLASS,
@@ -18,3 +20,13 @@ var keywords = map[string]TokenType{
 	"var":    VAR,
 	"while":  WHILE,
 }
+
+// Keywords returns the reserved words in alphabetical order.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+	return words
+}
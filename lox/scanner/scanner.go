@@ -2,7 +2,10 @@ package scanner
 
 import (
 	"strconv"
+	"strings"
 
+	"example.com/golox/lox/codes"
+	"example.com/golox/lox/intern"
 	"example.com/golox/lox/shared"
 )
 
@@ -13,6 +16,25 @@ type Scanner struct {
 	start   int //Go defaults value to 0
 	current int //Go defaults value to 0
 	line    int
+
+	lineStart   int // offset of the first byte of the current line
+	startColumn int // column of the token being scanned, from 1
+
+	// strings, when set, interns identifier lexemes and string literals.
+	strings *intern.Table
+
+	comments []Comment
+
+	// trivia, when set, keeps whitespace, comments and unscannable bytes
+	// as tokens, so the tokens cover every byte of the source.
+	trivia bool
+}
+
+// Comment is a "//" comment the scanner skipped over.
+type Comment struct {
+	Line   int
+	Column int
+	Text   string // including the leading "//"
 }
 
 func NewScanner(source string) *Scanner {
@@ -23,9 +45,32 @@ func NewScanner(source string) *Scanner {
 	}
 }
 
+// WithInterner makes the scanner intern identifiers and string literals
+// through table, so they share storage with the runtime's names.
+func (s *Scanner) WithInterner(table *intern.Table) *Scanner {
+	s.strings = table
+	return s
+}
+
+// WithTrivia makes the scanner keep whitespace, newlines, comments and
+// bytes it could not scan as trivia tokens, so that concatenating the
+// lexemes gives back the source. Errors are still reported as usual.
+func (s *Scanner) WithTrivia() *Scanner {
+	s.trivia = true
+	return s
+}
+
+func (s *Scanner) intern(text string) string {
+	if s.strings == nil {
+		return text
+	}
+	return s.strings.Intern(text)
+}
+
 func (s *Scanner) ScanTokens() []Token {
 	for !s.isAtEnd() {
 		s.start = s.current
+		s.startColumn = s.start - s.lineStart + 1
 		s.scanToken()
 	}
 
@@ -33,11 +78,18 @@ func (s *Scanner) ScanTokens() []Token {
 		Type:   EOF,
 		Lexeme: "",
 		Line:   s.line,
+		Column: s.current - s.lineStart + 1,
+		Offset: s.current,
 	})
 
 	return s.tokens
 }
 
+// Comments returns the comments seen by ScanTokens, in source order.
+func (s *Scanner) Comments() []Comment {
+	return s.comments
+}
+
 func (s *Scanner) isAtEnd() bool {
 	return s.current >= len(s.source)
 }
@@ -98,14 +150,25 @@ func (s *Scanner) scanToken() {
 			for s.peek() != '\n' && !s.isAtEnd() {
 				s.advance()
 			}
+			s.comments = append(s.comments, Comment{
+				Line:   s.line,
+				Column: s.startColumn,
+				Text:   strings.TrimRight(s.source[s.start:s.current], "\r"),
+			})
+			s.addTrivia(COMMENT)
 		} else {
 			s.addToken(SLASH, nil)
 		}
 
 	case ' ', '\r', '\t':
-		// ignore whitespace
+		// Whitespace is dropped unless the scanner keeps trivia.
+		for s.peek() == ' ' || s.peek() == '\r' || s.peek() == '\t' {
+			s.advance()
+		}
+		s.addTrivia(WHITESPACE)
     case '\n':
-        s.line++
+		s.addTrivia(NEWLINE)
+        s.newline()
 
 	case '"':
 		s.string()
@@ -121,7 +184,8 @@ func (s *Scanner) scanToken() {
 		} else if (isAlpha(c)) {
           s.identifier();
 		} else {
-		shared.ErrorAt(s.line, "Unexpected character.")
+		s.error(codes.UnexpectedCharacter)
+		s.addTrivia(ILLEGAL)
 		}
 	}
 }
@@ -134,14 +198,49 @@ func (s *Scanner) advance() byte {
 
 func (s *Scanner) addToken(t TokenType, literal any) {
 	text := s.source[s.start:s.current]
+	if t == IDENTIFIER {
+		text = s.intern(text)
+	}
 	s.tokens = append(s.tokens, Token{
 		Type:    t,
 		Lexeme:  text,
 		Literal: literal,
 		Line:    s.line,
+		Column:  s.startColumn,
+		Offset:  s.start,
+		Length:  s.current - s.start,
 	})
 }
 
+// addTrivia adds the text just scanned as a trivia token, when the
+// scanner keeps trivia.
+func (s *Scanner) addTrivia(t TokenType) {
+	if s.trivia {
+		s.addToken(t, nil)
+	}
+}
+
+// error reports a problem with the token being scanned, underlining it
+// when it sits on one line.
+func (s *Scanner) error(code codes.Code, args ...any) {
+	text := s.source[s.start:s.current]
+	if strings.Contains(text, "\n") {
+		shared.ReportAt(s.line, 0, 0, "", code, args...)
+		return
+	}
+	shared.ReportAt(s.line, s.startColumn, len(text), "", code, args...)
+}
+
+func (s *Scanner) previous() byte {
+	return s.source[s.current-1]
+}
+
+// newline records that the byte just consumed was a line break.
+func (s *Scanner) newline() {
+	s.line++
+	s.lineStart = s.current
+}
+
 func (s *Scanner) match(expected byte) bool {
 	if s.isAtEnd() {
 		return false
@@ -162,14 +261,15 @@ func (s *Scanner) peek() byte {
 
 func (s *Scanner) string() {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
-		}
 		s.advance()
+		if s.previous() == '\n' {
+			s.newline()
+		}
 	}
 
 	if s.isAtEnd() {
-		shared.ErrorAt(s.line, "Unterminated string.")
+		s.error(codes.UnterminatedString)
+		s.addTrivia(ILLEGAL)
 		return
 	}
 
@@ -178,7 +278,7 @@ func (s *Scanner) string() {
 
 	// Trim the surrounding quotes.
 	value := s.source[s.start+1 : s.current-1]
-	s.addToken(STRING, value)
+	s.addToken(STRING, s.intern(value))
 }
 
 func isDigit(c byte) bool {
@@ -206,7 +306,8 @@ func (s *Scanner) number() {
 	// Parse to float64 (Lox numbers are doubles).
 	value, err := strconv.ParseFloat(lexeme, 64)
 	if err != nil {
-		shared.Report(s.line, "", "Invalid number literal: "+lexeme)
+		s.error(codes.InvalidNumber, lexeme)
+		s.addTrivia(ILLEGAL)
 		return
 	}
 
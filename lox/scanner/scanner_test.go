@@ -2,6 +2,7 @@ package scanner
 
 import (
 	"testing"
+	"example.com/golox/lox/intern"
 	"example.com/golox/lox/shared"
 )
 
@@ -235,3 +236,130 @@ func TestTokenTypeStringUnknownValue(t *testing.T) {
     }
 }
 
+
+func TestTokenTypeOfInvertsString(t *testing.T) {
+    for tt := LEFT_PAREN; tt <= ILLEGAL; tt++ {
+        if got, ok := TokenTypeOf(tt.String()); !ok || got != tt {
+            t.Errorf("TokenTypeOf(%q) = %v, %v", tt.String(), got, ok)
+        }
+    }
+    if _, ok := TokenTypeOf("UNKNOWN"); ok {
+        t.Errorf("expected UNKNOWN not to be a token type")
+    }
+}
+
+func TestInternerSharesIdentifierAndStringStorage(t *testing.T) {
+	table := intern.NewTable()
+	toks := NewScanner(`foo "bar" foo "bar"`).WithInterner(table).ScanTokens()
+
+	if _, ok := table.Lookup(toks[0].Lexeme); !ok {
+		t.Errorf("expected identifier lexemes to be interned")
+	}
+	if _, ok := table.Lookup(toks[1].Literal.(string)); !ok {
+		t.Errorf("expected string literals to be interned")
+	}
+	if table.Len() != 2 {
+		t.Errorf("expected 2 interned strings, got %d", table.Len())
+	}
+}
+
+func TestTokenColumns(t *testing.T) {
+	toks := NewScanner("var ab = 1;\n  print \"xy\" + ab;").ScanTokens()
+
+	want := []struct {
+		lexeme string
+		line   int
+		column int
+	}{
+		{"var", 1, 1}, {"ab", 1, 5}, {"=", 1, 8}, {"1", 1, 10}, {";", 1, 11},
+		{"print", 2, 3}, {"\"xy\"", 2, 9}, {"+", 2, 14}, {"ab", 2, 16}, {";", 2, 18},
+	}
+	for i, w := range want {
+		got := toks[i]
+		if got.Lexeme != w.lexeme || got.Line != w.line || got.Column != w.column {
+			t.Errorf("token %d: got (%q, line %d, col %d), want (%q, line %d, col %d)",
+				i, got.Lexeme, got.Line, got.Column, w.lexeme, w.line, w.column)
+		}
+	}
+}
+
+func TestTokenOffsetsAndLengths(t *testing.T) {
+	src := "var ab = \"x\ny\";\n  ab;"
+	toks := NewScanner(src).ScanTokens()
+
+	for i, tok := range toks[:len(toks)-1] {
+		if got := src[tok.Offset : tok.Offset+tok.Length]; got != tok.Lexeme {
+			t.Errorf("token %d: source at offset %d is %q, want %q", i, tok.Offset, got, tok.Lexeme)
+		}
+	}
+	eof := toks[len(toks)-1]
+	if eof.Type != EOF || eof.Offset != len(src) || eof.Length != 0 {
+		t.Errorf("expected EOF at offset %d with no length, got %+v", len(src), eof)
+	}
+}
+
+func TestCommentsAreCollected(t *testing.T) {
+	s := NewScanner("// first\nvar a = 1; // second\r\n/ 2;")
+	toks := s.ScanTokens()
+
+	if toks[0].Type != VAR {
+		t.Fatalf("expected comments to be skipped, got %v first", toks[0].Type)
+	}
+	want := []Comment{
+		{Line: 1, Column: 1, Text: "// first"},
+		{Line: 2, Column: 12, Text: "// second"},
+	}
+	got := s.Comments()
+	if len(got) != len(want) {
+		t.Fatalf("expected %d comments, got %#v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("comment %d: got %#v, want %#v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestTriviaCoversEverySourceByte(t *testing.T) {
+	src := "var a = 1; // one\r\n\t@print \"open"
+	var toks []Token
+	shared.Collect(func() {
+		toks = NewScanner(src).WithTrivia().ScanTokens()
+	})
+
+	text := ""
+	var trivia []TokenType
+	for _, tok := range toks {
+		text += tok.Lexeme
+		if tok.Type.IsTrivia() {
+			trivia = append(trivia, tok.Type)
+		}
+	}
+	if text != src {
+		t.Fatalf("expected the lexemes to spell the source, got %q", text)
+	}
+	want := []TokenType{WHITESPACE, WHITESPACE, WHITESPACE, WHITESPACE, COMMENT, NEWLINE, WHITESPACE, ILLEGAL, WHITESPACE, ILLEGAL}
+	if len(trivia) != len(want) {
+		t.Fatalf("expected trivia %v, got %v", want, trivia)
+	}
+	for i := range want {
+		if trivia[i] != want[i] {
+			t.Fatalf("expected trivia %v, got %v", want, trivia)
+		}
+	}
+}
+
+func TestKeywordsAreSortedAndScanAsKeywords(t *testing.T) {
+	words := Keywords()
+	if len(words) != len(keywords) {
+		t.Fatalf("expected %d keywords, got %d", len(keywords), len(words))
+	}
+	for i, word := range words {
+		if i > 0 && words[i-1] >= word {
+			t.Errorf("keywords out of order at %q", word)
+		}
+		if tok := NewScanner(word).ScanTokens()[0]; tok.Type == IDENTIFIER {
+			t.Errorf("%q scanned as an identifier", word)
+		}
+	}
+}
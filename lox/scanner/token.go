@@ -52,13 +52,39 @@ const (
 	WHILE
 
 	EOF
+
+	// Trivia, only produced by a scanner WithTrivia. The parser never
+	// sees these.
+	WHITESPACE // a run of spaces, tabs and carriage returns
+	NEWLINE    // a single '\n'
+	COMMENT    // "//" to the end of the line, not including the '\n'
+	ILLEGAL    // bytes that could not be scanned, already reported
 )
 
 type Token struct {
 	Type    TokenType
 	Lexeme  string
 	Literal any
-	Line    int
+	Line    int // line of the last byte of the lexeme; only strings span lines
+	Column  int // column of the first byte of the lexeme, from 1
+	Offset  int // byte offset of the first byte of the lexeme
+	Length  int // bytes of source the token covers
+}
+
+// IsTrivia reports whether tt is whitespace, a comment or unscannable
+// bytes rather than part of the grammar.
+func (tt TokenType) IsTrivia() bool {
+	return tt >= WHITESPACE && tt <= ILLEGAL
+}
+
+// TokenTypeOf returns the token type whose String is name.
+func TokenTypeOf(name string) (TokenType, bool) {
+	for tt := LEFT_PAREN; tt <= ILLEGAL; tt++ {
+		if tt.String() == name {
+			return tt, true
+		}
+	}
+	return 0, false
 }
 
 // func newToken(t TokenType, lexeme string, literal any, line int) *Token {
@@ -149,6 +175,14 @@ func (tt TokenType) String() string {
 		return "WHILE"
 	case EOF:
 		return "EOF"
+	case WHITESPACE:
+		return "WHITESPACE"
+	case NEWLINE:
+		return "NEWLINE"
+	case COMMENT:
+		return "COMMENT"
+	case ILLEGAL:
+		return "ILLEGAL"
 	default:
 		return "UNKNOWN"
 	}
@@ -3,12 +3,90 @@ package shared
 import (
 	"fmt"
 	"os"
+	"strings"
+
+	"example.com/golox/lox/codes"
 )
 
 // HadError is set to true when any error is reported.
 var HadError bool
 var HadRuntimeError bool
 
+// Diagnostic is a single reported error.
+type Diagnostic struct {
+	Line int
+	// Column is where the offending source starts, from 1, and Length is
+	// how many bytes of it there are. Column is 0 when it is not known.
+	Column  int
+	Length  int
+	Where   string
+	Message string
+	// Code is the kind of error, or the zero Code for ad-hoc reports.
+	Code codes.Code
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("[Line %d] %s%s: %s", d.Line, d.Code.Label(), d.Where, d.Message)
+}
+
+// Caret renders d followed by the offending line of source, with the
+// reported text underlined:
+//
+//	[Line 3] Error at '+': Expect expression.
+//	    3 | print 1 + ;
+//	      |         ^
+//
+// It is just d.String() when the column is unknown or the line is not in
+// source.
+func (d Diagnostic) Caret(source string) string {
+	if excerpt := d.Excerpt(source); excerpt != "" {
+		return d.String() + "\n" + excerpt
+	}
+	return d.String()
+}
+
+// Excerpt is the part of Caret after the message: the offending line of
+// source and the underline. It is "" when Caret would show neither.
+func (d Diagnostic) Excerpt(source string) string {
+	lines := strings.Split(source, "\n")
+	if d.Column < 1 || d.Line < 1 || d.Line > len(lines) {
+		return ""
+	}
+	line := strings.TrimRight(lines[d.Line-1], "\r")
+	column := min(d.Column-1, len(line))
+
+	// Keep tabs in the padding so the caret lines up however wide they are
+	// shown. An underline stops at the end of the line.
+	var pad strings.Builder
+	for _, c := range line[:column] {
+		if c == '\t' {
+			pad.WriteRune('\t')
+		} else {
+			pad.WriteByte(' ')
+		}
+	}
+	length := min(max(d.Length, 1), max(len(line)-column, 1))
+
+	number := fmt.Sprintf("%5d", d.Line)
+	margin := strings.Repeat(" ", len(number))
+	return fmt.Sprintf("%s | %s\n%s | %s^%s", number, line, margin, pad.String(), strings.Repeat("~", length-1))
+}
+
+// Errors is a list of diagnostics returned as an error. Its message has
+// one diagnostic per line.
+type Errors []Diagnostic
+
+func (e Errors) Error() string {
+	lines := make([]string, len(e))
+	for i, d := range e {
+		lines[i] = d.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collected, when non-nil, receives diagnostics instead of stderr.
+var collected *[]Diagnostic
+
 // ErrorAt reports an error at a given line with a message.
 func ErrorAt(line int, message string) {
 	Report(line, "", message)
@@ -16,10 +94,37 @@ func ErrorAt(line int, message string) {
 
 // Report prints a formatted error message and marks HadError.
 func Report(line int, where string, message string) {
-	fmt.Fprintf(os.Stderr, "[Line %d] Error%s: %s\n", line, where, message)
+	report(Diagnostic{Line: line, Where: where, Message: message})
+}
+
+// ReportAt reports an error of the given kind whose column and length
+// are known, so that it can be underlined. args fill in the code's
+// message.
+func ReportAt(line, column, length int, where string, code codes.Code, args ...any) {
+	report(Diagnostic{Line: line, Column: column, Length: length, Where: where, Message: code.Format(args...), Code: code})
+}
+
+func report(d Diagnostic) {
+	if collected != nil {
+		*collected = append(*collected, d)
+	} else {
+		fmt.Fprintln(os.Stderr, d)
+	}
 	HadError = true
 }
 
+// Collect runs f and returns the diagnostics it reported, without
+// printing them. HadError is still set as usual.
+func Collect(f func()) []Diagnostic {
+	previous := collected
+	var diagnostics []Diagnostic
+	collected = &diagnostics
+	defer func() { collected = previous }()
+
+	f()
+	return diagnostics
+}
+
 func ResetErrors() {
     HadError = false
     HadRuntimeError = false
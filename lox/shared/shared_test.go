@@ -5,6 +5,8 @@ import (
 	"os"
 	"strings"
 	"testing"
+
+	"example.com/golox/lox/codes"
 )
 
 func captureStderr(f func()) string {
@@ -63,3 +65,89 @@ func TestReportWithWhereFormatsMessage(t *testing.T) {
 		t.Fatalf("expected stderr to contain %q, got %q", expected, out)
 	}
 }
+
+func TestReportAtShowsTheCode(t *testing.T) {
+	ResetErrors()
+
+	diagnostics := Collect(func() {
+		ReportAt(3, 7, 1, " at end", codes.ExpectSemicolonAfterValue)
+	})
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", diagnostics)
+	}
+	if got, want := diagnostics[0].String(), "[Line 3] Error[L0107] at end: Expect ';' after value."; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCollectCapturesInsteadOfPrinting(t *testing.T) {
+	ResetErrors()
+
+	var diagnostics []Diagnostic
+	out := captureStderr(func() {
+		diagnostics = Collect(func() {
+			ErrorAt(3, "First")
+			Report(4, " at 'x'", "Second")
+		})
+	})
+
+	if out != "" {
+		t.Fatalf("expected nothing on stderr, got %q", out)
+	}
+	if !HadError {
+		t.Fatalf("expected HadError to be true after collected reports")
+	}
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %d", len(diagnostics))
+	}
+	if got := diagnostics[1].String(); got != "[Line 4] Error at 'x': Second" {
+		t.Fatalf("unexpected diagnostic %q", got)
+	}
+}
+
+func TestCaretUnderlinesTheReportedText(t *testing.T) {
+	src := "var a = 1;\n\tprint a + b;\n"
+
+	tests := []struct {
+		name string
+		diag Diagnostic
+		want string
+	}{
+		{
+			name: "token",
+			diag: Diagnostic{Line: 2, Column: 8, Length: 1, Where: " at '+'", Message: "Oops."},
+			want: "[Line 2] Error at '+': Oops.\n" +
+				"    2 | \tprint a + b;\n" +
+				"      | \t      ^",
+		},
+		{
+			name: "longer token",
+			diag: Diagnostic{Line: 2, Column: 2, Length: 5, Where: " at 'print'", Message: "Oops."},
+			want: "[Line 2] Error at 'print': Oops.\n" +
+				"    2 | \tprint a + b;\n" +
+				"      | \t^~~~~",
+		},
+		{
+			name: "past the end of the line",
+			diag: Diagnostic{Line: 1, Column: 11, Length: 0, Where: " at end", Message: "Oops."},
+			want: "[Line 1] Error at end: Oops.\n" +
+				"    1 | var a = 1;\n" +
+				"      |           ^",
+		},
+		{
+			name: "no column",
+			diag: Diagnostic{Line: 1, Message: "Oops."},
+			want: "[Line 1] Error: Oops.",
+		},
+		{
+			name: "no such line",
+			diag: Diagnostic{Line: 9, Column: 1, Length: 1, Message: "Oops."},
+			want: "[Line 9] Error: Oops.",
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.diag.Caret(src); got != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+// Package suggest guesses which name a misspelt one was meant to be, for
+// "Did you mean ...?" hints in error messages.
+package suggest
+
+import "fmt"
+
+// Closest returns the candidate nearest to name, if one is near enough to
+// be a likely typo: at most a third of name's length in edits, and always
+// at least one. Swapping two adjacent letters counts as one edit. Earlier
+// candidates win ties, so callers list the likeliest names first.
+func Closest(name string, candidates []string) (string, bool) {
+	limit := max(len(name)/3, 1)
+	best, bestDistance := "", limit+1
+	for _, candidate := range candidates {
+		if candidate == name {
+			continue
+		}
+		// The distance is at least the difference in length.
+		if diff := len(candidate) - len(name); diff >= bestDistance || -diff >= bestDistance {
+			continue
+		}
+		if d := distance(name, candidate); d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best, best != ""
+}
+
+// DidYouMean returns a hint naming the closest candidate, ready to append
+// to a message, or "" if there is none.
+func DidYouMean(name string, candidates []string) string {
+	s, _ := Closest(name, candidates)
+	return Hint(s)
+}
+
+// Hint returns the hint for a suggestion already found, or "" if
+// suggestion is empty.
+func Hint(suggestion string) string {
+	if suggestion == "" {
+		return ""
+	}
+	return fmt.Sprintf(" Did you mean '%s'?", suggestion)
+}
+
+// distance is the number of single-byte insertions, deletions,
+// substitutions and adjacent transpositions that turn a into b (the
+// optimal string alignment distance).
+func distance(a, b string) int {
+	// rows[i%3][j] is the distance between a[:i] and b[:j].
+	var rows [3][]int
+	for i := range rows {
+		rows[i] = make([]int, len(b)+1)
+	}
+	for j := range rows[0] {
+		rows[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		prev2, prev, row := rows[(i+1)%3], rows[(i+2)%3], rows[i%3]
+		row[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			row[j] = min(prev[j]+1, row[j-1]+1, prev[j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				row[j] = min(row[j], prev2[j-2]+1)
+			}
+		}
+	}
+	return rows[len(a)%3][len(b)]
+}
@@ -0,0 +1,62 @@
+package suggest
+
+import "testing"
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"count", "count", 0},
+		{"", "abc", 3},
+		{"coutn", "count", 1},
+		{"cnt", "count", 2},
+		{"kitten", "sitting", 3},
+		{"ca", "abc", 3},
+	}
+	for _, tt := range tests {
+		if got := distance(tt.a, tt.b); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := distance(tt.b, tt.a); got != tt.want {
+			t.Errorf("distance(%q, %q) = %d, want %d", tt.b, tt.a, got, tt.want)
+		}
+	}
+}
+
+func TestClosest(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []string
+		want       string
+	}{
+		{"coutn", []string{"clock", "count", "total"}, "count"},
+		// Ties go to the earlier candidate.
+		{"prnt", []string{"print", "pint"}, "print"},
+		{"prnt", []string{"pint", "print"}, "pint"},
+		{"totl", []string{"total", "tote"}, "total"},
+		// Short names allow a single edit.
+		{"x", []string{"y", "xs"}, "y"},
+		{"ab", []string{"xy"}, ""},
+		// Too far from anything to be a typo.
+		{"velocity", []string{"position", "count"}, ""},
+		{"count", []string{"count"}, ""},
+		{"count", nil, ""},
+	}
+	for _, tt := range tests {
+		got, ok := Closest(tt.name, tt.candidates)
+		if got != tt.want || ok != (tt.want != "") {
+			t.Errorf("Closest(%q, %v) = %q, %v; want %q", tt.name, tt.candidates, got, ok, tt.want)
+		}
+	}
+}
+
+func TestDidYouMean(t *testing.T) {
+	if got := DidYouMean("coutn", []string{"count"}); got != " Did you mean 'count'?" {
+		t.Errorf("unexpected hint %q", got)
+	}
+	if got := DidYouMean("coutn", nil); got != "" {
+		t.Errorf("expected no hint, got %q", got)
+	}
+}
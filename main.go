@@ -2,12 +2,20 @@ package main
 
 import (
 	"bufio"
+	"errors"
+	"flag"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
 
+	"example.com/golox/lox/ast"
+	"example.com/golox/lox/diagnostics"
 	"example.com/golox/lox/interpreter"
+	"example.com/golox/lox/optimizer"
 	"example.com/golox/lox/parser"
+	"example.com/golox/lox/repl"
 	"example.com/golox/lox/resolver"
 	"example.com/golox/lox/scanner"
 	"example.com/golox/lox/shared"
@@ -21,14 +29,78 @@ const (
 
 var interp = interpreter.NewInterpreter()
 
+var (
+	optimize      = flag.Bool("optimize", false, "run the AST optimizer before interpreting")
+	dumpOptimized = flag.Bool("dump-optimized", false, "print the optimized AST instead of running it")
+
+	trace      = flag.Bool("trace", false, "log each statement, call and return to stderr while running")
+	traceFile  = flag.String("trace-file", "", "write the trace to `file` instead of stderr (implies --trace)")
+	traceCalls = flag.Bool("trace-calls", false, "trace only calls and returns (implies --trace)")
+	traceFunc  = flag.String("trace-func", "", "trace only during calls of the function `name` (implies --trace)")
+)
+
+func init() {
+	flag.Var(&diagnosticsFormat, "diagnostics", diagnosticsUsage)
+}
+
 func main() {
-	args := os.Args[1:]
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: glox [flags] [script]")
+		fmt.Fprintln(os.Stderr, "       glox ast [--format sexpr|dot|json] [--diagnostics format] file.lox")
+		fmt.Fprintln(os.Stderr, "       glox conformance [-v] [--diagnostics format] [dir | file ...]")
+		fmt.Fprintln(os.Stderr, "       glox dap [--listen addr]")
+		fmt.Fprintln(os.Stderr, "       glox debug [--break spec,...] script")
+		fmt.Fprintln(os.Stderr, "       glox explain [code ...]")
+		fmt.Fprintln(os.Stderr, "       glox fmt [--check | --write] [--diagnostics format] [file ...]")
+		fmt.Fprintln(os.Stderr, "       glox lint [--disable rule,...] [--diagnostics format] file ...")
+		fmt.Fprintln(os.Stderr, "       glox lsp")
+		fmt.Fprintln(os.Stderr, "       glox rename [--write] [--diagnostics format] file.lox:line:column newName")
+		fmt.Fprintln(os.Stderr, "       glox tokens [--json] [--trivia] [--diagnostics format] file.lox")
+		fmt.Fprintln(os.Stderr, "       glox test [-v] [--diagnostics format] [dir | file ...]")
+		fmt.Fprintln(os.Stderr, "--diagnostics works with a script and the commands that show it. The REPL, debug, dap,")
+		fmt.Fprintln(os.Stderr, "lsp and explain do not take it: they report errors interactively, over their own")
+		fmt.Fprintln(os.Stderr, "protocol or not at all.")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) > 0 {
+		if command, ok := commands[args[0]]; ok {
+			if diagnosticsFormat.Structured() && !structuredCommands[args[0]] {
+				fmt.Fprintf(os.Stderr, "glox %s does not support --diagnostics=%s\n", args[0], diagnosticsFormat)
+				os.Exit(exitUsage)
+			}
+			if tracing() {
+				fmt.Fprintf(os.Stderr, "glox %s does not support --trace\n", args[0])
+				os.Exit(exitUsage)
+			}
+			os.Exit(command(args[1:]))
+		}
+	}
+
 	if len(args) > 1 {
-		fmt.Println("Usage: glox [script]")
+		flag.Usage()
+		os.Exit(exitUsage)
 	} else if len(args) == 1 {
 		shared.ResetErrors()
-		if err := runFile(args[0]); err != nil {
-			fmt.Fprintln(os.Stderr, "Error:", err)
+		stopTrace := func() {}
+		if tracing() {
+			stop, err := startTrace()
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+			stopTrace = stop
+		}
+		err := runFile(args[0])
+		stopTrace()
+		if err != nil {
+			var log diagnosticsLog
+			log.fail(args[0], err, fmt.Sprintln("Error:", err))
+			if diagnosticsFormat.Structured() {
+				log.write()
+			}
 			os.Exit(exitDataError)
 		}
 		
@@ -39,51 +111,143 @@ func main() {
 			os.Exit(exitRuntimeError) 
 		}
 	} else {
+		if diagnosticsFormat.Structured() {
+			fmt.Fprintf(os.Stderr, "The REPL does not support --diagnostics=%s\n", diagnosticsFormat)
+			os.Exit(exitUsage)
+		}
+		if tracing() {
+			fmt.Fprintln(os.Stderr, "The REPL does not support --trace")
+			os.Exit(exitUsage)
+		}
 		runPrompt()
 	}
 }
 
+// tracing reports whether any of the --trace flags was given.
+func tracing() bool {
+	return *trace || *traceFile != "" || *traceCalls || *traceFunc != ""
+}
+
+// startTrace installs a tracer on the interpreter, writing to stderr or
+// to --trace-file. The returned function flushes and closes the file, and
+// has to be called before exiting.
+func startTrace() (stop func(), err error) {
+	options := interpreter.TraceOptions{CallsOnly: *traceCalls, Function: *traceFunc}
+	if *traceFile == "" {
+		// Unbuffered, so the trace interleaves with the program's output.
+		interp.SetHooks(interpreter.NewTracer(os.Stderr, options))
+		return func() {}, nil
+	}
+
+	file, err := os.Create(*traceFile)
+	if err != nil {
+		return nil, err
+	}
+	buffered := bufio.NewWriter(file)
+	interp.SetHooks(interpreter.NewTracer(buffered, options))
+	return func() {
+		buffered.Flush()
+		file.Close()
+	}, nil
+}
+
 func runFile(path string) error {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		return fmt.Errorf("failed to read %q: %w", path, err)
 	}
-	return run(string(data))
+	return run(path, string(data))
 }
 
+// runPrompt starts the REPL. SIGINT is delivered to it, so Ctrl-C cancels
+// the current input or stops a running program instead of exiting.
 func runPrompt() {
-	reader := bufio.NewReader(os.Stdin)
-	for {
-		fmt.Print("> ")
-		line, err := reader.ReadString('\n')
-		if err == io.EOF { fmt.Println(); return}
-		if err != nil { fmt.Fprintln(os.Stderr, "Read error: ", err); return}
+	r := repl.New(interp, os.Stdout, os.Stderr)
+	if *optimize {
+		r.Optimizer = optimizer.Default()
+	}
 
-		shared.ResetErrors()
-		if err := run(line); err != nil {
-			fmt.Fprintln(os.Stderr, "Error: ", err)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	defer signal.Stop(signals)
+	go func() {
+		for range signals {
+			r.Interrupt()
 		}
+	}()
+
+	editor, err := r.Terminal(os.Stdin, historyFile())
+	if err != nil {
+		if !errors.Is(err, repl.ErrNotTerminal) {
+			fmt.Fprintln(os.Stderr, "Warning:", err)
+		}
+		r.Run(r.Reader(os.Stdin))
+		return
+	}
+	r.Run(editor)
+}
+
+// historyFile is where the REPL keeps its history: ~/.glox_history, or
+// nowhere when there is no home directory.
+func historyFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
 	}
+	return filepath.Join(home, ".glox_history")
 }
 
-func run(source string) error {
-	sc := scanner.NewScanner(source)
-	tokens := sc.ScanTokens()
+// run runs the script at path. With a structured --diagnostics format,
+// its errors are written to stderr as one document instead of as text.
+func run(path, source string) error {
+	var log diagnosticsLog
+	if diagnosticsFormat.Structured() {
+		interp.SetOutput(nil, io.Discard)
+		defer func() {
+			if rt, ok := interp.LastError(); ok {
+				log.add(diagnostics.FromRuntimeError(path, rt))
+			}
+			log.write()
+		}()
+	}
+	report := func(diags []shared.Diagnostic) bool {
+		for _, diag := range diags {
+			if diagnosticsFormat.Structured() {
+				log.add(diagnostics.FromDiagnostic(path, diag))
+			} else {
+				fmt.Fprintln(os.Stderr, diag.Caret(source))
+			}
+		}
+		return len(diags) > 0
+	}
 
-	p := parser.NewParser(tokens)
-	statements := p.Parse()
+	var statements []ast.Stmt
+	if report(shared.Collect(func() {
+		sc := scanner.NewScanner(source).WithInterner(interp.Strings())
+		tokens := sc.ScanTokens()
 
-	if shared.HadError || statements == nil {
+		p := parser.NewParser(tokens)
+		statements = p.Parse()
+	})) || statements == nil {
 		return nil
 	}
 
-	res := resolver.NewResolver(interp)
-	res.Resolve(statements)
+	if report(shared.Collect(func() {
+		res := resolver.NewResolver(interp)
+		res.Resolve(statements)
+	})) {
+		return nil
+	}
 
-	if shared.HadError {
+	if *optimize || *dumpOptimized {
+		statements = optimizer.Default().Optimize(statements)
+	}
+	if *dumpOptimized {
+		fmt.Print((&ast.AstPrinter{}).PrintProgram(statements))
 		return nil
 	}
 
+	interp.SetSource(source)
 	interp.Interpret(statements)
 	return nil
 }
@@ -23,6 +23,7 @@ func main() {
 		"Assign   : Token name, Expr value",
 		"Binary   : Expr left, Token operator, Expr right",
 		"Call     : Expr callee, Token paren, List<Expr> arguments",
+		"Error    : List<Token> tokens",
 		"Get      : Expr object, Token name",
 		"Grouping : Expr expression",
 		"Literal  : any value",
@@ -38,6 +39,7 @@ func main() {
 	}
 
 	if err := defineAst(outputDir, "Stmt", []string{
+		"Bad        : List<Token> tokens",
 		"Block      : List<Stmt> statements",
       	"Class      : Token name, Expr superclass," +
                   	" List<Function> methods",
@@ -79,6 +81,7 @@ func defineAst(outputDir, baseName string, types []string) error {
 
     fmt.Fprintf(w, "type %s interface {\n", baseName)
     fmt.Fprintf(w, "\tAccept(v %s) any\n", visitorName)
+    fmt.Fprintln(w, "\tExtent() Span")
     fmt.Fprintln(w, "}")
     fmt.Fprintln(w)
 
@@ -121,6 +124,8 @@ func defineVisitor(w *bufio.Writer, baseName string, types []string) error {
 func defineType(w *bufio.Writer, baseName, className, fieldList string) error {
 	// struct header: type Binary struct { ... }
 	fmt.Fprintf(w, "type %s struct {\n", className)
+	// Node carries the span the parser fills in; see span.go.
+	fmt.Fprintln(w, "\tNode")
 
 	fields := strings.Split(fieldList, ", ")
 	for _, f := range fields {